@@ -0,0 +1,318 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	ACCOUNT_TOKEN_TTL        = 7 * 24 * time.Hour // 登录令牌有效期
+	MAX_USERNAME_LENGTH      = 20
+	MIN_PASSWORD_LENGTH      = 6
+	PASSWORD_HASH_ITERATIONS = 100000 // PBKDF2迭代次数
+	ALLOW_GUESTS             = true   // 是否允许不登录（游客）连接，游客不能使用已注册的昵称
+)
+
+// 账号保存的文件（测试时替换为临时文件）
+var accountsFile = "accounts.json"
+
+// 账号：只保存加盐的密码哈希
+type Account struct {
+	Username  string    `json:"username"`
+	Salt      string    `json:"salt"`   // base64
+	Hash      string    `json:"hash"`   // base64，PBKDF2-HMAC-SHA256
+	Wallet    int       `json:"wallet"` // 钱包余额
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// 登录会话
+type accountSession struct {
+	Username  string
+	ExpiresAt time.Time
+}
+
+var accounts = make(map[string]*Account)               // 用户名 -> 账号
+var accountSessions = make(map[string]*accountSession) // 令牌 -> 会话
+var accountsMutex sync.RWMutex
+
+// 启动时从文件加载账号，文件不存在时没有账号
+func loadAccounts() error {
+	data, err := os.ReadFile(accountsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []*Account
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	accountsMutex.Lock()
+	defer accountsMutex.Unlock()
+	for _, account := range list {
+		accounts[account.Username] = account
+	}
+	log.Printf("加载了 %d 个账号", len(list))
+	return nil
+}
+
+// 把所有账号写入文件，先写临时文件再替换，避免写到一半时损坏（调用者需持有写锁）
+func saveAccounts() error {
+	list := make([]*Account, 0, len(accounts))
+	for _, account := range accounts {
+		list = append(list, account)
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := accountsFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, accountsFile)
+}
+
+// 请求的静态文件是否是账号文件
+func isAccountsFile(urlPath string) bool {
+	name := filepath.Clean(strings.TrimPrefix(urlPath, "/"))
+	return name == filepath.Clean(accountsFile) || name == filepath.Clean(accountsFile+".tmp")
+}
+
+// PBKDF2-HMAC-SHA256（RFC 8018）
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	mac := hmac.New(sha256.New, password)
+	key := make([]byte, 0, keyLen)
+	for block := uint32(1); len(key) < keyLen; block++ {
+		mac.Reset()
+		mac.Write(salt)
+		mac.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u := mac.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			mac.Reset()
+			mac.Write(u)
+			u = mac.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+func hashAccountPassword(salt []byte, password string) []byte {
+	return pbkdf2SHA256([]byte(password), salt, PASSWORD_HASH_ITERATIONS, sha256.Size)
+}
+
+// 注册账号
+func registerAccount(username, password string) error {
+	username = strings.TrimSpace(username)
+	if username == "" || utf8.RuneCountInString(username) > MAX_USERNAME_LENGTH {
+		return errors.New("用户名长度需要在1到20个字符之间")
+	}
+	if utf8.RuneCountInString(password) < MIN_PASSWORD_LENGTH {
+		return errors.New("密码至少需要6个字符")
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	account := &Account{
+		Username:  username,
+		Salt:      base64.StdEncoding.EncodeToString(salt),
+		Hash:      base64.StdEncoding.EncodeToString(hashAccountPassword(salt, password)),
+		Wallet:    WALLET_INITIAL_BALANCE,
+		CreatedAt: time.Now(),
+	}
+
+	accountsMutex.Lock()
+	defer accountsMutex.Unlock()
+	if _, exists := accounts[username]; exists {
+		return errors.New("用户名已被注册")
+	}
+	accounts[username] = account
+	if err := saveAccounts(); err != nil {
+		delete(accounts, username)
+		log.Printf("保存账号失败: %v", err)
+		return errors.New("保存账号失败")
+	}
+	log.Printf("注册账号 %s", username)
+	return nil
+}
+
+// 检查用户名和密码
+func authenticate(username, password string) error {
+	accountsMutex.RLock()
+	account, exists := accounts[strings.TrimSpace(username)]
+	accountsMutex.RUnlock()
+	if !exists {
+		return errors.New("用户名或密码错误")
+	}
+	salt, err := base64.StdEncoding.DecodeString(account.Salt)
+	if err != nil {
+		return errors.New("用户名或密码错误")
+	}
+	hash, err := base64.StdEncoding.DecodeString(account.Hash)
+	if err != nil || subtle.ConstantTimeCompare(hashAccountPassword(salt, password), hash) != 1 {
+		return errors.New("用户名或密码错误")
+	}
+	return nil
+}
+
+// 为登录的账号生成令牌
+func createSession(username string) (string, time.Time) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		panic(err)
+	}
+	token := hex.EncodeToString(raw)
+	expiresAt := time.Now().Add(ACCOUNT_TOKEN_TTL)
+
+	accountsMutex.Lock()
+	accountSessions[token] = &accountSession{Username: username, ExpiresAt: expiresAt}
+	accountsMutex.Unlock()
+	return token, expiresAt
+}
+
+// 令牌对应的账号，令牌无效或过期时返回false
+func sessionAccount(token string) (string, bool) {
+	accountsMutex.Lock()
+	defer accountsMutex.Unlock()
+	session, exists := accountSessions[token]
+	if !exists {
+		return "", false
+	}
+	if time.Now().After(session.ExpiresAt) {
+		delete(accountSessions, token)
+		return "", false
+	}
+	return session.Username, true
+}
+
+// 昵称是否是已注册的用户名
+func accountExists(name string) bool {
+	accountsMutex.RLock()
+	defer accountsMutex.RUnlock()
+	_, exists := accounts[name]
+	return exists
+}
+
+// 玩家在房间中使用的昵称：登录的玩家总是使用用户名，游客不能使用已注册的用户名
+func accountPlayerName(player *Player, requested string) (string, error) {
+	if player.Account != "" {
+		return player.Account, nil
+	}
+	if accountExists(requested) {
+		return "", errors.New("该昵称已被注册，请登录后使用")
+	}
+	return requested, nil
+}
+
+// 从请求中取出登录令牌：WebSocket连接用URL参数token，HTTP接口也可以用Authorization: Bearer
+func requestToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// 返回JSON响应
+func writeAccountResponse(w http.ResponseWriter, status int, data map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+// 解析注册和登录请求的用户名和密码
+func readCredentials(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return "", "", false
+	}
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		writeAccountResponse(w, http.StatusBadRequest, map[string]interface{}{"error": "请求数据格式错误"})
+		return "", "", false
+	}
+	return strings.TrimSpace(req.Username), req.Password, true
+}
+
+// HTTP接口：POST /api/register 注册并登录
+func handleRegister(w http.ResponseWriter, r *http.Request) {
+	username, password, ok := readCredentials(w, r)
+	if !ok {
+		return
+	}
+	if err := registerAccount(username, password); err != nil {
+		writeAccountResponse(w, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return
+	}
+	token, expiresAt := createSession(username)
+	writeAccountResponse(w, http.StatusOK, map[string]interface{}{
+		"username":  username,
+		"token":     token,
+		"expiresAt": expiresAt,
+	})
+}
+
+// HTTP接口：POST /api/login 登录，返回令牌（同一地址1分钟内失败5次后暂时禁止登录）
+func handleLogin(w http.ResponseWriter, r *http.Request) {
+	username, password, ok := readCredentials(w, r)
+	if !ok {
+		return
+	}
+	limitKey := "login:" + clientAddr(r)
+	if failuresBlocked(limitKey) {
+		writeAccountResponse(w, http.StatusTooManyRequests, map[string]interface{}{"error": "尝试次数过多，请稍后再试"})
+		return
+	}
+	if err := authenticate(username, password); err != nil {
+		log.Printf("登录失败: 用户名=%s, 地址=%s, 最近失败 %d 次", username, clientAddr(r), recordFailure(limitKey))
+		writeAccountResponse(w, http.StatusUnauthorized, map[string]interface{}{"error": err.Error()})
+		return
+	}
+	token, expiresAt := createSession(username)
+	log.Printf("账号 %s 登录", username)
+	writeAccountResponse(w, http.StatusOK, map[string]interface{}{
+		"username":  username,
+		"token":     token,
+		"expiresAt": expiresAt,
+	})
+}
+
+// HTTP接口：POST /api/logout 使令牌失效
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	accountsMutex.Lock()
+	delete(accountSessions, requestToken(r))
+	accountsMutex.Unlock()
+	writeAccountResponse(w, http.StatusOK, map[string]interface{}{})
+}
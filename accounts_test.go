@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 使用临时文件保存账号，测试结束后恢复
+func useTestAccountsFile(t *testing.T) {
+	old := accountsFile
+	accountsFile = filepath.Join(t.TempDir(), "accounts.json")
+	t.Cleanup(func() { accountsFile = old })
+}
+
+// 测试PBKDF2-HMAC-SHA256（RFC 7914第11节的测试向量）
+func TestPBKDF2SHA256(t *testing.T) {
+	key := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)
+	expected := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if hex.EncodeToString(key) != expected {
+		t.Errorf("Unexpected key: %x", key)
+	}
+}
+
+// 测试注册、登录、令牌和昵称保护
+func TestAccounts(t *testing.T) {
+	useTestAccountsFile(t)
+
+	if err := registerAccount("AccountAlice", "secret1"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := registerAccount("AccountAlice", "secret2"); err == nil {
+		t.Error("Duplicate username should be rejected")
+	}
+	if err := registerAccount("AccountBob", "123"); err == nil {
+		t.Error("Short password should be rejected")
+	}
+	if err := authenticate("AccountAlice", "secret1"); err != nil {
+		t.Errorf("Login failed: %v", err)
+	}
+	if err := authenticate("AccountAlice", "wrong"); err == nil {
+		t.Error("Wrong password should be rejected")
+	}
+
+	// 账号保存在文件中，重新加载后还能登录
+	accountsMutex.Lock()
+	delete(accounts, "AccountAlice")
+	accountsMutex.Unlock()
+	if err := loadAccounts(); err != nil {
+		t.Fatal(err)
+	}
+	if err := authenticate("AccountAlice", "secret1"); err != nil {
+		t.Errorf("Login after reload failed: %v", err)
+	}
+
+	token, _ := createSession("AccountAlice")
+	if username, ok := sessionAccount(token); !ok || username != "AccountAlice" {
+		t.Error("Token should identify the account")
+	}
+	if _, ok := sessionAccount("bogus"); ok {
+		t.Error("Unknown token should be rejected")
+	}
+
+	// 登录的玩家使用用户名，游客不能冒用已注册的用户名
+	if name, _ := accountPlayerName(&Player{Account: "AccountAlice"}, "Mallory"); name != "AccountAlice" {
+		t.Errorf("Logged in player should use the username, got %s", name)
+	}
+	if _, err := accountPlayerName(&Player{}, "AccountAlice"); err == nil {
+		t.Error("Guest should not use a registered username")
+	}
+	if name, err := accountPlayerName(&Player{}, "Guest"); err != nil || name != "Guest" {
+		t.Errorf("Guest should keep an unregistered name, got %s, %v", name, err)
+	}
+}
+
+// 测试HTTP登录接口
+func TestHandleLogin(t *testing.T) {
+	useTestAccountsFile(t)
+	if err := registerAccount("AccountCarol", "secret1"); err != nil {
+		t.Fatal(err)
+	}
+
+	login := func(password string) *httptest.ResponseRecorder {
+		body := `{"username": "AccountCarol", "password": "` + password + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(body))
+		req.RemoteAddr = "198.51.100.9:1234"
+		rec := httptest.NewRecorder()
+		handleLogin(rec, req)
+		return rec
+	}
+
+	rec := login("secret1")
+	var resp map[string]interface{}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	token, _ := resp["token"].(string)
+	if rec.Code != http.StatusOK || token == "" {
+		t.Fatalf("Expected a token, got %d %v", rec.Code, resp)
+	}
+	if username, ok := sessionAccount(token); !ok || username != "AccountCarol" {
+		t.Error("Returned token should be valid")
+	}
+
+	for i := 0; i < JOIN_MAX_FAILURES; i++ {
+		if rec := login("wrong"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected 401, got %d", rec.Code)
+		}
+	}
+	if rec := login("secret1"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected login to be rate limited, got %d", rec.Code)
+	}
+}
+
+// 测试账号文件不能当作静态文件访问
+func TestServeStaticHidesAccounts(t *testing.T) {
+	for _, path := range []string{"/accounts.json", "/accounts.json.tmp"} {
+		rec := httptest.NewRecorder()
+		serveStatic(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("Expected %s to be hidden, got %d", path, rec.Code)
+		}
+	}
+}
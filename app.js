@@ -2,12 +2,17 @@
 let ws = null;
 let currentRoom = null;
 let currentPlayer = null;
+let currentMTT = null; // 报名的多桌锦标赛ID
+let inviteToken = null; // 通过邀请链接打开时的邀请令牌
 let gameState = null;
 let turnTimer = null; // 回合倒计时定时器
 let isSettlement = false; // 是否在结算状态
 let settlementData = null; // 结算数据
 let heartbeatInterval = null; // 心跳定时器
 let isSpectating = false; // 是否在观战状态
+let authToken = localStorage.getItem('authToken'); // 登录令牌（游客为空）
+let authUser = localStorage.getItem('authUser'); // 登录的用户名
+let useHttpTransport = false; // WebSocket连不上时改用HTTP连接（Server-Sent Events或长轮询接收，POST发送）
 
 // DOM元素
 const loginScreen = document.getElementById('loginScreen');
@@ -18,11 +23,21 @@ const gameEndScreen = document.getElementById('gameEndScreen');
 // 初始化
 document.addEventListener('DOMContentLoaded', () => {
     setupEventListeners();
+    updateAccountUI();
     // 检查URL参数（分享链接）
     checkUrlParams();
     // 连接WebSocket
     connectWebSocket();
     
+    // 查看兔子牌按钮事件
+    const rabbitHuntBtn = document.getElementById('rabbitHuntBtn');
+    if (rabbitHuntBtn) {
+        rabbitHuntBtn.addEventListener('click', () => {
+            rabbitHuntBtn.classList.add('hidden');
+            sendMessage({ type: 'rabbitHunt', data: {} });
+        });
+    }
+
     // 下一局按钮事件
     const nextHandBtn = document.getElementById('nextHandBtn');
     if (nextHandBtn) {
@@ -57,6 +72,10 @@ function setupEventListeners() {
     document.getElementById('roomId').addEventListener('keypress', (e) => {
         if (e.key === 'Enter') joinGame();
     });
+    document.getElementById('loginBtn').addEventListener('click', () => submitAccount('login'));
+    document.getElementById('registerBtn').addEventListener('click', () => submitAccount('register'));
+    document.getElementById('logoutBtn').addEventListener('click', logout);
+    document.getElementById('leaderboardPeriod').addEventListener('change', subscribeLeaderboard);
 
     // 大厅界面
     document.getElementById('startGameBtn').addEventListener('click', startGame);
@@ -64,6 +83,48 @@ function setupEventListeners() {
     
     // 游戏界面中的开始按钮
     document.getElementById('startGameBtnInGame').addEventListener('click', startGame);
+    document.getElementById('scheduleBombPotBtn').addEventListener('click', scheduleBombPot);
+    document.getElementById('pauseGameBtn').addEventListener('click', () => {
+        sendMessage({ type: gameState && gameState.paused ? 'resumeGame' : 'pauseGame', data: {} });
+    });
+    document.getElementById('kickPlayerBtn').addEventListener('click', () => hostCommandOnPlayer('kickPlayer', '踢出'));
+    document.getElementById('banPlayerBtn').addEventListener('click', () => hostCommandOnPlayer('banPlayer', '禁止'));
+    document.getElementById('transferHostBtn').addEventListener('click', () => hostCommandOnPlayer('transferHost', '转让房主给'));
+    document.getElementById('mutePlayerBtn').addEventListener('click', () => hostCommandOnPlayer('mutePlayer', '禁言'));
+    document.getElementById('unmutePlayerBtn').addEventListener('click', () => hostCommandOnPlayer('unmutePlayer', '解除禁言'));
+    document.getElementById('chatSendBtn').addEventListener('click', sendChat);
+    document.getElementById('chatInput').addEventListener('keypress', (e) => {
+        if (e.key === 'Enter') sendChat();
+    });
+    document.getElementById('roomSettingsBtn').addEventListener('click', () => {
+        const blinds = prompt('新的盲注（小盲/大盲），下一局生效：', gameState ? `${gameState.smallBlind}/${gameState.bigBlind}` : '10/20');
+        if (!blinds) return;
+        const [smallBlind, bigBlind] = blinds.split('/').map(v => parseInt(v, 10));
+        sendMessage({ type: 'updateSettings', data: { settings: { smallBlind: smallBlind, bigBlind: bigBlind } } });
+    });
+    document.getElementById('overlayKeyBtn').addEventListener('click', () => {
+        const delay = prompt('直播画面延迟多少秒（至少30秒）？生成新链接后旧链接失效', '60');
+        if (!delay) return;
+        sendMessage({ type: 'createOverlayKey', data: { delay: parseInt(delay, 10) } });
+    });
+    document.getElementById('closeRoomBtn').addEventListener('click', () => {
+        if (confirm('确定关闭房间？所有人都会离开房间')) {
+            sendMessage({ type: 'closeRoom', data: {} });
+        }
+    });
+    document.getElementById('dealBtn').addEventListener('click', () => {
+        sendMessage({ type: 'getDealQuote', data: {} });
+    });
+    document.getElementById('startMTTBtn').addEventListener('click', () => {
+        if (currentMTT) {
+            sendMessage({ type: 'startMTT', data: { mttId: currentMTT } });
+        }
+    });
+    document.getElementById('unregisterMTTBtn').addEventListener('click', () => {
+        if (currentMTT) {
+            sendMessage({ type: 'unregisterMTT', data: { mttId: currentMTT } });
+        }
+    });
     
     // 上桌按钮
     const joinTableBtn = document.getElementById('joinTableBtn');
@@ -71,18 +132,11 @@ function setupEventListeners() {
         joinTableBtn.addEventListener('click', joinTable);
     }
     
-    // 观战面板的买一手按钮
-    const buyHandBtnSpectating = document.getElementById('buyHandBtnSpectating');
-    if (buyHandBtnSpectating) {
-        buyHandBtnSpectating.addEventListener('click', () => {
-            if (ws && ws.readyState === WebSocket.OPEN) {
-                ws.send(JSON.stringify({
-                    type: 'buyHand',
-                    data: {}
-                }));
-            }
-        });
-    }
+    // 等候名单按钮（已在名单中时离开名单）
+    document.getElementById('waitlistBtn').addEventListener('click', () => {
+        const inWaitlist = myWaitlistPosition(gameState) !== null;
+        sendMessage({ type: inWaitlist ? 'leaveWaitlist' : 'joinWaitlist', data: {} });
+    });
     
     // 分享房间按钮
     const shareRoomBtn = document.getElementById('shareRoomBtn');
@@ -95,6 +149,14 @@ function setupEventListeners() {
     document.getElementById('checkBtn').addEventListener('click', () => sendAction('check'));
     document.getElementById('callBtn').addEventListener('click', () => sendAction('call'));
     
+    // 离桌兑现按钮（本局进行中时再点一次取消）
+    document.getElementById('standUpBtn').addEventListener('click', () => {
+        const me = gameState && gameState.players
+            ? gameState.players.find(p => p.name === document.getElementById('playerName').value.trim())
+            : null;
+        sendMessage({ type: 'standUp', data: { cancel: !!(me && me.standingUp) } });
+    });
+
     // 买一手按钮（操作面板中的）
     const buyHandBtn = document.getElementById('buyHandBtn');
     if (buyHandBtn) {
@@ -134,6 +196,22 @@ function setupEventListeners() {
         });
     }
     
+    // 玩家统计按钮
+    document.getElementById('playerStatsBtn').addEventListener('click', () => requestStats('room'));
+    document.getElementById('roomStatsBtn').addEventListener('click', () => requestStats('room'));
+    document.getElementById('overallStatsBtn').addEventListener('click', () => requestStats('overall'));
+    document.getElementById('closePlayerStatsBtn').addEventListener('click', () => {
+        document.getElementById('playerStatsModal').classList.add('hidden');
+    });
+
+    // 本场结算按钮
+    document.getElementById('sessionReportBtn').addEventListener('click', () => {
+        sendMessage({ type: 'getSessionReport', data: {} });
+    });
+    document.getElementById('closeSessionReportBtn').addEventListener('click', () => {
+        document.getElementById('sessionReportModal').classList.add('hidden');
+    });
+    
     // 关闭买一手统计模态框
     const closeBuyHandStatsBtn = document.getElementById('closeBuyHandStatsBtn');
     if (closeBuyHandStatsBtn) {
@@ -267,16 +345,152 @@ function setupEventListeners() {
     document.getElementById('newHandBtn').addEventListener('click', startGame);
 }
 
+// WebSocket被屏蔽时的备用连接，接口与WebSocket相同（readyState、send、close和onopen等回调），其他代码不需要区分
+// 消息通过Server-Sent Events接收（浏览器不支持时用长轮询），通过POST /api/send发送
+class HttpTransport {
+    constructor(token) {
+        this.readyState = WebSocket.CONNECTING;
+        this.token = token;
+        this.conn = null;
+        this.source = null;
+        this.sending = Promise.resolve(); // 按顺序发送消息
+        this.open();
+    }
+
+    async open() {
+        try {
+            const headers = this.token ? { 'Authorization': `Bearer ${this.token}` } : {};
+            const response = await fetch('/api/connect', { method: 'POST', headers });
+            if (!response.ok) {
+                throw new Error(`HTTP ${response.status}`);
+            }
+            this.conn = (await response.json()).conn;
+        } catch (error) {
+            console.error('建立HTTP连接失败:', error);
+            if (this.readyState === WebSocket.CONNECTING) {
+                if (this.onerror) this.onerror(error);
+                this.finish(1006);
+            }
+            return;
+        }
+        // 连接建立前已经关闭
+        if (this.readyState !== WebSocket.CONNECTING) {
+            this.disconnect();
+            return;
+        }
+        this.readyState = WebSocket.OPEN;
+        if (this.onopen) this.onopen({});
+        if (window.EventSource) {
+            this.stream();
+        } else {
+            this.poll();
+        }
+    }
+
+    url(path) {
+        return `${path}?conn=${encodeURIComponent(this.conn)}`;
+    }
+
+    receive(data) {
+        if (this.readyState === WebSocket.OPEN && this.onmessage) {
+            this.onmessage({ data });
+        }
+    }
+
+    // 断开后（服务端也会定期结束响应）EventSource自动重连，用Last-Event-ID确认收到的消息并补发没有收到的消息，连接已失效时不再重连
+    stream() {
+        this.source = new EventSource(this.url('/api/stream'));
+        this.source.onmessage = (event) => this.receive(event.data);
+        this.source.onerror = () => {
+            if (this.source.readyState === EventSource.CLOSED) {
+                this.finish(1006);
+            }
+        };
+    }
+
+    async poll() {
+        let since = 0;
+        while (this.readyState === WebSocket.OPEN) {
+            try {
+                const response = await fetch(`${this.url('/api/poll')}&since=${since}`, { cache: 'no-store' });
+                if (response.status === 404 || response.status === 409) {
+                    this.finish(1006);
+                    return;
+                }
+                if (!response.ok) {
+                    throw new Error(`HTTP ${response.status}`);
+                }
+                const result = await response.json();
+                result.messages.forEach(message => this.receive(JSON.stringify(message)));
+                since = result.lastId;
+            } catch (error) {
+                // 网络错误时稍后重试，没有确认的消息会重新返回
+                console.error('长轮询失败:', error);
+                await new Promise(resolve => setTimeout(resolve, 2000));
+            }
+        }
+    }
+
+    send(data) {
+        if (this.readyState !== WebSocket.OPEN) {
+            return;
+        }
+        this.sending = this.sending
+            .then(() => fetch(this.url('/api/send'), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: data
+            }))
+            .then(response => {
+                if (response.status === 404) {
+                    this.finish(1006);
+                }
+            })
+            .catch(error => console.error('发送消息失败:', error));
+    }
+
+    close(code) {
+        if (this.readyState === WebSocket.CLOSED) {
+            return;
+        }
+        const wasOpen = this.readyState === WebSocket.OPEN;
+        this.finish(code || 1005);
+        if (wasOpen) {
+            this.disconnect();
+        }
+    }
+
+    disconnect() {
+        fetch(this.url('/api/connect'), { method: 'DELETE', keepalive: true }).catch(() => {});
+    }
+
+    finish(code) {
+        if (this.readyState === WebSocket.CLOSED) {
+            return;
+        }
+        this.readyState = WebSocket.CLOSED;
+        if (this.source) {
+            this.source.close();
+        }
+        if (this.onclose) this.onclose({ code, reason: '' });
+    }
+}
+
 function connectWebSocket() {
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const wsUrl = `${protocol}//${window.location.host}/ws`;
+    let wsUrl = `${protocol}//${window.location.host}/ws`;
+    if (authToken) {
+        wsUrl += `?token=${encodeURIComponent(authToken)}`;
+    }
     
-    console.log('正在连接WebSocket:', wsUrl);
+    console.log('正在连接:', useHttpTransport ? 'HTTP' : `${protocol}//${window.location.host}/ws`);
     
     try {
-        ws = new WebSocket(wsUrl);
+        ws = useHttpTransport ? new HttpTransport(authToken) : new WebSocket(wsUrl);
+        let opened = false;
 
         ws.onopen = () => {
+            opened = true;
             console.log('✅ WebSocket连接已建立');
             // 清除之前的错误提示
             const errorDiv = document.getElementById('loginError');
@@ -286,6 +500,11 @@ function connectWebSocket() {
             }
             // 启动心跳
             startHeartbeat();
+            // 在登录界面时订阅大厅房间列表
+            if (!currentRoom) {
+                sendMessage({ type: 'subscribeLobby', data: {} });
+                subscribeLeaderboard();
+            }
         };
 
         ws.onmessage = (event) => {
@@ -309,12 +528,29 @@ function connectWebSocket() {
         ws.onerror = (error) => {
             console.error('❌ WebSocket错误:', error);
             console.error('WebSocket状态:', ws ? ws.readyState : 'null');
-            showError('WebSocket连接错误，请检查服务器是否运行');
+            // WebSocket连不上时改用HTTP连接，不提示错误
+            if (opened || useHttpTransport) {
+                showError('连接错误，请检查服务器是否运行');
+            }
         };
 
         ws.onclose = (event) => {
-            console.log('WebSocket连接已关闭:', event.code, event.reason);
+            console.log('连接已关闭:', event.code, event.reason);
             stopHeartbeat();
+            // WebSocket还没建立就失败（可能被网络屏蔽），改用HTTP连接；令牌过期时HTTP连接同样会被拒绝
+            if (!opened && !useHttpTransport) {
+                console.log('WebSocket连接失败，改用HTTP连接');
+                useHttpTransport = true;
+                connectWebSocket();
+                return;
+            }
+            // 带令牌的连接还没建立就被拒绝，一般是令牌已过期，退回游客身份重连
+            if (!opened && authToken) {
+                clearAccount();
+                showError('登录已过期，请重新登录');
+                connectWebSocket();
+                return;
+            }
             if (event.code !== 1000) {
                 showError('连接已断开，请刷新页面重试');
             }
@@ -325,6 +561,83 @@ function connectWebSocket() {
     }
 }
 
+// 显示登录状态：登录后昵称固定为用户名
+function updateAccountUI() {
+    const nameInput = document.getElementById('playerName');
+    document.getElementById('accountForm').classList.toggle('hidden', !!authUser);
+    document.getElementById('accountInfo').classList.toggle('hidden', !authUser);
+    document.getElementById('accountName').textContent = authUser || '';
+    if (authUser) {
+        nameInput.value = authUser;
+    }
+    nameInput.disabled = !!authUser;
+}
+
+function clearAccount() {
+    authToken = null;
+    authUser = null;
+    localStorage.removeItem('authToken');
+    localStorage.removeItem('authUser');
+    updateAccountUI();
+}
+
+// 重新建立WebSocket连接（登录状态变化后用新的身份连接）
+function reconnectWebSocket() {
+    if (ws) {
+        ws.onclose = null;
+        ws.close(1000);
+    }
+    stopHeartbeat();
+    connectWebSocket();
+}
+
+// 登录或注册账号，用户名使用名字输入框
+async function submitAccount(action) {
+    const username = document.getElementById('playerName').value.trim();
+    const password = document.getElementById('accountPassword').value;
+    if (!username || !password) {
+        showError('请输入用户名（名字）和账号密码');
+        return;
+    }
+    try {
+        const response = await fetch(`/api/${action}`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ username, password })
+        });
+        const result = await response.json();
+        if (!response.ok) {
+            showError(result.error || (action === 'login' ? '登录失败' : '注册失败'));
+            return;
+        }
+        authToken = result.token;
+        authUser = result.username;
+        localStorage.setItem('authToken', authToken);
+        localStorage.setItem('authUser', authUser);
+        document.getElementById('accountPassword').value = '';
+        updateAccountUI();
+        reconnectWebSocket();
+    } catch (error) {
+        console.error('账号请求失败:', error);
+        showError('无法连接服务器');
+    }
+}
+
+async function logout() {
+    if (authToken) {
+        try {
+            await fetch('/api/logout', {
+                method: 'POST',
+                headers: { 'Authorization': `Bearer ${authToken}` }
+            });
+        } catch (error) {
+            console.error('退出登录失败:', error);
+        }
+    }
+    clearAccount();
+    reconnectWebSocket();
+}
+
 function joinGame() {
     console.log('=== 点击加入游戏按钮 ===');
     const playerName = document.getElementById('playerName').value.trim();
@@ -403,6 +716,16 @@ function joinGame() {
 
 function doJoinGame(playerName, roomId) {
     console.log('执行加入游戏:', { playerName, roomId });
+    const roomType = document.getElementById('roomType')?.value;
+    if (roomType === 'mtt') {
+        // 多桌锦标赛：填写赛事ID时报名，否则创建新赛事
+        if (roomId) {
+            sendMessage({ type: 'registerMTT', data: { mttId: roomId, playerName: playerName } });
+        } else {
+            sendMessage({ type: 'createMTT', data: { playerName: playerName, settings: { tournament: {} } } });
+        }
+        return;
+    }
     if (roomId) {
         // 加入现有房间
         console.log('加入现有房间:', roomId);
@@ -410,16 +733,31 @@ function doJoinGame(playerName, roomId) {
             type: 'joinRoom',
             data: {
                 roomId: roomId,
-                playerName: playerName
+                playerName: playerName,
+                password: document.getElementById('roomPassword')?.value || '',
+                invite: inviteToken || ''
             }
         });
     } else {
         // 创建新房间
         console.log('创建新房间');
+        const settings = {
+            visibility: document.getElementById('roomVisibility')?.value || 'public',
+            password: document.getElementById('roomPassword')?.value || ''
+        };
+        // 现金局买入限制（留空不限制）
+        const minBuyIn = parseInt(document.getElementById('minBuyIn')?.value, 10);
+        const maxBuyIn = parseInt(document.getElementById('maxBuyIn')?.value, 10);
+        if (minBuyIn > 0) settings.minBuyIn = minBuyIn;
+        if (maxBuyIn > 0) settings.maxBuyIn = maxBuyIn;
+        if (roomType === 'tournament') {
+            settings.tournament = {};
+        }
         sendMessage({
             type: 'createRoom',
             data: {
-                playerName: playerName
+                playerName: playerName,
+                settings: settings
             }
         });
     }
@@ -503,9 +841,106 @@ function handleMessage(message) {
 
     console.log('处理消息，类型:', message.type);
     switch (message.type) {
+        case 'hostChanged':
+            console.log(`房主变更为 ${message.data.name}`);
+            if (gameState) {
+                gameState.hostId = message.data.hostId;
+                updateGameState(gameState);
+            }
+            break;
+
+        case 'gamePaused':
+        case 'gameResumed':
+            console.log(message.type === 'gamePaused'
+                ? `${message.data.name} 暂停了游戏，当前回合剩余 ${message.data.turnTimeLeft} 秒`
+                : `${message.data.name} 继续了游戏`);
+            updateGameState(message.data.room);
+            break;
+
+        case 'settingsUpdated':
+            console.log(message.type, message.data);
+            updateGameState(message.data.room);
+            break;
+
+        case 'kicked':
+            alert(message.data.banned ? '你已被房主禁止进入该房间' : '你已被房主踢出房间');
+            returnToLogin();
+            break;
+
+        case 'waitlistUpdated':
+            if (gameState) {
+                gameState.waitlist = message.data.waitlist;
+                updateWaitlistInfo(gameState);
+            }
+            break;
+
+        case 'seatOffered':
+            if (confirm(`有空座位了，是否上桌？（${message.data.timeoutSeconds}秒内有效）`)) {
+                sendMessage({ type: 'acceptSeat', data: buyInRequest() });
+            } else {
+                sendMessage({ type: 'declineSeat', data: {} });
+            }
+            break;
+
+        case 'seatOfferExpired':
+            showError('没有及时接受座位，已移出等候名单');
+            break;
+
+        case 'roomClosed':
+            {
+                const balances = message.data.balances || {};
+                const playerName = document.getElementById('playerName').value.trim();
+                const chips = balances[playerName];
+                alert(chips !== undefined
+                    ? `房间已关闭：${message.data.reason}\n你的 ${chips} 筹码已存回钱包`
+                    : `房间已关闭：${message.data.reason}`);
+            }
+            returnToLogin();
+            if (message.data.report) {
+                showSessionReport(message.data.report);
+            }
+            break;
+
+        case 'overlayKey':
+            if (message.data.overlayUrl) {
+                copyToClipboard(`${window.location.origin}${message.data.overlayUrl}`);
+            }
+            break;
+
+        case 'inviteCreated':
+            shareLink(`${window.location.origin}${window.location.pathname}?room=${message.data.roomId}` +
+                `&invite=${encodeURIComponent(message.data.token)}`);
+            break;
+
+        case 'leaderboard':
+        case 'leaderboardUpdated':
+            updateLeaderboard(message.data.leaderboard);
+            break;
+
+        case 'chat':
+            appendChat(message.data.message);
+            break;
+
+        case 'chatHistory':
+            document.getElementById('chatMessages').innerHTML = '';
+            (message.data.messages || []).forEach(appendChat);
+            break;
+
+        case 'playerMuted':
+            appendChatNotice(message.data.muted
+                ? `${message.data.name} 已被房主禁言`
+                : `${message.data.name} 已被解除禁言`);
+            break;
+
+        case 'lobbyRooms':
+        case 'lobbyUpdated':
+            updateLobbyRooms(message.data.rooms);
+            break;
+
         case 'roomCreated':
             console.log('✅ 收到房间创建消息:', message.data);
             currentRoom = message.data.roomId;
+            loadChatHistory();
             console.log('设置房间ID:', currentRoom);
             // 更新房间ID显示
             updateRoomIdDisplay(currentRoom);
@@ -537,6 +972,7 @@ function handleMessage(message) {
 
         case 'roomJoined':
             currentRoom = message.data.room.id;
+            loadChatHistory();
             // 更新房间ID显示
             updateRoomIdDisplay(currentRoom);
             
@@ -620,6 +1056,28 @@ function handleMessage(message) {
             }
             break;
             
+        case 'standUpPending':
+            showError(message.data.pending ? '本局结束后离桌' : '已取消离桌');
+            document.getElementById('standUpBtn').textContent = message.data.pending ? '取消离桌' : '离桌兑现';
+            break;
+
+        case 'playerStoodUp':
+            console.log('玩家离桌', message.data);
+            document.getElementById('standUpBtn').textContent = '离桌兑现';
+            if (message.data.name === document.getElementById('playerName').value.trim()) {
+                const cashOut = message.data.cashOut;
+                alert(`已离桌，筹码 ${cashOut.chips}，累计买入 ${cashOut.buyIn}，输赢 ${cashOut.net >= 0 ? '+' : ''}${cashOut.net}`);
+                isSpectating = true;
+                updateGameState(message.data.room);
+                showSpectatingPanel(message.data.room);
+            } else {
+                updateGameState(message.data.room);
+                if (isSpectating) {
+                    showSpectatingPanel(message.data.room);
+                }
+            }
+            break;
+
         case 'playerMovedToSpectating':
             console.log('玩家被移入观战状态');
             isSpectating = true;
@@ -812,10 +1270,173 @@ function handleMessage(message) {
 
         case 'gameEnded':
             showSettlement(message.data);
+            // 无人跟注赢得底池时，获胜者可以选择亮牌
+            if (message.data.uncontested && currentPlayer && message.data.winner && message.data.winner.id === currentPlayer.id) {
+                setTimeout(() => {
+                    if (confirm('其他玩家都已弃牌，是否亮出你的手牌？')) {
+                        sendMessage({ type: 'showCards', data: {} });
+                    }
+                }, 500);
+            }
+            break;
+
+        case 'blindLevelUp':
+            console.log('盲注升级:', message.data.message);
+            if (gameState && gameState.tournament) {
+                gameState.tournament.level = message.data.level - 1;
+                gameState.tournament.levelEndsAt = message.data.levelEndsAt;
+                updateTournamentInfo(gameState.tournament);
+            }
+            break;
+
+        case 'playerEliminated':
+            console.log(`玩家 ${message.data.name} 被淘汰`, message.data);
+            if (currentPlayer && message.data.playerId === currentPlayer.id) {
+                if (message.data.canRebuy && confirm('你的筹码已输光，是否重购？')) {
+                    sendMessage({ type: 'buyHand', data: {} });
+                } else if (!message.data.canRebuy) {
+                    alert('你已被淘汰');
+                }
+            }
+            break;
+
+        case 'tournamentFinished':
+            showTournamentResults(message.data);
+            break;
+
+        case 'dealQuote':
+            proposeDealFromQuote(message.data);
+            break;
+
+        case 'dealProposed':
+            // 剩余玩家确认是否同意协议
+            if (currentPlayer && message.data.deal.offers.some(o => o.playerId === currentPlayer.id) &&
+                !message.data.deal.accepted[currentPlayer.id]) {
+                const accept = confirm(`${message.data.name} 提议分配剩余奖金 ${message.data.deal.remainingPool}：\n` +
+                    formatDealOffers(message.data.deal.offers) + '\n是否同意？');
+                sendMessage({ type: 'respondDeal', data: { accept: accept } });
+            }
+            break;
+
+        case 'dealAccepted':
+            console.log(`玩家 ${message.data.name} 同意奖金分配协议`, message.data.deal);
+            break;
+
+        case 'dealRejected':
+            alert(`${message.data.name} 拒绝了奖金分配协议`);
+            break;
+
+        case 'mttCreated':
+            // 创建者自动报名自己创建的赛事
+            currentMTT = message.data.mtt.id;
+            document.getElementById('startMTTBtn').classList.remove('hidden');
+            sendMessage({
+                type: 'registerMTT',
+                data: { mttId: currentMTT, playerName: document.getElementById('playerName').value.trim() }
+            });
+            updateMTTInfo(message.data.mtt);
+            break;
+
+        case 'mttRegistered':
+            currentMTT = message.data.mtt.id;
+            document.getElementById('unregisterMTTBtn').classList.remove('hidden');
+            updateMTTInfo(message.data.mtt);
+            break;
+
+        case 'mttUnregistered':
+            if (message.data.left) {
+                // 自己退出报名
+                currentMTT = null;
+                document.getElementById('startMTTBtn').classList.add('hidden');
+                document.getElementById('unregisterMTTBtn').classList.add('hidden');
+                document.getElementById('mttInfo').classList.add('hidden');
+            } else {
+                updateMTTInfo(message.data.mtt);
+            }
+            break;
+
+        case 'mttStarted':
+        case 'tableMoved':
+            // 被分配（或平衡移动）到牌桌
+            if (message.type === 'tableMoved') {
+                console.log(`换桌: ${message.data.fromRoomId} -> ${message.data.roomId}`);
+            }
+            currentRoom = message.data.roomId;
+            isSpectating = false;
+            updateRoomIdDisplay(currentRoom);
+            if (message.data.room && message.data.room.players) {
+                const playerName = document.getElementById('playerName').value.trim();
+                currentPlayer = message.data.room.players.find(p => p.name === playerName) || currentPlayer;
+            }
+            updateGameState(message.data.room);
+            showScreen('gameScreen');
+            hideSpectatingPanel();
+            break;
+
+        case 'handForHand':
+            console.log(message.data.enabled ? '进入泡沫期，逐手同步发牌' : '逐手同步结束');
+            break;
+
+        case 'mttEliminated':
+            alert(`你已被淘汰，第 ${message.data.place} 名`);
+            break;
+
+        case 'mttFinished':
+            showTournamentResults(message.data);
+            break;
+
+        case 'bombPotScheduled':
+        case 'bombPotCancelled':
+            console.log('炸弹底池:', message.data);
+            if (gameState) {
+                gameState.nextBombPot = message.type === 'bombPotScheduled'
+                    ? { ante: message.data.ante, doubleBoard: message.data.doubleBoard }
+                    : null;
+                updateGameState(gameState);
+            }
+            break;
+
+        case 'rabbitRevealed':
+            // 显示本来会发出的公共牌（不影响结果）
+            console.log(`玩家 ${message.data.name} 查看兔子牌:`, message.data.rabbitCards);
+            document.getElementById('rabbitHuntBtn')?.classList.add('hidden');
+            if (isSettlement && Array.isArray(message.data.board)) {
+                updateCommunityCards(message.data.board);
+            }
+            break;
+
+        case 'cardsShown':
+            // 无人跟注的获胜者选择亮牌
+            console.log(`玩家 ${message.data.name} 亮出手牌:`, message.data.hand);
+            showShownCards(message.data);
+            break;
+
+        case 'allInEquity':
+            // 全押后逐张亮牌，显示每个玩家的胜率
+            showAllInEquity(message.data);
+            break;
+
+        case 'runItTwiceOffer':
+            // 所有玩家全押，询问是否多次发牌
+            if (currentPlayer && message.data.playerIds && message.data.playerIds.includes(currentPlayer.id)) {
+                const maxRuns = message.data.maxRuns || 2;
+                const agree = confirm(`所有玩家已全押，是否同意发牌 ${maxRuns} 次？`);
+                sendMessage({
+                    type: 'runItTwice',
+                    data: { runs: agree ? maxRuns : 1 }
+                });
+            }
+            break;
+
+        case 'walletUpdated':
+            updateWalletBalance(message.data.balance);
             break;
 
         case 'buyHandSuccess':
             console.log('✅ 买一手成功，新筹码:', message.data.chips);
+            if (message.data && message.data.wallet !== undefined) {
+                updateWalletBalance(message.data.wallet);
+            }
             if (message.data && message.data.chips !== undefined) {
                 const playerChipsEl = document.getElementById('playerChips');
                 const playerChipsWaitingEl = document.getElementById('playerChipsWaiting');
@@ -828,6 +1449,14 @@ function handleMessage(message) {
             }
             break;
             
+        case 'stats':
+            showPlayerStats(message.data.stats);
+            break;
+
+        case 'sessionReport':
+            showSessionReport(message.data.report);
+            break;
+
         case 'buyHandStats':
             console.log('收到买一手统计:', message.data);
             showBuyHandStats(message.data.stats);
@@ -869,6 +1498,33 @@ function updateLobby(data) {
     });
 }
 
+function showAllInEquity(data) {
+    if (!data || !data.equity) return;
+    data.equity.forEach(e => {
+        const seat = document.querySelector(`.player-seat[data-player-id="${e.playerId}"]`);
+        if (!seat) return;
+        let badge = seat.querySelector('.equity-badge');
+        if (!badge) {
+            badge = document.createElement('div');
+            badge.className = 'equity-badge';
+            seat.appendChild(badge);
+        }
+        const runLabel = data.runs > 1 ? `第${data.run}次 ` : '';
+        const tieLabel = e.tie > 0 ? ` 平${e.tie.toFixed(1)}%` : '';
+        badge.textContent = `${runLabel}胜${e.win.toFixed(1)}%${tieLabel}${data.exact ? '' : ' (估算)'}`;
+    });
+}
+
+function showShownCards(data) {
+    if (!data || !Array.isArray(data.hand)) return;
+    const seat = document.querySelector(`.player-seat[data-player-id="${data.playerId}"]`);
+    if (!seat) return;
+    const cardsEl = seat.querySelector('.player-seat-cards');
+    if (cardsEl) {
+        cardsEl.innerHTML = data.hand.map(card => createCardHTML(card)).join('');
+    }
+}
+
 function updateRoomIdDisplay(roomId) {
     const roomIdElement = document.getElementById('gameRoomId');
     if (roomIdElement && roomId) {
@@ -892,6 +1548,11 @@ function updateGameState(room) {
     // 更新底池和当前下注
     document.getElementById('potAmount').textContent = room.pot || 0;
     document.getElementById('currentBet').textContent = room.currentBet || 0;
+    if (room.bigBlind) {
+        document.getElementById('blindsInfo').textContent =
+            `${room.smallBlind}/${room.bigBlind}` + (room.ante ? ` 前注${room.ante}` : '');
+    }
+    updateTournamentInfo(room.tournament);
 
     // 更新游戏阶段
     const phaseNames = {
@@ -902,13 +1563,31 @@ function updateGameState(room) {
         'showdown': '比牌',
         'waiting': '等待开始'
     };
-    document.getElementById('gamePhase').textContent = phaseNames[room.gamePhase] || room.gamePhase;
+    let phaseText = phaseNames[room.gamePhase] || room.gamePhase;
+    if (room.bombPot && room.gamePhase !== 'waiting') {
+        phaseText += room.bombPot.doubleBoard ? ' · 炸弹底池（双公共牌）' : ' · 炸弹底池';
+    } else if (room.nextBombPot) {
+        phaseText += ' · 下一局炸弹底池';
+    }
+    document.getElementById('gamePhase').textContent = phaseText;
+    updateExtraBoards(room.runBoards);
     
-    // 显示/隐藏开始游戏按钮（游戏界面中的）
+    // 房主管理面板
+    const isHost = isRoomHost(room);
+    const hostPanel = document.getElementById('hostPanel');
+    if (hostPanel) {
+        hostPanel.classList.toggle('hidden', !isHost);
+        document.getElementById('pauseGameBtn').textContent = room.paused ? '继续' : '暂停';
+    }
+    if (room.paused) {
+        document.getElementById('gamePhase').textContent += ' · 已暂停';
+    }
+
+    // 显示/隐藏开始游戏按钮（游戏界面中的，只有房主可以开始）
     const startGamePanel = document.getElementById('startGamePanel');
     const startGameBtnInGame = document.getElementById('startGameBtnInGame');
     if (startGamePanel && startGameBtnInGame) {
-        if (room.gamePhase === 'waiting' && room.players && room.players.length >= 4) {
+        if (isHost && room.gamePhase === 'waiting' && room.players && room.players.length >= 4) {
             startGamePanel.classList.remove('hidden');
         } else {
             startGamePanel.classList.add('hidden');
@@ -926,18 +1605,6 @@ function updateGameState(room) {
     // 如果是新游戏开始且不是结算状态，会清空玩家手牌显示
     updatePlayersArea(room.players || [], room.currentTurn, room.dealerIndex);
 
-    // 如果玩家在观战状态，更新观战面板的筹码显示
-    if (isSpectating && room.spectators) {
-        const playerName = document.getElementById('playerName')?.value.trim();
-        const spectator = room.spectators.find(p => p && p.name === playerName);
-        if (spectator) {
-            const chipsEl = document.getElementById('playerChipsSpectating');
-            if (chipsEl) {
-                chipsEl.textContent = spectator.chips || 500;
-            }
-        }
-    }
-
     // 更新当前玩家信息
     if (room.players) {
         // 如果没有currentPlayer，尝试通过名字找到
@@ -1028,6 +1695,207 @@ function updateCommunityCards(cards) {
     }
 }
 
+// 显示第二套及以后的公共牌（第一套在公共牌区域显示）
+function updateExtraBoards(boards) {
+    const container = document.getElementById('extraBoards');
+    if (!container) return;
+    if (!Array.isArray(boards) || boards.length < 2) {
+        container.innerHTML = '';
+        container.classList.add('hidden');
+        return;
+    }
+    container.innerHTML = boards.slice(1).map((board, i) => `
+        <div class="extra-board">
+            <span class="extra-board-label">第${i + 2}套</span>
+            ${(board || []).map(card => createCardHTML(card)).join('')}
+        </div>
+    `).join('');
+    container.classList.remove('hidden');
+}
+
+// 显示锦标赛级别和奖池
+function updateTournamentInfo(tournament) {
+    const el = document.getElementById('tournamentInfo');
+    if (!el) return;
+    if (!tournament) {
+        el.classList.add('hidden');
+        return;
+    }
+    const dealBtn = document.getElementById('dealBtn');
+    if (dealBtn) {
+        dealBtn.classList.toggle('hidden', !(tournament.started && !tournament.finished));
+    }
+    let text = `锦标赛 奖池: ${tournament.prizePool}`;
+    if (tournament.started && !tournament.finished) {
+        const seconds = Math.max(0, Math.round((new Date(tournament.levelEndsAt) - Date.now()) / 1000));
+        text += ` · 第${tournament.level + 1}级 · ${Math.floor(seconds / 60)}:${String(seconds % 60).padStart(2, '0')}后升级`;
+    } else if (tournament.finished) {
+        text += ' · 已结束';
+    }
+    el.textContent = text;
+    el.classList.remove('hidden');
+}
+
+// 当前玩家是否是房主（按昵称在房间中查找自己，同一房间内昵称不重复）
+function isRoomHost(room) {
+    if (!room || !room.hostId) return false;
+    const playerName = document.getElementById('playerName').value.trim();
+    const members = [].concat(room.players || [], room.spectators || [], room.waitingPlayers || []);
+    const me = members.find(p => p.name === playerName);
+    return !!me && me.id === room.hostId;
+}
+
+// 房主对某个玩家执行命令（输入昵称）
+function hostCommandOnPlayer(type, verb) {
+    if (!gameState) return;
+    const name = prompt(`${verb}哪位玩家？输入昵称：`);
+    if (!name) return;
+    const members = [].concat(gameState.players || [], gameState.spectators || [], gameState.waitingPlayers || []);
+    const target = members.find(p => p.name === name.trim());
+    if (!target) {
+        showError('房间中没有该玩家');
+        return;
+    }
+    sendMessage({ type: type, data: { playerId: target.id } });
+}
+
+// 进入房间后获取最近的聊天记录
+function loadChatHistory() {
+    document.getElementById('chatMessages').innerHTML = '';
+    sendMessage({ type: 'getChatHistory', data: {} });
+}
+
+function sendChat() {
+    const input = document.getElementById('chatInput');
+    const text = input.value.trim();
+    if (!text || !currentRoom) return;
+    sendMessage({ type: 'chat', data: { text } });
+    input.value = '';
+}
+
+function appendChat(chat) {
+    const el = document.getElementById('chatMessages');
+    const item = document.createElement('div');
+    item.className = 'chat-message' + (chat.channel === 'spectators' ? ' chat-spectators' : '');
+    const time = new Date(chat.at).toLocaleTimeString();
+    item.textContent = `[${time}] ${chat.channel === 'spectators' ? '(观战) ' : ''}${chat.name}: ${chat.text}`;
+    el.appendChild(item);
+    el.scrollTop = el.scrollHeight;
+}
+
+function appendChatNotice(text) {
+    const el = document.getElementById('chatMessages');
+    const item = document.createElement('div');
+    item.className = 'chat-message chat-notice';
+    item.textContent = text;
+    el.appendChild(item);
+    el.scrollTop = el.scrollHeight;
+}
+
+// 被踢出或房间关闭后回到登录界面
+function returnToLogin() {
+    currentRoom = null;
+    currentPlayer = null;
+    gameState = null;
+    isSpectating = false;
+    updateRoomIdDisplay('');
+    showScreen('loginScreen');
+    sendMessage({ type: 'subscribeLobby', data: {} });
+}
+
+function updateLobbyRooms(rooms) {
+    const el = document.getElementById('lobbyRooms');
+    if (!el) return;
+    // 进入房间后不再需要大厅更新
+    if (currentRoom) {
+        sendMessage({ type: 'unsubscribeLobby', data: {} });
+        return;
+    }
+    const typeNames = { cash: '现金局', tournament: '锦标赛', mtt: '多桌锦标赛' };
+    el.innerHTML = '';
+    (rooms || []).forEach(room => {
+        const item = document.createElement('div');
+        item.className = 'lobby-room';
+        item.textContent = `${room.id} · ${typeNames[room.gameType] || room.gameType} · ${room.smallBlind}/${room.bigBlind}` +
+            (room.password ? ' · 🔒' : '') + ` · ${room.seated}/${room.maxPlayers}人` + (room.waiting > 0 ? ` · 等待${room.waiting}人` : '') +
+            ` · 平均底池${room.averagePot} · ${room.phase}`;
+        item.addEventListener('click', () => {
+            document.getElementById('roomId').value = room.id;
+        });
+        el.appendChild(item);
+    });
+}
+
+// 订阅排行榜（切换时间范围时重新订阅）
+function subscribeLeaderboard() {
+    const period = document.getElementById('leaderboardPeriod').value;
+    sendMessage({ type: 'subscribeLeaderboard', data: { period, limit: 5 } });
+}
+
+function updateLeaderboard(leaderboard) {
+    const el = document.getElementById('leaderboardList');
+    if (!el || !leaderboard) return;
+    // 进入房间后不再需要排行榜更新
+    if (currentRoom) {
+        sendMessage({ type: 'unsubscribeLeaderboard', data: {} });
+        return;
+    }
+    const boards = [
+        ['净赢筹码', leaderboard.netChips],
+        ['最大底池', leaderboard.biggestPot],
+        ['锦标赛积分', leaderboard.tournamentPoints]
+    ];
+    el.innerHTML = '';
+    boards.forEach(([title, entries]) => {
+        const item = document.createElement('div');
+        item.className = 'lobby-room';
+        item.textContent = `${title}：` + ((entries || []).length > 0
+            ? entries.map(e => `${e.rank}. ${e.name} ${e.value}`).join('  ')
+            : '暂无');
+        el.appendChild(item);
+    });
+}
+
+function updateMTTInfo(mtt) {
+    const mttInfo = document.getElementById('mttInfo');
+    if (!mttInfo || !mtt) return;
+    mttInfo.textContent = `赛事ID: ${mtt.id}，已报名 ${mtt.registrants.length} 人：${mtt.registrants.join('、')}`;
+    mttInfo.classList.remove('hidden');
+}
+
+function showTournamentResults(data) {
+    const lines = (data.results || []).map(r => `第${r.place}名 ${r.name}` + (r.prize > 0 ? ` 奖金 ${r.prize}` : '') + (r.deal ? '（协议）' : ''));
+    alert(`锦标赛结束（奖池 ${data.prizePool}）\n` + lines.join('\n'));
+}
+
+function formatDealOffers(offers) {
+    return offers.map(o => `${o.name}（筹码 ${o.chips}）: ${o.payout}`).join('\n');
+}
+
+function proposeDealFromQuote(quote) {
+    const method = prompt(`剩余奖金 ${quote.remainingPool}\n` +
+        `ICM:\n${formatDealOffers(quote.icm)}\n` +
+        `按筹码比例:\n${formatDealOffers(quote.chipChop)}\n` +
+        '输入 icm 或 chipchop 发起协议（取消则不发起）：', 'icm');
+    if (method === null) return;
+    sendMessage({ type: 'proposeDeal', data: { method: method.trim().toLowerCase() } });
+}
+
+function scheduleBombPot() {
+    const anteInput = prompt('下一局炸弹底池，每人前注：', '20');
+    if (anteInput === null) return;
+    const ante = parseInt(anteInput, 10);
+    if (!(ante > 0)) {
+        alert('前注必须大于0');
+        return;
+    }
+    const doubleBoard = confirm('是否发两套公共牌（每套赢一半底池）？');
+    sendMessage({
+        type: 'scheduleBombPot',
+        data: { ante: ante, doubleBoard: doubleBoard }
+    });
+}
+
 function updatePlayersArea(players, currentTurn, dealerIndex) {
     const playersArea = document.getElementById('playersArea');
     if (!playersArea) return;
@@ -1065,6 +1933,7 @@ function updatePlayersArea(players, currentTurn, dealerIndex) {
         if (!player) return;
         const seat = document.createElement('div');
         seat.className = 'player-seat';
+        seat.dataset.playerId = player.id;
         
         // 设置位置
         if (positions[index]) {
@@ -1109,9 +1978,8 @@ function updatePlayersArea(players, currentTurn, dealerIndex) {
         if (isSettlement && settlementData && settlementData.allHands) {
             const handData = settlementData.allHands.find(h => h && h.id === player.id);
             if (handData) {
-                if (Array.isArray(handData.hand)) {
-                    playerHand = handData.hand;
-                }
+                // 盖牌的玩家不包含手牌，不显示
+                playerHand = Array.isArray(handData.hand) ? handData.hand : [];
                 // 结算时使用settlementData中的folded状态
                 isFoldedInSettlement = handData.folded || false;
             }
@@ -1340,8 +2208,8 @@ function updatePlayerInfo(player, room) {
         actionPanel.classList.remove('hidden');
         waitingPanel.classList.add('hidden');
         
-        // 启动倒计时
-        startTurnTimer();
+        // 启动倒计时（按服务端剩余时间，暂停时停在剩余时间）
+        startTurnTimer(room.turnTimeLeft, room.paused);
         
         // 计算需要跟注的金额
         const callAmount = room.currentBet - player.bet;
@@ -1423,16 +2291,18 @@ function updatePlayerInfo(player, room) {
 }
 
 // 启动回合倒计时
-function startTurnTimer() {
+function startTurnTimer(seconds, paused) {
     // 清除之前的定时器
     stopTurnTimer();
     
     const timerDisplay = document.getElementById('timerCountdown');
     if (!timerDisplay) return;
     
-    let timeLeft = 60; // 60秒
+    let timeLeft = seconds > 0 ? seconds : 60; // 默认60秒
     timerDisplay.textContent = timeLeft;
     timerDisplay.className = 'timer-countdown';
+    // 暂停时只显示剩余时间，不倒计时
+    if (paused) return;
     
     // 更新倒计时显示
     turnTimer = setInterval(() => {
@@ -1525,6 +2395,12 @@ function showSettlement(data) {
         handEl.style.display = 'none';
     }
     
+    // 无人跟注结束且房间开启了兔子牌时，显示查看兔子牌按钮
+    const rabbitBtn = document.getElementById('rabbitHuntBtn');
+    if (rabbitBtn) {
+        rabbitBtn.classList.toggle('hidden', !data.rabbitHunt);
+    }
+
     // 更新公共牌显示（确保显示所有公共牌）
     // 优先使用gameEnded消息中的公共牌数据
     if (data.communityCards && Array.isArray(data.communityCards)) {
@@ -1624,9 +2500,16 @@ function shareRoom() {
         return;
     }
     
-    // 生成分享链接
-    const shareUrl = `${window.location.origin}${window.location.pathname}?room=${currentRoom}`;
-    
+    // 私密房间和有密码的房间分享带邀请令牌的链接
+    if (gameState && (gameState.hasPassword || (gameState.settings && gameState.settings.visibility === 'private'))) {
+        sendMessage({ type: 'createInvite', data: {} });
+        return;
+    }
+
+    shareLink(`${window.location.origin}${window.location.pathname}?room=${currentRoom}`);
+}
+
+function shareLink(shareUrl) {
     // 尝试使用Web Share API（移动端）
     if (navigator.share) {
         navigator.share({
@@ -1679,6 +2562,7 @@ function fallbackCopyToClipboard(text) {
 function checkUrlParams() {
     const urlParams = new URLSearchParams(window.location.search);
     const roomId = urlParams.get('room');
+    inviteToken = urlParams.get('invite');
     if (roomId) {
         const roomIdInput = document.getElementById('roomId');
         if (roomIdInput) {
@@ -1795,10 +2679,23 @@ function joinTable() {
     
     ws.send(JSON.stringify({
         type: 'joinTable',
-        data: {}
+        data: buyInRequest()
     }));
 }
 
+// 上桌时从钱包买入的筹码（不填时服务端使用默认买入）
+function buyInRequest() {
+    const amount = parseInt(document.getElementById('buyInAmount')?.value, 10);
+    return amount > 0 ? { amount } : {};
+}
+
+function updateWalletBalance(balance) {
+    const walletEl = document.getElementById('walletBalance');
+    if (walletEl) {
+        walletEl.textContent = balance;
+    }
+}
+
 // 显示观战面板
 function showSpectatingPanel(room) {
     const spectatingPanel = document.getElementById('spectatingPanel');
@@ -1807,18 +2704,8 @@ function showSpectatingPanel(room) {
     
     if (spectatingPanel) {
         spectatingPanel.classList.remove('hidden');
-        
-        // 更新筹码显示
-        const playerName = document.getElementById('playerName')?.value.trim();
-        if (room && room.spectators) {
-            const spectator = room.spectators.find(p => p && p.name === playerName);
-            if (spectator) {
-                const chipsEl = document.getElementById('playerChipsSpectating');
-                if (chipsEl) {
-                    chipsEl.textContent = spectator.chips || 500;
-                }
-            }
-        }
+        updateWaitlistInfo(room);
+        updateSpectatorDelayInfo(room);
     }
     
     if (actionPanel) {
@@ -1836,6 +2723,45 @@ function showSpectatingPanel(room) {
     if (handCard1) handCard1.innerHTML = '';
 }
 
+// 观战延迟提示（画面比实际牌局晚几秒）
+function updateSpectatorDelayInfo(room) {
+    const el = document.getElementById('spectatorDelayInfo');
+    if (!el) return;
+    const settings = (room && room.settings) || {};
+    if (settings.spectatorDelay > 0) {
+        el.textContent = settings.spectatorMode === 'omniscient'
+            ? `观战画面延迟 ${settings.spectatorDelay} 秒，可以看到所有人的手牌`
+            : `观战画面延迟 ${settings.spectatorDelay} 秒`;
+    } else {
+        el.textContent = '';
+    }
+}
+
+// 当前玩家在等候名单中的位置（不在名单中时为null）
+function myWaitlistPosition(room) {
+    if (!room || !room.waitlist) return null;
+    const playerName = document.getElementById('playerName').value.trim();
+    const entry = room.waitlist.find(e => e.name === playerName);
+    return entry || null;
+}
+
+// 更新等候名单显示
+function updateWaitlistInfo(room) {
+    const info = document.getElementById('waitlistInfo');
+    const btn = document.getElementById('waitlistBtn');
+    if (!info || !btn) return;
+    const entry = myWaitlistPosition(room);
+    const total = room && room.waitlist ? room.waitlist.length : 0;
+    btn.textContent = entry ? '离开等候名单' : '加入等候名单';
+    if (entry) {
+        info.textContent = entry.offered
+            ? '已为你保留座位，请尽快接受'
+            : `你在等候名单第 ${entry.position} 位（共 ${total} 人）`;
+    } else {
+        info.textContent = total > 0 ? `等候名单中有 ${total} 人` : '';
+    }
+}
+
 // 隐藏观战面板
 function hideSpectatingPanel() {
     const spectatingPanel = document.getElementById('spectatingPanel');
@@ -1845,6 +2771,58 @@ function hideSpectatingPanel() {
 }
 
 // 显示买一手统计
+function requestStats(scope) {
+    sendMessage({ type: 'getStats', data: { scope } });
+}
+
+// 显示玩家统计（VPIP、PFR等都是百分比）
+function showPlayerStats(stats) {
+    const list = document.getElementById('playerStatsList');
+    list.innerHTML = '';
+    if (!stats || stats.length === 0) {
+        list.innerHTML = '<tr><td colspan="9">暂无统计数据</td></tr>';
+    }
+    (stats || []).forEach(s => {
+        const row = document.createElement('tr');
+        [s.name, s.hands, `${s.vpip}%`, `${s.pfr}%`, `${s.threeBet}%`, s.aggressionFactor,
+            `${s.wtsd}%`, `${s.wsd}%`, s.bbPer100].forEach(value => {
+            const cell = document.createElement('td');
+            cell.textContent = value;
+            row.appendChild(cell);
+        });
+        list.appendChild(row);
+    });
+    document.getElementById('playerStatsModal').classList.remove('hidden');
+}
+
+// 显示本场结算：每个玩家的买入和输赢，以及谁付给谁的结算建议
+function showSessionReport(report) {
+    const list = document.getElementById('sessionReportList');
+    list.innerHTML = '';
+    if (!report.players || report.players.length === 0) {
+        list.innerHTML = '<tr><td colspan="7">暂无结算数据</td></tr>';
+    }
+    (report.players || []).forEach(p => {
+        const row = document.createElement('tr');
+        [p.name, p.boughtIn, p.seated ? p.chips : '已离桌', p.net > 0 ? `+${p.net}` : p.net,
+            p.hands, p.biggestWin, p.biggestLoss].forEach(value => {
+            const cell = document.createElement('td');
+            cell.textContent = value;
+            row.appendChild(cell);
+        });
+        list.appendChild(row);
+    });
+
+    const settlements = document.getElementById('sessionSettlements');
+    settlements.innerHTML = '';
+    (report.settlements || []).forEach(s => {
+        const item = document.createElement('li');
+        item.textContent = `${s.from} 付给 ${s.to} ${s.amount}`;
+        settlements.appendChild(item);
+    });
+    document.getElementById('sessionReportModal').classList.remove('hidden');
+}
+
 function showBuyHandStats(stats) {
     const modal = document.getElementById('buyHandStatsModal');
     const statsList = document.getElementById('buyHandStatsList');
@@ -0,0 +1,173 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+const (
+	DEFAULT_BOMB_POT_ANTE    = 20 // 炸弹底池默认前注
+	MAX_BOMB_POT_ANTE_BLINDS = 10 // 炸弹底池的前注最多为大盲注的倍数
+)
+
+// 炸弹底池：所有玩家下前注，没有翻牌前下注，直接从翻牌开始
+type BombPot struct {
+	Ante        int  `json:"ante"`        // 每个玩家的前注
+	DoubleBoard bool `json:"doubleBoard"` // 是否发两套公共牌，每套赢一半底池
+}
+
+// 开始一局炸弹底池：收取前注并发出翻牌（调用者需持有写锁）
+func (room *GameRoom) startBombPot() error {
+	bomb := room.BombPot
+
+	for _, p := range room.Players {
+		p.IsSmall = false
+		p.IsBig = false
+
+		room.postForcedBet(p, bomb.Ante, "ante", false)
+	}
+
+	// 发翻牌（双公共牌时再发第二套翻牌）
+	room.CommunityCards = []Card{}
+	if err := dealCards(room, &room.CommunityCards, 3); err != nil {
+		return err
+	}
+	room.GamePhase = "flop"
+	room.recordStreet()
+	if bomb.DoubleBoard {
+		second := []Card{}
+		if err := dealCards(room, &second, 3); err != nil {
+			return err
+		}
+		room.RunBoards = [][]Card{append([]Card{}, room.CommunityCards...), second}
+		room.recordSecondBoard()
+	}
+
+	// 和翻牌后一样从小盲注位置（庄家下一位）开始下注
+	room.CurrentBet = 0
+	room.LastRaiseIndex = -1
+	room.LastAggressorID = ""
+	smallBlindIndex := (room.DealerIndex + 1) % len(room.Players)
+	room.CurrentTurn = smallBlindIndex
+	room.BettingStartIndex = smallBlindIndex
+	return nil
+}
+
+// 双公共牌的炸弹底池在转牌和河牌时给第二套公共牌也发一张（调用者需持有写锁）
+func (room *GameRoom) dealSecondBoard() error {
+	if room.BombPot == nil || !room.BombPot.DoubleBoard || len(room.RunBoards) != 2 {
+		return nil
+	}
+	if err := dealCards(room, &room.RunBoards[1], 1); err != nil {
+		return err
+	}
+	room.RunBoards[0] = append([]Card{}, room.CommunityCards...)
+	room.recordSecondBoard()
+	return nil
+}
+
+// 记录第二套公共牌（调用者需持有写锁）
+func (room *GameRoom) recordSecondBoard() {
+	room.recordHandEvent(HandEvent{
+		Type:    HandEventStreet,
+		Cards:   append([]Card{}, room.RunBoards[1]...),
+		Message: "第二套公共牌",
+	})
+}
+
+// 从牌组发n张牌到board（调用者需持有写锁）
+func dealCards(room *GameRoom, board *[]Card, n int) error {
+	for i := 0; i < n; i++ {
+		card, err := drawCard(&room.Deck)
+		if err != nil {
+			return err
+		}
+		*board = append(*board, card)
+	}
+	return nil
+}
+
+// 预约下一局为炸弹底池（再次发送cancel为true可以取消）
+func scheduleBombPot(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	bomb := &BombPot{Ante: DEFAULT_BOMB_POT_ANTE}
+	cancel := false
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		if ante, ok := data["ante"].(float64); ok {
+			bomb.Ante = int(ante)
+		}
+		if doubleBoard, ok := data["doubleBoard"].(bool); ok {
+			bomb.DoubleBoard = doubleBoard
+		}
+		if c, ok := data["cancel"].(bool); ok {
+			cancel = c
+		}
+	}
+	if !cancel && bomb.Ante <= 0 {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "炸弹底池的前注必须大于0"},
+		})
+		return
+	}
+
+	room.Mutex.Lock()
+	if err := room.requireHost(player); err != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+	if maxAnte := MAX_BOMB_POT_ANTE_BLINDS * room.BigBlind; !cancel && bomb.Ante > maxAnte {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": fmt.Sprintf("炸弹底池的前注最多为大盲注的%d倍（%d）", MAX_BOMB_POT_ANTE_BLINDS, maxAnte)},
+		})
+		return
+	}
+	if cancel {
+		room.NextBombPot = nil
+	} else {
+		room.NextBombPot = bomb
+	}
+	recipients := room.recipients()
+	room.Mutex.Unlock()
+
+	if cancel {
+		log.Printf("玩家 %s 取消了炸弹底池，房间 %s", player.Name, room.ID)
+		sendToPlayers(recipients, Message{
+			Type: "bombPotCancelled",
+			Data: map[string]interface{}{
+				"playerId": player.ID,
+				"name":     player.Name,
+			},
+		})
+		return
+	}
+
+	log.Printf("玩家 %s 预约下一局为炸弹底池，前注 %d，双公共牌: %v，房间 %s", player.Name, bomb.Ante, bomb.DoubleBoard, room.ID)
+	sendToPlayers(recipients, Message{
+		Type: "bombPotScheduled",
+		Data: map[string]interface{}{
+			"playerId":    player.ID,
+			"name":        player.Name,
+			"ante":        bomb.Ante,
+			"doubleBoard": bomb.DoubleBoard,
+			"message":     fmt.Sprintf("下一局为炸弹底池，每人前注 %d", bomb.Ante),
+		},
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+)
+
+// 测试炸弹底池收取前注并直接发出两套翻牌
+func TestStartBombPot(t *testing.T) {
+	players := []*Player{
+		{ID: "a", Name: "Alice", Chips: 500},
+		{ID: "b", Name: "Bob", Chips: 500},
+		{ID: "c", Name: "Carol", Chips: 15},
+		{ID: "d", Name: "Dave", Chips: 500},
+	}
+	room := &GameRoom{
+		ID:          "test_bomb_pot",
+		Players:     players,
+		Deck:        createDeck(),
+		DealerIndex: 0,
+		BombPot:     &BombPot{Ante: 20, DoubleBoard: true},
+	}
+
+	if err := room.startBombPot(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if room.noActionLeft() {
+		t.Error("Three players can still act after the antes")
+	}
+	if room.Pot != 75 {
+		t.Errorf("Expected pot 75 (3x20 + 15 all-in), got %d", room.Pot)
+	}
+	if !players[2].AllIn || players[2].Chips != 0 {
+		t.Errorf("Carol should be all-in for less than the ante, got chips %d", players[2].Chips)
+	}
+	if room.GamePhase != "flop" || len(room.CommunityCards) != 3 {
+		t.Errorf("Expected flop with 3 cards, got %s with %d", room.GamePhase, len(room.CommunityCards))
+	}
+	if len(room.RunBoards) != 2 || len(room.RunBoards[1]) != 3 {
+		t.Fatalf("Expected a second 3-card board, got %v", room.RunBoards)
+	}
+	if room.CurrentBet != 0 || room.CurrentTurn != 1 {
+		t.Errorf("Expected betting to start at seat 1 with no bet, got turn %d bet %d", room.CurrentTurn, room.CurrentBet)
+	}
+
+	// 转牌时两套公共牌各发一张
+	room.CommunityCards = append(room.CommunityCards, room.Deck[0])
+	room.Deck = room.Deck[1:]
+	if err := room.dealSecondBoard(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(room.RunBoards[0]) != 4 || len(room.RunBoards[1]) != 4 {
+		t.Errorf("Expected both boards to have 4 cards, got %d and %d", len(room.RunBoards[0]), len(room.RunBoards[1]))
+	}
+	if len(room.Deck) != CARDS_IN_DECK-8 {
+		t.Errorf("Expected %d cards left in deck, got %d", CARDS_IN_DECK-8, len(room.Deck))
+	}
+}
+
+// 测试只有房主可以预约炸弹底池，前注不能超过大盲注的MAX_BOMB_POT_ANTE_BLINDS倍
+func TestScheduleBombPot(t *testing.T) {
+	host := &Player{ID: "bh", Name: "Host"}
+	guest := &Player{ID: "bg", Name: "Guest"}
+	room := &GameRoom{ID: "test_schedule_bomb_pot", Players: []*Player{host, guest}, HostID: host.ID, BigBlind: 10}
+	roomsMutex.Lock()
+	rooms[room.ID] = room
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(rooms, room.ID)
+		roomsMutex.Unlock()
+	}()
+
+	scheduleBombPot(guest, &Message{Data: map[string]interface{}{"ante": float64(20)}})
+	if room.NextBombPot != nil {
+		t.Error("Non-host should not schedule a bomb pot")
+	}
+	scheduleBombPot(host, &Message{Data: map[string]interface{}{"ante": float64(MAX_BOMB_POT_ANTE_BLINDS*10 + 1)}})
+	if room.NextBombPot != nil {
+		t.Error("Ante above the cap should be rejected")
+	}
+	scheduleBombPot(host, &Message{Data: map[string]interface{}{"ante": float64(MAX_BOMB_POT_ANTE_BLINDS * 10)}})
+	if room.NextBombPot == nil || room.NextBombPot.Ante != MAX_BOMB_POT_ANTE_BLINDS*10 {
+		t.Errorf("Host should schedule a bomb pot, got %+v", room.NextBombPot)
+	}
+}
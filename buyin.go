@@ -0,0 +1,143 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	RATHOLE_MINUTES     = 60      // 默认离桌后多少分钟内回到座位需要带上离桌时的筹码
+	MAX_RATHOLE_MINUTES = 24 * 60 // 最长可以设置的分钟数
+)
+
+// 检查买一手并返回实际从钱包买入的筹码（调用者需持有锁）
+// 只有坐着的玩家能买一手，在座位上的玩家只能在两局之间补充筹码，设置了最大买入时最多补到最大买入
+func (room *GameRoom) topUpAmount(player *Player, requested int) (int, error) {
+	seated := false
+	for _, p := range room.Players {
+		if p.ID == player.ID {
+			if room.GamePhase != "waiting" {
+				return 0, errors.New("请在本局结束后再买入")
+			}
+			seated = true
+		}
+	}
+	for _, p := range room.WaitingPlayers {
+		seated = seated || p.ID == player.ID
+	}
+	if !seated {
+		return 0, errors.New("请先上桌，上桌时从钱包买入")
+	}
+	settings := room.Settings
+	if settings.MaxRebuys > 0 && room.BuyHandCount[player.Name] >= settings.MaxRebuys {
+		return 0, fmt.Errorf("买一手次数已达上限 %d 次", settings.MaxRebuys)
+	}
+	amount := requested
+	if settings.MaxBuyIn > 0 {
+		if player.Chips >= settings.MaxBuyIn {
+			return 0, fmt.Errorf("筹码已达到最大买入 %d", settings.MaxBuyIn)
+		}
+		if player.Chips+amount > settings.MaxBuyIn {
+			amount = settings.MaxBuyIn - player.Chips
+		}
+	}
+	return amount, nil
+}
+
+// 记录玩家买一手的筹码（调用者需持有写锁）
+func (room *GameRoom) recordTopUp(name string, amount int) {
+	if room.BuyIns == nil {
+		room.BuyIns = make(map[string]int)
+	}
+	room.BuyIns[name] += amount
+}
+
+// 离桌后一段时间内回到座位至少要带上的筹码，没有限制时返回0（调用者需持有锁）
+func (room *GameRoom) ratholeChips(name string) int {
+	if room.Settings.RatholeMinutes <= 0 {
+		return 0
+	}
+	window := time.Duration(room.Settings.RatholeMinutes) * time.Minute
+	for i := len(room.CashOuts) - 1; i >= 0; i-- {
+		cashOut := room.CashOuts[i]
+		if cashOut.Name != name {
+			continue
+		}
+		if time.Since(cashOut.At) < window {
+			return cashOut.Chips
+		}
+		return 0
+	}
+	return 0
+}
+
+// 检查现金局玩家坐下时从钱包带入的筹码并返回实际买入的数量（调用者需持有锁）
+// requested为0时默认买入BUY_IN_AMOUNT，并调整到最低和最大买入之间；
+// 离桌后一段时间内回来需要至少带上离桌时的筹码，不能赢了钱离桌再用少量筹码坐回来（这时可以超过最大买入）
+func (room *GameRoom) seatBuyIn(player *Player, requested int) (int, error) {
+	settings := room.Settings
+	rathole := room.ratholeChips(player.Name)
+	amount := requested
+	if amount <= 0 {
+		amount = BUY_IN_AMOUNT
+		if settings.MaxBuyIn > 0 && amount > settings.MaxBuyIn {
+			amount = settings.MaxBuyIn
+		}
+		if amount < settings.MinBuyIn {
+			amount = settings.MinBuyIn
+		}
+		if amount < rathole {
+			amount = rathole
+		}
+	}
+	if amount < rathole {
+		return 0, fmt.Errorf("离桌后 %d 分钟内回到座位需要至少带上离桌时的 %d 筹码", settings.RatholeMinutes, rathole)
+	}
+	if settings.MinBuyIn > 0 && amount < settings.MinBuyIn {
+		return 0, fmt.Errorf("买入少于最低买入 %d", settings.MinBuyIn)
+	}
+	if settings.MaxBuyIn > 0 && amount > settings.MaxBuyIn && amount > rathole {
+		return 0, fmt.Errorf("买入超过最大买入 %d", settings.MaxBuyIn)
+	}
+	return amount, nil
+}
+
+// 玩家坐下时从钱包买入筹码（调用者需持有写锁）
+// 锦标赛支付买入并发放起始筹码；多桌锦标赛在报名时支付，座位由赛事分配
+func (room *GameRoom) buyInFromWallet(player *Player, requested int) error {
+	if room.MTT != nil {
+		return nil
+	}
+	if room.Tournament != nil {
+		return room.tournamentBuyIn(player)
+	}
+	amount, err := room.seatBuyIn(player, requested)
+	if err != nil {
+		return err
+	}
+	if err := walletWithdraw(player, amount); err != nil {
+		return err
+	}
+	player.Chips = amount
+	if room.BuyIns == nil {
+		room.BuyIns = make(map[string]int)
+	}
+	room.BuyIns[player.Name] = amount
+	log.Printf("玩家 %s 从钱包买入 %d 筹码上桌，房间 %s", player.Name, amount, room.ID)
+	return nil
+}
+
+// 上桌请求中指定的买入筹码，没有指定时返回0
+func requestedBuyIn(msg *Message) int {
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		if amount, ok := data["amount"].(float64); ok && amount > 0 {
+			return int(amount)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// 测试买一手的限制：本局进行中不能补充筹码，最多补到最大买入，买一手次数上限
+func TestTopUpAmount(t *testing.T) {
+	alice := &Player{ID: "ba", Name: "Alice", Chips: 700}
+	room := &GameRoom{
+		ID:           "test_topup",
+		GamePhase:    "turn",
+		Players:      []*Player{alice},
+		BuyHandCount: map[string]int{},
+		Settings:     RoomSettings{MaxBuyIn: 1000, MaxRebuys: 2},
+	}
+
+	if _, err := room.topUpAmount(alice, BUY_IN_AMOUNT); err == nil {
+		t.Error("Seated player should not top up during a hand")
+	}
+	room.GamePhase = "waiting"
+	if amount, err := room.topUpAmount(alice, BUY_IN_AMOUNT); err != nil || amount != 300 {
+		t.Errorf("Expected to top up 300 to the max buy-in, got %d, %v", amount, err)
+	}
+	alice.Chips = 1000
+	if _, err := room.topUpAmount(alice, BUY_IN_AMOUNT); err == nil {
+		t.Error("Player at the max buy-in should not top up")
+	}
+	alice.Chips = 100
+	room.BuyHandCount["Alice"] = 2
+	if _, err := room.topUpAmount(alice, BUY_IN_AMOUNT); err == nil {
+		t.Error("Player should not exceed the rebuy cap")
+	}
+	bob := &Player{ID: "bb", Name: "Bob"}
+	room.Spectators = []*Player{bob}
+	if _, err := room.topUpAmount(bob, BUY_IN_AMOUNT); err == nil {
+		t.Error("Spectator should buy in when taking a seat")
+	}
+}
+
+// 测试坐下时的买入：默认买入调整到最低和最大买入之间，离桌后回来需要带上离桌时的筹码
+func TestSeatBuyIn(t *testing.T) {
+	alice := &Player{ID: "ba", Name: "Alice"}
+	room := &GameRoom{
+		ID:       "test_seat_buyin",
+		Settings: RoomSettings{MinBuyIn: 200, MaxBuyIn: 400, RatholeMinutes: 60},
+	}
+	if amount, err := room.seatBuyIn(alice, 0); err != nil || amount != 400 {
+		t.Errorf("Default buy-in should be capped at the max buy-in, got %d, %v", amount, err)
+	}
+	if _, err := room.seatBuyIn(alice, 150); err == nil {
+		t.Error("Buy-in below the min buy-in should be rejected")
+	}
+	if _, err := room.seatBuyIn(alice, 450); err == nil {
+		t.Error("Buy-in above the max buy-in should be rejected")
+	}
+
+	room.CashOuts = []*CashOut{
+		{Name: "Alice", Chips: 300, At: time.Now().Add(-2 * time.Hour)},
+		{Name: "Alice", Chips: 900, At: time.Now().Add(-10 * time.Minute)},
+		{Name: "Bob", Chips: 100, At: time.Now()},
+	}
+	if _, err := room.seatBuyIn(alice, 400); err == nil {
+		t.Error("Player coming back within the window should bring the cashed out chips")
+	}
+	if amount, err := room.seatBuyIn(alice, 0); err != nil || amount != 900 {
+		t.Errorf("Default buy-in should bring the cashed out chips, got %d, %v", amount, err)
+	}
+
+	room.CashOuts[1].At = time.Now().Add(-61 * time.Minute)
+	if amount, err := room.seatBuyIn(alice, 400); err != nil || amount != 400 {
+		t.Errorf("Rathole rule should expire after the window, got %d, %v", amount, err)
+	}
+}
+
+// 测试本局结束后等待玩家上桌：筹码只来自买入，没有筹码的玩家回到观战，座位已满时继续等待
+func TestSeatWaitingPlayers(t *testing.T) {
+	room := &GameRoom{ID: "test_seat_waiting"}
+	for i := 0; i < MAX_PLAYERS-1; i++ {
+		room.Players = append(room.Players, &Player{ID: string(rune('a' + i)), Chips: 100})
+	}
+	alice := &Player{ID: "swa", Name: "Alice", Chips: 300, Status: PlayerStatusPlaying}
+	bob := &Player{ID: "swb", Name: "Bob", Status: PlayerStatusPlaying}
+	carol := &Player{ID: "swc", Name: "Carol", Chips: 200, Status: PlayerStatusPlaying}
+	room.WaitingPlayers = []*Player{alice, bob, carol}
+
+	room.seatWaitingPlayers()
+
+	if len(room.Players) != MAX_PLAYERS || room.Players[MAX_PLAYERS-1] != alice || alice.Chips != 300 || alice.Status != PlayerStatusPlaying {
+		t.Errorf("Alice should take the last seat with her buy-in, got %d chips, status %s", alice.Chips, alice.Status)
+	}
+	if bob.Chips != 0 || len(room.Spectators) != 1 || room.Spectators[0] != bob || bob.Status != PlayerStatusSpectating {
+		t.Errorf("Bob without a buy-in should go back to spectating, got %d chips, status %s", bob.Chips, bob.Status)
+	}
+	if len(room.WaitingPlayers) != 1 || room.WaitingPlayers[0] != carol {
+		t.Errorf("Carol should keep waiting while the table is full, got %d waiting", len(room.WaitingPlayers))
+	}
+}
@@ -0,0 +1,200 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// 玩家离桌时的兑现记录
+type CashOut struct {
+	PlayerID string    `json:"playerId"`
+	Name     string    `json:"name"`
+	Chips    int       `json:"chips"` // 离桌时的筹码，存回玩家的钱包
+	BuyIn    int       `json:"buyIn"` // 本次坐下以来从钱包买入的筹码（上桌买入加买一手）
+	Net      int       `json:"net"`   // 输赢：筹码减去累计买入
+	At       time.Time `json:"at"`
+}
+
+// 玩家本次坐下以来累计买入的筹码（调用者需持有锁）
+func (room *GameRoom) buyInTotal(name string) int {
+	return room.BuyIns[name]
+}
+
+// 结算玩家的筹码：存回钱包并记录本次输赢，玩家离开座位后不再有筹码（调用者需持有写锁）
+func (room *GameRoom) recordCashOut(player *Player) *CashOut {
+	walletDeposit(player, player.Chips)
+	buyIn := room.buyInTotal(player.Name)
+	cashOut := &CashOut{
+		PlayerID: player.ID,
+		Name:     player.Name,
+		Chips:    player.Chips,
+		BuyIn:    buyIn,
+		Net:      player.Chips - buyIn,
+		At:       time.Now(),
+	}
+	room.CashOuts = append(room.CashOuts, cashOut)
+	delete(room.BuyIns, player.Name)
+	player.Chips = 0
+	log.Printf("玩家 %s 离桌兑现，房间 %s，筹码 %d，累计买入 %d，输赢 %+d", player.Name, room.ID, cashOut.Chips, buyIn, cashOut.Net)
+	return cashOut
+}
+
+// 把玩家从座位移到观战并结算筹码，玩家不在座位上时返回nil（调用者需持有写锁）
+// 只能在两局之间或玩家不在本局中时调用
+func (room *GameRoom) standUpNow(player *Player) *CashOut {
+	removed := false
+	for i, p := range room.Players {
+		if p.ID == player.ID {
+			room.Players = append(room.Players[:i], room.Players[i+1:]...)
+			if i < room.DealerIndex {
+				room.DealerIndex--
+			}
+			if room.DealerIndex >= len(room.Players) {
+				room.DealerIndex = 0
+			}
+			removed = true
+			break
+		}
+	}
+	if !removed {
+		for i, p := range room.WaitingPlayers {
+			if p.ID == player.ID {
+				room.WaitingPlayers = append(room.WaitingPlayers[:i], room.WaitingPlayers[i+1:]...)
+				removed = true
+				break
+			}
+		}
+	}
+	if !removed {
+		return nil
+	}
+
+	player.StandUpPending = false
+	player.Status = PlayerStatusSpectating
+	player.Hand = []Card{}
+	player.Bet = 0
+	player.Folded = false
+	player.AllIn = false
+	player.IsDealer = false
+	player.IsSmall = false
+	player.IsBig = false
+	room.Spectators = append(room.Spectators, player)
+	return room.recordCashOut(player)
+}
+
+// 广播玩家离桌（在锁外调用）
+func (room *GameRoom) sendStoodUp(cashOuts []*CashOut) {
+	if len(cashOuts) == 0 {
+		return
+	}
+	room.Mutex.RLock()
+	recipients := room.recipients()
+	report := room.sessionReport()
+	room.Mutex.RUnlock()
+
+	roomData := room.ToJSON()
+	for _, cashOut := range cashOuts {
+		sendToPlayers(recipients, Message{
+			Type: "playerStoodUp",
+			Data: map[string]interface{}{
+				"playerId": cashOut.PlayerID,
+				"name":     cashOut.Name,
+				"cashOut":  cashOut,
+				"room":     roomData,
+			},
+		})
+		// 离桌的玩家的筹码已经存回钱包，同时发给他本场的结算报告
+		for _, p := range recipients {
+			if p.ID == cashOut.PlayerID {
+				sendWallet(p)
+				sendSessionReport(p, report)
+			}
+		}
+	}
+}
+
+// 一局结束后让申请离桌的玩家离开座位（在锁外调用）
+func (room *GameRoom) processStandUps() {
+	room.Mutex.Lock()
+	var cashOuts []*CashOut
+	if room.GamePhase == "waiting" {
+		for _, p := range append([]*Player{}, room.Players...) {
+			if p.StandUpPending {
+				cashOuts = append(cashOuts, room.standUpNow(p))
+			}
+		}
+	}
+	room.Mutex.Unlock()
+
+	room.sendStoodUp(cashOuts)
+}
+
+// 离桌兑现：两局之间立即离开座位，本局进行中时在本局结束后离开
+// data.cancel为true时取消离桌申请
+func standUp(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	cancel := false
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		cancel, _ = data["cancel"].(bool)
+	}
+
+	room.Mutex.Lock()
+	var err error
+	seated, waiting := false, false
+	for _, p := range room.Players {
+		seated = seated || p.ID == player.ID
+	}
+	for _, p := range room.WaitingPlayers {
+		waiting = waiting || p.ID == player.ID
+	}
+	if room.MTT != nil || room.Tournament != nil {
+		err = errors.New("锦标赛中不能离桌兑现")
+	} else if !seated && !waiting {
+		err = errors.New("您不在座位上")
+	}
+	if err != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+
+	// 本局进行中，等本局结束
+	if seated && room.GamePhase != "waiting" {
+		player.StandUpPending = !cancel
+		room.Mutex.Unlock()
+
+		log.Printf("玩家 %s 申请本局结束后离桌: %v，房间 %s", player.Name, !cancel, room.ID)
+		sendMessage(player, Message{
+			Type: "standUpPending",
+			Data: map[string]interface{}{
+				"pending": !cancel,
+			},
+		})
+		return
+	}
+	if cancel {
+		room.Mutex.Unlock()
+		return
+	}
+
+	cashOut := room.standUpNow(player)
+	room.Mutex.Unlock()
+
+	room.sendStoodUp([]*CashOut{cashOut})
+	room.refreshWaitlist(false)
+}
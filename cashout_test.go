@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+)
+
+// 测试离桌兑现：两局之间立即离开座位，本局进行中等本局结束
+func TestStandUp(t *testing.T) {
+	alice := &Player{ID: "ca", Name: "Alice", Chips: 800, Status: PlayerStatusPlaying}
+	bob := &Player{ID: "cb", Name: "Bob", Chips: 300, Status: PlayerStatusPlaying}
+	carol := &Player{ID: "cc", Name: "Carol", Chips: 900, Status: PlayerStatusPlaying}
+	room := &GameRoom{
+		ID:          "test_standup",
+		GamePhase:   "waiting",
+		Players:     []*Player{alice, bob, carol},
+		DealerIndex: 2,
+		BuyIns:      map[string]int{"Alice": 500, "Bob": 500, "Carol": 1000},
+	}
+	roomsMutex.Lock()
+	rooms[room.ID] = room
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(rooms, room.ID)
+		roomsMutex.Unlock()
+	}()
+
+	standUp(alice, &Message{})
+	if len(room.Players) != 2 || !room.isSpectator(alice.ID) || alice.Status != PlayerStatusSpectating {
+		t.Fatal("Alice should stand up immediately between hands")
+	}
+	if room.DealerIndex != 1 || room.Players[room.DealerIndex] != carol {
+		t.Errorf("Dealer should stay on Carol, got index %d", room.DealerIndex)
+	}
+	if len(room.CashOuts) != 1 || room.CashOuts[0].Net != 300 || alice.Chips != 0 {
+		t.Errorf("Unexpected cash out: %+v", room.CashOuts)
+	}
+
+	// 本局进行中只标记，本局结束后离开
+	room.GamePhase = "flop"
+	standUp(carol, &Message{})
+	if len(room.Players) != 2 || !carol.StandUpPending {
+		t.Fatal("Carol should stay seated until the hand ends")
+	}
+	room.GamePhase = "waiting"
+	room.processStandUps()
+	if len(room.Players) != 1 || !room.isSpectator(carol.ID) {
+		t.Fatal("Carol should stand up after the hand")
+	}
+	if c := room.CashOuts[1]; c.BuyIn != 1000 || c.Net != -100 {
+		t.Errorf("Unexpected cash out for Carol: %+v", c)
+	}
+
+	// 不在座位上不能离桌
+	standUp(alice, &Message{})
+	if len(room.CashOuts) != 2 {
+		t.Error("Spectator should not be able to cash out again")
+	}
+}
@@ -0,0 +1,314 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	CHAT_MAX_LENGTH   = 200              // 一条聊天消息最多的字数
+	CHAT_HISTORY_SIZE = 100              // 每个房间保留的最近聊天消息数
+	CHAT_RATE_LIMIT   = 5                // 时间窗口内每人最多发送的消息数
+	CHAT_RATE_WINDOW  = 10 * time.Second // 发言频率限制的时间窗口
+)
+
+// 聊天频道
+const (
+	ChatChannelTable      = "table"      // 房间中所有人都能看到
+	ChatChannelSpectators = "spectators" // 观战聊天和牌桌分开时，只有观战的人能看到
+)
+
+// 一条聊天消息
+type ChatMessage struct {
+	ID       int       `json:"id"`
+	PlayerID string    `json:"playerId"`
+	Name     string    `json:"name"`
+	Text     string    `json:"text"`
+	Channel  string    `json:"channel"`
+	At       time.Time `json:"at"`
+}
+
+// 聊天内容过滤器：返回过滤后的内容，返回错误时拒绝发送
+type ChatFilter interface {
+	Filter(text string) (string, error)
+}
+
+// 屏蔽词过滤器：把屏蔽词（不区分大小写）替换成星号
+type wordFilter struct {
+	words [][]rune
+}
+
+func newWordFilter(words []string) *wordFilter {
+	f := &wordFilter{}
+	for _, word := range words {
+		word = strings.TrimSpace(word)
+		if word != "" {
+			f.words = append(f.words, lowerRunes(word))
+		}
+	}
+	return f
+}
+
+func lowerRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+func (f *wordFilter) Filter(text string) (string, error) {
+	runes := []rune(text)
+	lower := lowerRunes(text)
+	for _, word := range f.words {
+		for i := 0; i+len(word) <= len(lower); i++ {
+			if string(lower[i:i+len(word)]) != string(word) {
+				continue
+			}
+			for j := i; j < i+len(word); j++ {
+				runes[j] = '*'
+			}
+			i += len(word) - 1
+		}
+	}
+	return string(runes), nil
+}
+
+// 屏蔽词文件（每行一个词），不存在时不过滤
+var chatFilterFile = "chat_filter.txt"
+
+// 当前使用的聊天过滤器，可以替换成其他实现
+var chatFilter ChatFilter = newWordFilter(nil)
+
+// 启动时从文件加载屏蔽词
+func loadChatFilter() error {
+	file, err := os.Open(chatFilterFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		words = append(words, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	filter := newWordFilter(words)
+	chatFilter = filter
+	log.Printf("加载了 %d 个聊天屏蔽词", len(filter.words))
+	return nil
+}
+
+// 玩家发送的消息所在的频道（调用者需持有锁）
+func (room *GameRoom) chatChannel(player *Player) string {
+	if room.Settings.SeparateSpectatorChat && room.isSpectator(player.ID) {
+		return ChatChannelSpectators
+	}
+	return ChatChannelTable
+}
+
+// 能看到该频道消息的人（调用者需持有锁）
+func (room *GameRoom) chatRecipients(channel string) []*Player {
+	if channel == ChatChannelSpectators {
+		return append([]*Player{}, room.Spectators...)
+	}
+	return room.recipients()
+}
+
+// 玩家能看到的聊天记录（调用者需持有锁）
+func (room *GameRoom) visibleChat(player *Player) []*ChatMessage {
+	spectator := room.isSpectator(player.ID)
+	messages := make([]*ChatMessage, 0, len(room.ChatHistory))
+	for _, m := range room.ChatHistory {
+		if m.Channel == ChatChannelTable || spectator {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+// 检查发言频率并记录这次发言，超过限制时返回false（调用者需持有写锁）
+func (room *GameRoom) allowChat(playerID string, now time.Time) bool {
+	if room.ChatTimes == nil {
+		room.ChatTimes = make(map[string][]time.Time)
+	}
+	recent := room.ChatTimes[playerID][:0]
+	for _, at := range room.ChatTimes[playerID] {
+		if now.Sub(at) < CHAT_RATE_WINDOW {
+			recent = append(recent, at)
+		}
+	}
+	if len(recent) >= CHAT_RATE_LIMIT {
+		room.ChatTimes[playerID] = recent
+		return false
+	}
+	room.ChatTimes[playerID] = append(recent, now)
+	return true
+}
+
+// 检查并保存一条聊天消息（调用者需持有写锁），本局进行中时同时记入牌局记录
+func (room *GameRoom) addChat(player *Player, text string, now time.Time) (*ChatMessage, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("聊天内容不能为空")
+	}
+	if len([]rune(text)) > CHAT_MAX_LENGTH {
+		return nil, fmt.Errorf("聊天内容不能超过 %d 个字", CHAT_MAX_LENGTH)
+	}
+	if room.MutedNames[player.Name] {
+		return nil, errors.New("您已被房主禁言")
+	}
+	if !room.allowChat(player.ID, now) {
+		return nil, errors.New("发言太频繁，请稍后再试")
+	}
+	text, err := chatFilter.Filter(text)
+	if err != nil {
+		return nil, err
+	}
+
+	room.ChatSeq++
+	chat := &ChatMessage{
+		ID:       room.ChatSeq,
+		PlayerID: player.ID,
+		Name:     player.Name,
+		Text:     text,
+		Channel:  room.chatChannel(player),
+		At:       now,
+	}
+	room.ChatHistory = append(room.ChatHistory, chat)
+	if len(room.ChatHistory) > CHAT_HISTORY_SIZE {
+		room.ChatHistory = room.ChatHistory[len(room.ChatHistory)-CHAT_HISTORY_SIZE:]
+	}
+	// 观战频道的消息玩家看不到，不记入牌局记录
+	if chat.Channel == ChatChannelTable {
+		room.recordHandEvent(HandEvent{
+			Type:     HandEventChat,
+			PlayerID: player.ID,
+			Name:     player.Name,
+			Message:  text,
+		})
+	}
+	return chat, nil
+}
+
+// 发送聊天消息
+func sendChat(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	text := ""
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		text, _ = data["text"].(string)
+	}
+
+	room.Mutex.Lock()
+	chat, err := room.addChat(player, text, time.Now())
+	if err != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+	recipients := room.chatRecipients(chat.Channel)
+	room.Mutex.Unlock()
+
+	sendToPlayers(recipients, Message{
+		Type: "chat",
+		Data: map[string]interface{}{
+			"message": chat,
+		},
+	})
+}
+
+// 获取房间最近的聊天记录（观战聊天分开时，只有观战的人能看到观战频道的消息）
+func getChatHistory(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	room.Mutex.RLock()
+	messages := room.visibleChat(player)
+	room.Mutex.RUnlock()
+
+	sendMessage(player, Message{
+		Type: "chatHistory",
+		Data: map[string]interface{}{
+			"messages": messages,
+		},
+	})
+}
+
+// 房主禁言或解除禁言（按昵称，离开后重新加入仍然禁言）
+func setMuted(player *Player, msg *Message, muted bool) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	room.Mutex.Lock()
+	err := room.requireHost(player)
+	target := room.findMember(targetPlayerID(msg))
+	if err == nil && (target == nil || target.ID == player.ID) {
+		err = errors.New("玩家不在房间中")
+	}
+	if err != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+	if room.MutedNames == nil {
+		room.MutedNames = make(map[string]bool)
+	}
+	if muted {
+		room.MutedNames[target.Name] = true
+	} else {
+		delete(room.MutedNames, target.Name)
+	}
+	recipients := room.recipients()
+	room.Mutex.Unlock()
+
+	log.Printf("房主 %s 禁言玩家 %s: %v，房间 %s", player.Name, target.Name, muted, room.ID)
+	sendToPlayers(recipients, Message{
+		Type: "playerMuted",
+		Data: map[string]interface{}{
+			"playerId": target.ID,
+			"name":     target.Name,
+			"muted":    muted,
+		},
+	})
+}
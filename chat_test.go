@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试聊天：长度限制、发言频率、禁言、观战频道和牌局记录
+func TestAddChat(t *testing.T) {
+	alice := &Player{ID: "ha", Name: "Alice"}
+	bob := &Player{ID: "hb", Name: "Bob"}
+	room := &GameRoom{
+		ID:          "test_chat",
+		GamePhase:   "flop",
+		Players:     []*Player{alice},
+		Spectators:  []*Player{bob},
+		CurrentHand: &HandHistory{},
+	}
+	now := time.Now()
+
+	if _, err := room.addChat(alice, "   ", now); err == nil {
+		t.Error("Empty message should be rejected")
+	}
+	if _, err := room.addChat(alice, strings.Repeat("好", CHAT_MAX_LENGTH+1), now); err == nil {
+		t.Error("Long message should be rejected")
+	}
+	for i := 0; i < CHAT_RATE_LIMIT; i++ {
+		if _, err := room.addChat(alice, "hi", now); err != nil {
+			t.Fatalf("Message %d rejected: %v", i, err)
+		}
+	}
+	if _, err := room.addChat(alice, "hi", now); err == nil {
+		t.Error("Message over the rate limit should be rejected")
+	}
+	if _, err := room.addChat(alice, "hi", now.Add(CHAT_RATE_WINDOW)); err != nil {
+		t.Errorf("Message after the window should be allowed: %v", err)
+	}
+	if n := len(room.CurrentHand.Events); n != CHAT_RATE_LIMIT+1 || room.CurrentHand.Events[0].Type != HandEventChat {
+		t.Errorf("Expected chat events in hand history, got %d", n)
+	}
+
+	// 观战聊天分开时，观战的人的消息不记入牌局记录，玩家也看不到
+	room.Settings.SeparateSpectatorChat = true
+	chat, err := room.addChat(bob, "spectating", now)
+	if err != nil || chat.Channel != ChatChannelSpectators {
+		t.Fatalf("Unexpected spectator chat: %+v, %v", chat, err)
+	}
+	if len(room.CurrentHand.Events) != CHAT_RATE_LIMIT+1 {
+		t.Error("Spectator chat should not be recorded in hand history")
+	}
+	if len(room.visibleChat(alice)) != CHAT_RATE_LIMIT+1 || len(room.visibleChat(bob)) != CHAT_RATE_LIMIT+2 {
+		t.Error("Players should not see the spectator channel")
+	}
+	if r := room.chatRecipients(ChatChannelSpectators); len(r) != 1 || r[0] != bob {
+		t.Errorf("Unexpected spectator recipients: %v", r)
+	}
+
+	room.MutedNames = map[string]bool{"Bob": true}
+	if _, err := room.addChat(bob, "muted", now); err == nil {
+		t.Error("Muted player should not be able to chat")
+	}
+}
+
+// 测试屏蔽词过滤
+func TestWordFilter(t *testing.T) {
+	f := newWordFilter([]string{"bad", " 笨蛋 ", ""})
+	got, err := f.Filter("BAD luck, 你这个笨蛋, badbad")
+	if err != nil || got != "*** luck, 你这个**, ******" {
+		t.Errorf("Unexpected filter result: %q, %v", got, err)
+	}
+}
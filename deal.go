@@ -0,0 +1,273 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"errors"
+	"log"
+	"sort"
+)
+
+const (
+	DealMethodICM      = "icm"      // 按ICM（独立筹码模型）分配
+	DealMethodChipChop = "chipchop" // 按筹码比例分配
+)
+
+// 协议中一名玩家分到的奖金
+type DealOffer struct {
+	PlayerID string `json:"playerId"`
+	Name     string `json:"name"`
+	Chips    int    `json:"chips"`
+	Payout   int    `json:"payout"`
+}
+
+// 锦标赛剩余玩家协商的奖金分配协议，所有剩余玩家都同意后比赛结束
+type TournamentDeal struct {
+	Method        string          `json:"method"`
+	ProposedBy    string          `json:"proposedBy"`
+	RemainingPool int             `json:"remainingPool"` // 剩余名次的奖金总额
+	Offers        []DealOffer     `json:"offers"`        // 按筹码从多到少排列
+	Accepted      map[string]bool `json:"accepted"`
+	Agreed        bool            `json:"agreed"`
+}
+
+// 复制协议用于在锁外发送
+func (d *TournamentDeal) copy() *TournamentDeal {
+	c := *d
+	c.Offers = append([]DealOffer{}, d.Offers...)
+	c.Accepted = make(map[string]bool, len(d.Accepted))
+	for id, accepted := range d.Accepted {
+		c.Accepted[id] = accepted
+	}
+	return &c
+}
+
+// 检查当前是否可以协商奖金分配，返回剩余玩家（调用者需持有锁）
+func (room *GameRoom) dealPlayers(player *Player) ([]*Player, error) {
+	if room.MTT != nil {
+		return nil, errors.New("多桌锦标赛暂不支持协商分配奖金")
+	}
+	t := room.Tournament
+	if t == nil || !t.Started || t.Finished {
+		return nil, errors.New("只有进行中的锦标赛可以协商分配奖金")
+	}
+	if room.GamePhase != "waiting" {
+		return nil, errors.New("请在本局结束后再协商")
+	}
+	for _, entry := range t.Busted {
+		if entry.PendingRebuy {
+			return nil, errors.New("还有玩家可以重购，重购期结束后才能协商")
+		}
+	}
+
+	remaining := append(append([]*Player{}, room.Players...), room.WaitingPlayers...)
+	if len(remaining) < MIN_TOURNAMENT_PLAYERS {
+		return nil, errors.New("剩余玩家不足")
+	}
+	for _, p := range remaining {
+		if p.ID == player.ID {
+			return remaining, nil
+		}
+	}
+	return nil, errors.New("只有剩余的参赛玩家可以协商")
+}
+
+// 按指定方式计算剩余玩家的奖金（调用者需持有锁）
+// 剩余玩家分配的是还没有发出的名次奖金，已经被淘汰的玩家奖金不变
+func (room *GameRoom) calculateDeal(remaining []*Player, method string) *TournamentDeal {
+	t := room.Tournament
+	players := append([]*Player{}, remaining...)
+	sort.SliceStable(players, func(i, j int) bool {
+		return players[i].Chips > players[j].Chips
+	})
+
+	prizes := calculatePayouts(t.PrizePool, t.Settings.Payouts, len(players)+len(t.Busted))
+	if len(prizes) > len(players) {
+		prizes = prizes[:len(players)]
+	}
+	pool := 0
+	for _, prize := range prizes {
+		pool += prize
+	}
+
+	stacks := make([]int, len(players))
+	for i, p := range players {
+		stacks[i] = p.Chips
+	}
+	var shares []float64
+	if method == DealMethodChipChop {
+		shares = calculateChipChop(stacks, pool)
+	} else {
+		method = DealMethodICM
+		shares = calculateICM(stacks, prizes)
+	}
+	payouts := roundShares(shares, pool)
+
+	deal := &TournamentDeal{
+		Method:        method,
+		RemainingPool: pool,
+		Offers:        make([]DealOffer, len(players)),
+		Accepted:      make(map[string]bool),
+	}
+	for i, p := range players {
+		deal.Offers[i] = DealOffer{PlayerID: p.ID, Name: p.Name, Chips: p.Chips, Payout: payouts[i]}
+	}
+	return deal
+}
+
+// 查询按ICM和按筹码比例分配的奖金（不发起协议）
+func getDealQuote(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	room.Mutex.RLock()
+	remaining, err := room.dealPlayers(player)
+	if err != nil {
+		room.Mutex.RUnlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+	icm := room.calculateDeal(remaining, DealMethodICM)
+	chipChop := room.calculateDeal(remaining, DealMethodChipChop)
+	room.Mutex.RUnlock()
+
+	sendMessage(player, Message{
+		Type: "dealQuote",
+		Data: map[string]interface{}{
+			"remainingPool": icm.RemainingPool,
+			"icm":           icm.Offers,
+			"chipChop":      chipChop.Offers,
+		},
+	})
+}
+
+// 发起奖金分配协议，发起者视为已同意
+func proposeDeal(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	method := DealMethodICM
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		if m, ok := data["method"].(string); ok && m == DealMethodChipChop {
+			method = m
+		}
+	}
+
+	room.Mutex.Lock()
+	remaining, err := room.dealPlayers(player)
+	if err == nil && room.Tournament.Deal != nil {
+		err = errors.New("已有待确认的协议")
+	}
+	if err != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+	deal := room.calculateDeal(remaining, method)
+	deal.ProposedBy = player.ID
+	deal.Accepted[player.ID] = true
+	room.Tournament.Deal = deal
+	dealData := deal.copy()
+	recipients := room.recipients()
+	room.Mutex.Unlock()
+
+	log.Printf("玩家 %s 发起奖金分配协议（%s），房间 %s，剩余奖金 %d", player.Name, method, room.ID, deal.RemainingPool)
+	sendToPlayers(recipients, Message{
+		Type: "dealProposed",
+		Data: map[string]interface{}{
+			"playerId": player.ID,
+			"name":     player.Name,
+			"deal":     dealData,
+		},
+	})
+}
+
+// 同意或拒绝奖金分配协议，所有剩余玩家同意后按协议结束比赛
+func respondDeal(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	accept := false
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		accept, _ = data["accept"].(bool)
+	}
+
+	room.Mutex.Lock()
+	_, err := room.dealPlayers(player)
+	if err == nil && room.Tournament.Deal == nil {
+		err = errors.New("没有待确认的协议")
+	}
+	if err != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+
+	deal := room.Tournament.Deal
+	if !accept {
+		room.Tournament.Deal = nil
+		recipients := room.recipients()
+		room.Mutex.Unlock()
+
+		log.Printf("玩家 %s 拒绝奖金分配协议，房间 %s", player.Name, room.ID)
+		sendToPlayers(recipients, Message{
+			Type: "dealRejected",
+			Data: map[string]interface{}{
+				"playerId": player.ID,
+				"name":     player.Name,
+			},
+		})
+		return
+	}
+
+	deal.Accepted[player.ID] = true
+	deal.Agreed = len(deal.Accepted) == len(deal.Offers)
+	var finished []Message
+	if deal.Agreed {
+		finished = append(finished, room.finishTournament())
+	}
+	dealData := deal.copy()
+	recipients := room.recipients()
+	room.Mutex.Unlock()
+
+	log.Printf("玩家 %s 同意奖金分配协议，房间 %s，已同意 %d/%d", player.Name, room.ID, len(dealData.Accepted), len(dealData.Offers))
+	sendToPlayers(recipients, Message{
+		Type: "dealAccepted",
+		Data: map[string]interface{}{
+			"playerId": player.ID,
+			"name":     player.Name,
+			"deal":     dealData,
+		},
+	})
+	for _, m := range finished {
+		sendToPlayers(recipients, m)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+)
+
+// 测试达成协议后按协议记录名次和奖金
+func TestTournamentDeal(t *testing.T) {
+	alice := &Player{ID: "a", Name: "Alice", Chips: 1000}
+	bob := &Player{ID: "b", Name: "Bob", Chips: 3000}
+	settings := defaultTournamentSettings()
+	room := &GameRoom{
+		ID:         "test_deal",
+		Players:    []*Player{alice, bob},
+		GamePhase:  "waiting",
+		Tournament: newTournament(settings),
+	}
+	room.Tournament.Started = true
+	room.Tournament.Entries = 3
+	room.Tournament.PrizePool = 300
+	room.Tournament.Busted = []*TournamentEntry{{PlayerID: "c", Name: "Carol"}}
+
+	if _, err := room.dealPlayers(&Player{ID: "c"}); err == nil {
+		t.Error("Busted player should not take part in a deal")
+	}
+	remaining, err := room.dealPlayers(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 剩余奖金为前两名的150+90，Carol的第三名奖金不变
+	deal := room.calculateDeal(remaining, DealMethodChipChop)
+	if deal.RemainingPool != 240 || deal.Offers[0].PlayerID != "b" || deal.Offers[0].Payout != 180 || deal.Offers[1].Payout != 60 {
+		t.Errorf("Unexpected chip chop deal: %+v", deal)
+	}
+	deal = room.calculateDeal(remaining, DealMethodICM)
+	if deal.Offers[0].Payout+deal.Offers[1].Payout != 240 || deal.Offers[0].Payout != 135 {
+		t.Errorf("Unexpected ICM deal: %+v", deal)
+	}
+
+	deal.Agreed = true
+	room.Tournament.Deal = deal
+	room.finishTournament()
+	results := room.Tournament.Results
+	if len(results) != 3 || results[0].Name != "Bob" || results[0].Prize != 135 || !results[0].Deal {
+		t.Fatalf("Expected Bob first with the ICM payout, got %+v", results)
+	}
+	if results[2].Name != "Carol" || results[2].Prize != 60 || results[2].Deal {
+		t.Errorf("Expected Carol's payout to be unchanged, got %+v", results[2])
+	}
+}
@@ -23,12 +23,12 @@ echo "✅ 环境检查通过"
 echo ""
 
 # 检查是否已编译
-if [ ! -f "poker-server" ] || [ "main.go" -nt "poker-server" ] || [ "game.go" -nt "poker-server" ]; then
+if [ ! -f "poker-server" ] || [ -n "$(find . -maxdepth 1 -name '*.go' -newer poker-server)" ]; then
     echo "📦 正在本地编译 Go 程序..."
     export CGO_ENABLED=0
     export GOOS=linux
     export GOARCH=amd64
-    go build -o poker-server main.go game.go
+    go build -o poker-server .
     
     if [ $? -ne 0 ]; then
         echo "❌ 编译失败"
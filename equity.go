@@ -0,0 +1,158 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+)
+
+const (
+	EQUITY_EXACT_LIMIT = 50000 // 剩余发牌组合数不超过此值时精确枚举
+	EQUITY_SAMPLES     = 2000  // 超过精确枚举上限时（翻牌前全押）随机抽样的次数
+)
+
+// 单个玩家的胜率
+type PlayerEquity struct {
+	PlayerID string  `json:"playerId"`
+	Name     string  `json:"name"`
+	Win      float64 `json:"win"`    // 独赢概率（百分比）
+	Tie      float64 `json:"tie"`    // 打平概率（百分比）
+	Equity   float64 `json:"equity"` // 期望分得底池的比例（百分比，打平按人数平分）
+}
+
+// 参与胜率计算的玩家手牌
+type EquityHand struct {
+	PlayerID string
+	Name     string
+	Hand     []Card
+}
+
+// 计算当前公共牌下每个玩家的胜率
+// 枚举剩余牌组中所有可能的后续公共牌（用evaluateHand比牌），组合数过多时（翻牌前）改为随机抽样
+// 抽样的随机数种子由已知的手牌和公共牌决定，同一局面对所有人（包括直播画面）给出相同的估算结果
+// 返回每个玩家的胜率，以及结果是否为精确枚举（false时是估算）
+func calculateEquity(hands []EquityHand, board []Card) ([]PlayerEquity, bool) {
+	results := make([]PlayerEquity, len(hands))
+	for i, h := range hands {
+		results[i] = PlayerEquity{PlayerID: h.PlayerID, Name: h.Name}
+	}
+	if len(hands) == 0 {
+		return results, true
+	}
+
+	// 剩余牌组：去掉所有已知手牌和公共牌
+	known := make(map[Card]bool)
+	for _, h := range hands {
+		for _, c := range h.Hand {
+			known[c] = true
+		}
+	}
+	for _, c := range board {
+		known[c] = true
+	}
+	remaining := []Card{}
+	for _, c := range createDeck() {
+		if !known[c] {
+			remaining = append(remaining, c)
+		}
+	}
+
+	need := 5 - len(board)
+	if need < 0 {
+		need = 0
+	}
+
+	wins := make([]float64, len(hands))
+	ties := make([]float64, len(hands))
+	shares := make([]float64, len(hands))
+	total := 0
+
+	evaluate := func(runout []Card) {
+		full := make([]Card, 0, 5)
+		full = append(full, board...)
+		full = append(full, runout...)
+
+		var best HandRank
+		best.Rank = -1
+		winners := []int{}
+		for i, h := range hands {
+			hand := append([]Card{}, h.Hand...)
+			rank := evaluateHand(hand, full)
+			comparison := compareHandRanks(rank, best)
+			if comparison > 0 {
+				best = rank
+				winners = []int{i}
+			} else if comparison == 0 {
+				winners = append(winners, i)
+			}
+		}
+
+		total++
+		if len(winners) == 1 {
+			wins[winners[0]]++
+			shares[winners[0]]++
+			return
+		}
+		for _, i := range winners {
+			ties[i]++
+			shares[i] += 1 / float64(len(winners))
+		}
+	}
+
+	exact := countCombinations(len(remaining), need) <= EQUITY_EXACT_LIMIT
+	if exact {
+		for _, runout := range getCombinations(remaining, need) {
+			evaluate(runout)
+		}
+	} else {
+		deck := append([]Card{}, remaining...)
+		rng := rand.New(rand.NewSource(equitySeed(hands, board)))
+		for i := 0; i < EQUITY_SAMPLES; i++ {
+			// 部分洗牌，只需要前need张
+			for j := 0; j < need; j++ {
+				k := j + rng.Intn(len(deck)-j)
+				deck[j], deck[k] = deck[k], deck[j]
+			}
+			evaluate(deck[:need])
+		}
+	}
+
+	for i := range results {
+		results[i].Win = roundPercent(wins[i] * 100 / float64(total))
+		results[i].Tie = roundPercent(ties[i] * 100 / float64(total))
+		results[i].Equity = roundPercent(shares[i] * 100 / float64(total))
+	}
+	return results, exact
+}
+
+// 抽样的随机数种子：已知的手牌和公共牌的哈希
+func equitySeed(hands []EquityHand, board []Card) int64 {
+	h := fnv.New64a()
+	for _, hand := range hands {
+		for _, c := range hand.Hand {
+			h.Write([]byte(c.Suit + c.Rank + ","))
+		}
+		h.Write([]byte("|"))
+	}
+	for _, c := range board {
+		h.Write([]byte(c.Suit + c.Rank + ","))
+	}
+	return int64(h.Sum64())
+}
+
+// 百分比保留两位小数
+func roundPercent(x float64) float64 {
+	return math.Round(x*100) / 100
+}
+
+// 计算从n个中选k个的组合数
+func countCombinations(n, k int) int {
+	if k < 0 || k > n {
+		return 0
+	}
+	result := 1
+	for i := 1; i <= k; i++ {
+		result = result * (n - k + i) / i
+	}
+	return result
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// 测试转牌圈全押时的精确胜率
+func TestCalculateEquityOnTurn(t *testing.T) {
+	hands := []EquityHand{
+		{PlayerID: "a", Name: "Alice", Hand: []Card{{"spades", "A"}, {"hearts", "A"}}},
+		{PlayerID: "b", Name: "Bob", Hand: []Card{{"spades", "K"}, {"hearts", "K"}}},
+	}
+	board := []Card{{"clubs", "2"}, {"diamonds", "7"}, {"clubs", "9"}, {"diamonds", "J"}}
+
+	equity, exact := calculateEquity(hands, board)
+	if !exact {
+		t.Error("Equity on the turn should be exact")
+	}
+
+	// Bob只有两张K可以反超：2/44
+	expectedBob := 2.0 * 100 / 44
+	if math.Abs(equity[1].Win-expectedBob) > 0.01 {
+		t.Errorf("Expected Bob to win %.3f%%, got %.3f%%", expectedBob, equity[1].Win)
+	}
+	if math.Abs(equity[0].Win+equity[1].Win-100) > 0.01 {
+		t.Errorf("Win percentages should add up to 100, got %.3f", equity[0].Win+equity[1].Win)
+	}
+}
+
+// 测试公共牌发完后胜负已定
+func TestCalculateEquityOnRiver(t *testing.T) {
+	hands := []EquityHand{
+		{PlayerID: "a", Name: "Alice", Hand: []Card{{"spades", "A"}, {"hearts", "Q"}}},
+		{PlayerID: "b", Name: "Bob", Hand: []Card{{"clubs", "A"}, {"diamonds", "Q"}}},
+	}
+	board := []Card{{"clubs", "2"}, {"diamonds", "7"}, {"hearts", "9"}, {"diamonds", "J"}, {"spades", "3"}}
+
+	equity, exact := calculateEquity(hands, board)
+	if !exact {
+		t.Error("Equity on the river should be exact")
+	}
+	for _, e := range equity {
+		if e.Tie != 100 || e.Equity != 50 {
+			t.Errorf("Expected %s to tie with 50%% equity, got tie %.1f%% equity %.1f%%", e.Name, e.Tie, e.Equity)
+		}
+	}
+}
+
+// 测试组合数计算
+func TestCountCombinations(t *testing.T) {
+	if n := countCombinations(45, 2); n != 990 {
+		t.Errorf("C(45,2) should be 990, got %d", n)
+	}
+	if n := countCombinations(48, 5); n != 1712304 {
+		t.Errorf("C(48,5) should be 1712304, got %d", n)
+	}
+	if n := countCombinations(44, 0); n != 1 {
+		t.Errorf("C(44,0) should be 1, got %d", n)
+	}
+}
+
+// 测试翻牌前全押时抽样估算：结果标记为估算，同一局面每次结果相同
+func TestCalculateEquityPreflop(t *testing.T) {
+	hands := []EquityHand{
+		{PlayerID: "a", Name: "Alice", Hand: []Card{{"spades", "A"}, {"hearts", "A"}}},
+		{PlayerID: "b", Name: "Bob", Hand: []Card{{"spades", "K"}, {"hearts", "K"}}},
+	}
+
+	first, exact := calculateEquity(hands, nil)
+	if exact {
+		t.Error("Preflop equity should be marked as approximate")
+	}
+	second, _ := calculateEquity(hands, nil)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("Same spot should give the same equity: %+v vs %+v", first[i], second[i])
+		}
+	}
+	// AA对KK约82%
+	if first[0].Equity < 78 || first[0].Equity > 86 {
+		t.Errorf("Expected Alice to have about 82%% equity, got %.2f%%", first[0].Equity)
+	}
+}
@@ -0,0 +1,255 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"time"
+)
+
+const (
+	MAX_HAND_HISTORY = 50 // 每个房间保留的最近牌局记录数
+)
+
+// 牌局记录中的事件类型
+const (
+	HandEventAction = "action" // 玩家行动（包括超时自动行动）
+	HandEventStreet = "street" // 发公共牌
+	HandEventResult = "result" // 结算
+	HandEventShow   = "show"   // 摊牌时亮牌或盖牌，以及结束后主动亮牌
+	HandEventRabbit = "rabbit" // 结束后查看没发出的公共牌（不影响结果）
+	HandEventChat   = "chat"   // 本局进行中的聊天消息（不包括观战频道）
+	HandEventRunIt  = "runIt"  // 全下后约定的发牌次数（不是下注）
+)
+
+// 牌局记录中的一个事件
+type HandEvent struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Phase    string    `json:"phase"`
+	PlayerID string    `json:"playerId,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	Action   string    `json:"action,omitempty"`
+	Amount   int       `json:"amount,omitempty"`
+	Cards    []Card    `json:"cards,omitempty"`
+	Message  string    `json:"message,omitempty"`
+}
+
+// 牌局记录中的玩家信息
+type HandHistoryPlayer struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Seat       int     `json:"seat"`
+	StartChips int     `json:"startChips"`
+	EndChips   int     `json:"endChips"`
+	Hand       []Card  `json:"hand,omitempty"` // 只记录摊牌时亮出的手牌
+	player     *Player // 牌局进行中关闭房间时退还投入的筹码（玩家可能已经离开）
+}
+
+// 一局的完整记录
+type HandHistory struct {
+	HandNumber     int                  `json:"handNumber"`
+	RoomID         string               `json:"roomId"`
+	StartedAt      time.Time            `json:"startedAt"`
+	EndedAt        time.Time            `json:"endedAt"`
+	Players        []*HandHistoryPlayer `json:"players"`
+	Events         []HandEvent          `json:"events"`
+	CommunityCards []Card               `json:"communityCards"`
+	Boards         [][]Card             `json:"boards,omitempty"` // 多次发牌时每次的公共牌
+	Pot            int                  `json:"pot"`
+	Winners        []string             `json:"winners"`
+	WinningHand    string               `json:"winningHand"`
+}
+
+// 开始记录新的一局（调用者需持有写锁）
+func (room *GameRoom) beginHandHistory() {
+	room.HandCount++
+	history := &HandHistory{
+		HandNumber:     room.HandCount,
+		RoomID:         room.ID,
+		StartedAt:      time.Now(),
+		Players:        make([]*HandHistoryPlayer, len(room.Players)),
+		Events:         []HandEvent{},
+		CommunityCards: []Card{},
+	}
+	for i, p := range room.Players {
+		history.Players[i] = &HandHistoryPlayer{
+			ID:         p.ID,
+			Name:       p.Name,
+			Seat:       i,
+			StartChips: p.Chips,
+			player:     p,
+		}
+	}
+	room.CurrentHand = history
+	room.publishOverlay(nil)
+}
+
+// 向当前牌局记录追加事件（调用者需持有写锁）
+func (room *GameRoom) recordHandEvent(event HandEvent) {
+	if room.CurrentHand == nil {
+		return
+	}
+	event.Time = time.Now()
+	if event.Phase == "" {
+		event.Phase = room.GamePhase
+	}
+	room.CurrentHand.Events = append(room.CurrentHand.Events, event)
+	room.publishOverlay(&event)
+}
+
+// 记录玩家行动（调用者需持有写锁）
+func (room *GameRoom) recordAction(player *Player, action string, amount int) {
+	room.recordHandEvent(HandEvent{
+		Type:     HandEventAction,
+		PlayerID: player.ID,
+		Name:     player.Name,
+		Action:   action,
+		Amount:   amount,
+	})
+}
+
+// 记录发出的公共牌（调用者需持有写锁）
+func (room *GameRoom) recordStreet() {
+	room.recordHandEvent(HandEvent{
+		Type:  HandEventStreet,
+		Cards: append([]Card{}, room.CommunityCards...),
+	})
+}
+
+// 记录超时或掉线时的自动行动（调用者需持有写锁）
+func (room *GameRoom) recordAutoAction(player *Player, action string) {
+	room.recordHandEvent(HandEvent{
+		Type:     HandEventAction,
+		PlayerID: player.ID,
+		Name:     player.Name,
+		Action:   action,
+		Message:  "超时自动行动",
+	})
+}
+
+// 结束当前牌局记录并存入历史（调用者需持有写锁）
+// revealed 为摊牌时亮出的手牌（按玩家ID），未亮牌的玩家不记录手牌
+func (room *GameRoom) finishHandHistory(pot int, winners []*Player, winningHand string, revealed map[string][]Card) {
+	history := room.CurrentHand
+	if history == nil {
+		return
+	}
+	room.CurrentHand = nil
+
+	history.EndedAt = time.Now()
+	history.Pot = pot
+	history.WinningHand = winningHand
+	history.CommunityCards = append([]Card{}, room.CommunityCards...)
+	if len(room.RunBoards) > 1 {
+		history.Boards = make([][]Card, len(room.RunBoards))
+		for i, board := range room.RunBoards {
+			history.Boards[i] = append([]Card{}, board...)
+		}
+	}
+	history.Winners = make([]string, len(winners))
+	for i, w := range winners {
+		history.Winners[i] = w.Name
+	}
+	for _, hp := range history.Players {
+		for _, p := range room.Players {
+			if p.ID == hp.ID {
+				hp.EndChips = p.Chips
+				break
+			}
+		}
+		if cards, ok := revealed[hp.ID]; ok {
+			hp.Hand = append([]Card{}, cards...)
+		}
+	}
+	result := HandEvent{
+		Time:    history.EndedAt,
+		Type:    HandEventResult,
+		Phase:   "showdown",
+		Amount:  pot,
+		Message: winningHand,
+	}
+	history.Events = append(history.Events, result)
+	room.publishOverlay(&result)
+
+	room.HandHistories = append(room.HandHistories, history)
+	if len(room.HandHistories) > MAX_HAND_HISTORY {
+		room.HandHistories = room.HandHistories[len(room.HandHistories)-MAX_HAND_HISTORY:]
+	}
+	room.recordHandStats(history)
+	room.recordHandResults(history)
+	room.recordSessionHand(history)
+}
+
+// 向已结束的牌局记录追加事件（例如结束后主动亮牌），返回该局记录，找不到时返回nil
+// 注意：调用此函数时应该持有写锁
+func (room *GameRoom) appendFinishedHandEvent(handNumber int, event HandEvent) *HandHistory {
+	for _, history := range room.HandHistories {
+		if history.HandNumber != handNumber {
+			continue
+		}
+		event.Time = time.Now()
+		if event.Phase == "" {
+			event.Phase = "showdown"
+		}
+		history.Events = append(history.Events, event)
+		room.publishOverlay(&event)
+		return history
+	}
+	return nil
+}
+
+// 复制一局记录，避免在锁外序列化时与后续追加的事件冲突
+func copyHandHistory(history *HandHistory) *HandHistory {
+	c := *history
+	c.Events = append([]HandEvent{}, history.Events...)
+	c.Players = make([]*HandHistoryPlayer, len(history.Players))
+	for i, hp := range history.Players {
+		player := *hp
+		c.Players[i] = &player
+	}
+	return &c
+}
+
+// 获取房间最近的牌局记录
+func getHandHistory(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	limit := 10
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		if n, ok := data["limit"].(float64); ok && n > 0 {
+			limit = int(n)
+		}
+	}
+
+	room.Mutex.RLock()
+	// 观战延迟中的牌局不返回给观战的人
+	cutoff := room.historyCutoff(player, time.Now())
+	visible := room.HandHistories
+	for len(visible) > 0 && visible[len(visible)-1].EndedAt.After(cutoff) {
+		visible = visible[:len(visible)-1]
+	}
+	start := len(visible) - limit
+	if start < 0 {
+		start = 0
+	}
+	histories := make([]*HandHistory, 0, len(visible)-start)
+	for _, history := range visible[start:] {
+		histories = append(histories, copyHandHistory(history))
+	}
+	room.Mutex.RUnlock()
+
+	sendMessage(player, Message{
+		Type: "handHistory",
+		Data: map[string]interface{}{
+			"hands": histories,
+		},
+	})
+}
@@ -0,0 +1,351 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// 检查玩家是否是房主（调用者需持有锁）
+// 没有房主的房间（多桌锦标赛的牌桌）不能使用房主命令
+func (room *GameRoom) requireHost(player *Player) error {
+	if room.MTT != nil {
+		return errors.New("多桌锦标赛的牌桌由赛事管理")
+	}
+	if room.HostID != player.ID {
+		return errors.New("只有房主可以执行此操作")
+	}
+	return nil
+}
+
+// 在房间中按ID查找玩家（游戏中、观战、等待），调用者需持有锁
+func (room *GameRoom) findMember(playerID string) *Player {
+	for _, list := range [][]*Player{room.Players, room.Spectators, room.WaitingPlayers} {
+		for _, p := range list {
+			if p.ID == playerID {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// 房主离开后把房主转给房间中的下一个人（优先游戏中的玩家），没有人时房主为空
+// 返回新的房主（调用者需持有写锁）
+func (room *GameRoom) transferHost() *Player {
+	for _, list := range [][]*Player{room.Players, room.Spectators, room.WaitingPlayers} {
+		if len(list) > 0 {
+			room.HostID = list[0].ID
+			return list[0]
+		}
+	}
+	room.HostID = ""
+	return nil
+}
+
+// 广播房主变更（在锁外调用）
+func (room *GameRoom) sendHostChanged(host *Player) {
+	room.Mutex.RLock()
+	recipients := room.recipients()
+	room.Mutex.RUnlock()
+
+	log.Printf("房间 %s 房主变更为 %s", room.ID, host.Name)
+	sendToPlayers(recipients, Message{
+		Type: "hostChanged",
+		Data: map[string]interface{}{
+			"hostId": host.ID,
+			"name":   host.Name,
+		},
+	})
+}
+
+// 解析房主命令的目标玩家
+func targetPlayerID(msg *Message) string {
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		playerID, _ := data["playerId"].(string)
+		return playerID
+	}
+	return ""
+}
+
+// 房主把房主转给房间中的其他人
+func transferHostCommand(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	room.Mutex.Lock()
+	err := room.requireHost(player)
+	target := room.findMember(targetPlayerID(msg))
+	if err == nil && target == nil {
+		err = errors.New("玩家不在房间中")
+	}
+	if err != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+	room.HostID = target.ID
+	room.Mutex.Unlock()
+
+	room.sendHostChanged(target)
+}
+
+// 房主踢出玩家（ban为true时同时禁止该昵称和地址再次加入）
+// 本局进行中时不能踢出正在游戏的玩家，避免打乱行动顺序
+func kickPlayer(player *Player, msg *Message, ban bool) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	room.Mutex.Lock()
+	err := room.requireHost(player)
+	target := room.findMember(targetPlayerID(msg))
+	if err == nil && (target == nil || target.ID == player.ID) {
+		err = errors.New("玩家不在房间中")
+	}
+	if err == nil && room.GamePhase != "waiting" {
+		for _, p := range room.Players {
+			if p.ID == target.ID {
+				err = errors.New("请在本局结束后再踢出正在游戏的玩家")
+			}
+		}
+	}
+	if err != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+	if ban {
+		if room.BannedNames == nil {
+			room.BannedNames = make(map[string]bool)
+			room.BannedAddrs = make(map[string]bool)
+		}
+		room.BannedNames[target.Name] = true
+		if target.RemoteAddr != "" {
+			room.BannedAddrs[target.RemoteAddr] = true
+		}
+	}
+	room.Mutex.Unlock()
+
+	log.Printf("房主 %s 踢出玩家 %s，禁止再次加入: %v，房间 %s", player.Name, target.Name, ban, room.ID)
+	sendMessage(target, Message{
+		Type: "kicked",
+		Data: map[string]interface{}{
+			"roomId": room.ID,
+			"banned": ban,
+		},
+	})
+	removePlayer(target)
+}
+
+// 是否被禁止加入房间（调用者需持有锁）
+func (room *GameRoom) isBanned(player *Player) bool {
+	return room.BannedNames[player.Name] || (player.RemoteAddr != "" && room.BannedAddrs[player.RemoteAddr])
+}
+
+// 房主暂停或继续游戏：暂停期间拒绝所有下注操作，回合计时和锦标赛级别计时停止，
+// 也不能开始新的一局；继续时从暂停的地方接着计时
+func setPaused(player *Player, msg *Message, paused bool) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	room.Mutex.Lock()
+	err := room.requireHost(player)
+	if err == nil && room.Paused == paused {
+		if paused {
+			err = errors.New("游戏已经暂停")
+		} else {
+			err = errors.New("游戏没有暂停")
+		}
+	}
+	if err != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+
+	room.Paused = paused
+	msgType := "gameResumed"
+	if paused {
+		msgType = "gamePaused"
+		room.pauseClocks()
+	} else {
+		room.resumeClocks()
+	}
+	timeLeft := room.turnTimeLeft()
+	recipients := room.recipients()
+	room.Mutex.Unlock()
+
+	log.Printf("房主 %s %s，房间 %s，当前回合剩余 %d 秒", player.Name, msgType, room.ID, timeLeft)
+	sendToPlayers(recipients, Message{
+		Type: msgType,
+		Data: map[string]interface{}{
+			"playerId":     player.ID,
+			"name":         player.Name,
+			"turnTimeLeft": timeLeft,
+			"room":         room.ToJSON(),
+		},
+	})
+}
+
+// 暂停回合计时和锦标赛级别计时，记录剩余时间（调用者需持有写锁）
+func (room *GameRoom) pauseClocks() {
+	if room.TurnTimer != nil {
+		room.TurnTimer.Stop()
+		room.TurnTimer = nil
+		room.TurnRemaining = time.Until(room.TurnDeadline)
+		if room.TurnRemaining < time.Second {
+			room.TurnRemaining = time.Second
+		}
+	}
+	if t := room.Tournament; t != nil && t.LevelTimer != nil {
+		t.LevelTimer.Stop()
+		t.LevelTimer = nil
+		t.LevelLeft = time.Until(t.LevelEndsAt)
+		if t.LevelLeft < time.Second {
+			t.LevelLeft = time.Second
+		}
+	}
+}
+
+// 按暂停时剩余的时间继续计时（调用者需持有写锁）
+func (room *GameRoom) resumeClocks() {
+	if room.GamePhase != "waiting" && !room.AllInRunout {
+		room.startTurnTimer()
+	}
+	room.TurnRemaining = 0
+	if t := room.Tournament; t != nil && t.Started && !t.Finished && t.LevelLeft > 0 {
+		room.scheduleLevelUp()
+	}
+}
+
+// 当前回合剩余的秒数（调用者需持有锁），没有人在行动时为0
+func (room *GameRoom) turnTimeLeft() int {
+	left := room.TurnRemaining
+	if !room.Paused {
+		if room.TurnTimer == nil {
+			return 0
+		}
+		left = time.Until(room.TurnDeadline)
+	}
+	if left <= 0 {
+		return 0
+	}
+	return int((left + time.Second - 1) / time.Second)
+}
+
+// 房主在两局之间修改房间设置（锦标赛设置不能修改）
+func updateRoomSettings(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	data, _ := msg.Data.(map[string]interface{})
+	raw, _ := data["settings"].(map[string]interface{})
+
+	room.Mutex.Lock()
+	err := room.requireHost(player)
+	if err == nil && raw == nil {
+		err = errors.New("请求数据格式错误")
+	}
+	if err == nil && room.GamePhase != "waiting" {
+		err = errors.New("请在本局结束后再修改设置")
+	}
+	if err != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+
+	tournament := room.Settings.Tournament
+	room.Settings = room.Settings.merge(raw)
+	room.Settings.Tournament = tournament
+	if password, ok := raw["password"].(string); ok {
+		room.setPassword(password)
+	}
+	// 现金局可以修改盲注，锦标赛的盲注由级别决定
+	if room.Tournament == nil {
+		smallBlind, _ := raw["smallBlind"].(float64)
+		bigBlind, _ := raw["bigBlind"].(float64)
+		if smallBlind > 0 && bigBlind >= smallBlind {
+			room.SmallBlind = int(smallBlind)
+			room.BigBlind = int(bigBlind)
+		}
+	}
+	recipients := room.recipients()
+	room.Mutex.Unlock()
+
+	log.Printf("房主 %s 修改房间设置，房间 %s", player.Name, room.ID)
+	sendToPlayers(recipients, Message{
+		Type: "settingsUpdated",
+		Data: map[string]interface{}{
+			"room": room.ToJSON(),
+		},
+	})
+}
+
+// 房主关闭房间
+func closeRoomCommand(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	// 在关闭房间的同一把写锁内检查，检查后不会有新的一局开始
+	err := room.closeIf("房主关闭了房间", func() error {
+		if err := room.requireHost(player); err != nil {
+			return err
+		}
+		if room.GamePhase != "waiting" {
+			return errors.New("请在本局结束后再关闭房间")
+		}
+		return nil
+	})
+	if err != nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+	}
+}
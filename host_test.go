@@ -0,0 +1,160 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// 测试房主权限和房主离开后的转移
+func TestRoomHost(t *testing.T) {
+	host := &Player{ID: "h", Name: "Host"}
+	alice := &Player{ID: "a", Name: "Alice"}
+	bob := &Player{ID: "b", Name: "Bob", RemoteAddr: "192.0.2.7"}
+	room := &GameRoom{
+		ID:         "test_host",
+		HostID:     "h",
+		Players:    []*Player{alice},
+		Spectators: []*Player{bob},
+	}
+
+	if err := room.requireHost(host); err != nil {
+		t.Errorf("Host should pass the host check, got %v", err)
+	}
+	if err := room.requireHost(alice); err == nil {
+		t.Error("Non-host should fail the host check")
+	}
+
+	// 优先转给游戏中的玩家
+	if next := room.transferHost(); next != alice || room.HostID != "a" {
+		t.Errorf("Expected Alice to become host, got %v", next)
+	}
+	room.Players = nil
+	if next := room.transferHost(); next != bob {
+		t.Errorf("Expected Bob to become host, got %v", next)
+	}
+	room.Spectators = nil
+	if next := room.transferHost(); next != nil || room.HostID != "" {
+		t.Error("Empty room should have no host")
+	}
+
+	// 禁止加入按昵称和地址生效
+	room.BannedNames = map[string]bool{"Bob": true}
+	room.BannedAddrs = map[string]bool{"192.0.2.7": true}
+	if !room.isBanned(&Player{Name: "Bob"}) || !room.isBanned(&Player{Name: "Bobby", RemoteAddr: "192.0.2.7"}) {
+		t.Error("Banned name or address should be rejected")
+	}
+	if room.isBanned(alice) {
+		t.Error("Alice should not be banned")
+	}
+}
+
+// 测试修改设置时未指定的字段保持不变
+func TestRoomSettingsMerge(t *testing.T) {
+	settings := defaultRoomSettings()
+	settings.MaxRuns = 2
+	settings.RabbitHunt = true
+
+	merged := settings.merge(map[string]interface{}{"visibility": "private"})
+	if merged.MaxRuns != 2 || !merged.RabbitHunt || merged.Visibility != RoomVisibilityPrivate {
+		t.Errorf("Unexpected merged settings: %+v", merged)
+	}
+	merged = merged.merge(map[string]interface{}{"rabbitHunt": false, "visibility": "bogus"})
+	if merged.RabbitHunt || merged.Visibility != RoomVisibilityPrivate {
+		t.Errorf("Unexpected merged settings: %+v", merged)
+	}
+}
+
+// 测试暂停时记录剩余时间，继续时按剩余时间计时
+func TestRoomPauseClocks(t *testing.T) {
+	room := &GameRoom{
+		ID:          "test_pause",
+		Players:     []*Player{{ID: "a", Name: "Alice"}, {ID: "b", Name: "Bob"}},
+		GamePhase:   "flop",
+		CurrentTurn: 1,
+	}
+	room.startTurnTimer()
+	room.TurnDeadline = time.Now().Add(25 * time.Second)
+
+	room.Paused = true
+	room.pauseClocks()
+	if room.TurnTimer != nil {
+		t.Fatal("Turn timer should stop while paused")
+	}
+	if left := room.turnTimeLeft(); left != 25 {
+		t.Errorf("Expected 25 seconds left while paused, got %d", left)
+	}
+
+	room.Paused = false
+	room.resumeClocks()
+	if room.TurnTimer == nil {
+		t.Fatal("Turn timer should restart on resume")
+	}
+	if left := room.turnTimeLeft(); left != 25 {
+		t.Errorf("Expected to resume with 25 seconds, got %d", left)
+	}
+	if room.TurnRemaining != 0 {
+		t.Error("Remaining time should be consumed on resume")
+	}
+
+	// 暂停期间轮到新的玩家，继续后给他完整的时间
+	room.Paused = true
+	room.pauseClocks()
+	room.CurrentTurn = 0
+	room.startTurnTimer()
+	if left := room.turnTimeLeft(); left != TURN_TIMEOUT {
+		t.Errorf("Expected a new turn to get %d seconds, got %d", TURN_TIMEOUT, left)
+	}
+	room.Paused = false
+	room.resumeClocks()
+	room.TurnTimer.Stop()
+}
+
+// 测试已经触发的回合定时器在等待锁期间游戏被暂停或重新计时后不再自动行动
+func TestStaleTurnTimer(t *testing.T) {
+	alice := &Player{ID: "st_a", Name: "Alice", Bet: 0}
+	bob := &Player{ID: "st_b", Name: "Bob", Bet: 20}
+	room := &GameRoom{
+		ID:          "test_stale_turn_timer",
+		Players:     []*Player{alice, bob},
+		GamePhase:   "preflop",
+		CurrentBet:  20,
+		CurrentTurn: 0,
+	}
+	roomsMutex.Lock()
+	rooms[room.ID] = room
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(rooms, room.ID)
+		roomsMutex.Unlock()
+	}()
+
+	// 定时器触发后在等待锁时房主暂停了游戏
+	room.Mutex.Lock()
+	room.TurnRemaining = 10 * time.Millisecond
+	room.startTurnTimer()
+	time.Sleep(50 * time.Millisecond)
+	room.Paused = true
+	room.pauseClocks()
+	room.Mutex.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	room.Mutex.Lock()
+	if alice.Folded {
+		t.Fatal("Timer should not act after the game is paused")
+	}
+
+	// 定时器触发后在等待锁时被新的计时替换（继续游戏）
+	room.Paused = false
+	room.TurnRemaining = 10 * time.Millisecond
+	room.startTurnTimer()
+	time.Sleep(50 * time.Millisecond)
+	room.startTurnTimer()
+	room.Mutex.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	room.Mutex.Lock()
+	defer room.Mutex.Unlock()
+	if alice.Folded {
+		t.Error("Replaced timer should not act")
+	}
+	room.TurnTimer.Stop()
+}
@@ -0,0 +1,95 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// 计算ICM（独立筹码模型）下每个玩家的奖金期望
+// 按Malmuth-Harville模型：玩家获得某个名次的概率与其筹码占剩余筹码的比例成正比
+// 用位掩码枚举已经确定名次的玩家集合，玩家数不超过MAX_PLAYERS时计算量很小
+// prizes为剩余名次的奖金（第一名在前），返回值与stacks顺序一致
+func calculateICM(stacks []int, prizes []int) []float64 {
+	equities := make([]float64, len(stacks))
+	if len(stacks) == 0 || len(prizes) == 0 {
+		return equities
+	}
+
+	// reach[mask]：mask中的玩家恰好占据了前几名的概率
+	// 子集的数值总是小于超集，按数值从小到大遍历即可保证先算完子集
+	total := 0
+	for _, s := range stacks {
+		total += s
+	}
+	reach := make([]float64, 1<<len(stacks))
+	reach[0] = 1
+	for mask := range reach {
+		if reach[mask] == 0 {
+			continue
+		}
+		position, left := 0, total
+		for i, s := range stacks {
+			if mask&(1<<i) != 0 {
+				position++
+				left -= s
+			}
+		}
+		if position >= len(prizes) || left <= 0 {
+			continue
+		}
+		for i, s := range stacks {
+			if mask&(1<<i) != 0 || s <= 0 {
+				continue
+			}
+			p := reach[mask] * float64(s) / float64(left)
+			equities[i] += p * float64(prizes[position])
+			reach[mask|1<<i] += p
+		}
+	}
+	return equities
+}
+
+// 按筹码比例平分奖金（chip chop）
+func calculateChipChop(stacks []int, prizePool int) []float64 {
+	shares := make([]float64, len(stacks))
+	total := 0
+	for _, s := range stacks {
+		total += s
+	}
+	if total == 0 {
+		return shares
+	}
+	for i, s := range stacks {
+		shares[i] = float64(prizePool) * float64(s) / float64(total)
+	}
+	return shares
+}
+
+// 把奖金期望取整，保证总和等于total（零头按小数部分从大到小分配）
+func roundShares(shares []float64, total int) []int {
+	rounded := make([]int, len(shares))
+	if len(shares) == 0 {
+		return rounded
+	}
+	paid := 0
+	for i, s := range shares {
+		rounded[i] = int(math.Floor(s))
+		paid += rounded[i]
+	}
+
+	order := make([]int, len(shares))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return shares[order[a]]-math.Floor(shares[order[a]]) > shares[order[b]]-math.Floor(shares[order[b]])
+	})
+	for i := 0; paid < total; i = (i + 1) % len(order) {
+		rounded[order[i]]++
+		paid++
+	}
+	return rounded
+}
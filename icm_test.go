@@ -0,0 +1,51 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// 测试ICM计算
+func TestCalculateICM(t *testing.T) {
+	// 单挑：奖金期望 = 第二名奖金 + 胜率 * 两个名次的差额
+	equities := calculateICM([]int{3000, 1000}, []int{60, 40})
+	if math.Abs(equities[0]-55) > 1e-9 || math.Abs(equities[1]-45) > 1e-9 {
+		t.Errorf("Expected [55 45], got %v", equities)
+	}
+
+	// 三人：50/30/20的筹码分配50/30/20的奖金，筹码领先者的期望低于筹码比例
+	equities = calculateICM([]int{5000, 3000, 2000}, []int{50, 30, 20})
+	expected := []float64{38.393, 32.75, 28.857}
+	total := 0.0
+	for i, e := range equities {
+		total += e
+		if math.Abs(e-expected[i]) > 0.01 {
+			t.Errorf("Player %d: expected %.3f, got %.3f", i, expected[i], e)
+		}
+	}
+	if math.Abs(total-100) > 1e-9 {
+		t.Errorf("Expected equities to sum to 100, got %f", total)
+	}
+
+	// 筹码相同的玩家期望相同，人数多于奖励名次时也能计算
+	equities = calculateICM([]int{1000, 1000, 1000, 1000}, []int{70, 30})
+	for _, e := range equities {
+		if math.Abs(e-25) > 1e-9 {
+			t.Errorf("Expected 25 for equal stacks, got %v", equities)
+		}
+	}
+}
+
+// 测试按筹码比例分配和取整
+func TestChipChopRounding(t *testing.T) {
+	shares := calculateChipChop([]int{1000, 1000, 1000}, 100)
+	payouts := roundShares(shares, 100)
+	if payouts[0]+payouts[1]+payouts[2] != 100 {
+		t.Errorf("Expected payouts to sum to 100, got %v", payouts)
+	}
+	for _, p := range payouts {
+		if p != 33 && p != 34 {
+			t.Errorf("Expected 33 or 34, got %v", payouts)
+		}
+	}
+}
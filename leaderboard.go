@@ -0,0 +1,372 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	LEADERBOARD_SIZE            = 10              // 排行榜默认显示的人数
+	MAX_LEADERBOARD_SIZE        = 100             // 排行榜最多显示的人数
+	LEADERBOARD_UPDATE_INTERVAL = 5 * time.Second // 有新结果时推送排行榜的间隔
+)
+
+// 结果记录的类型
+const (
+	ResultTypeHand       = "hand"       // 现金局一局的输赢
+	ResultTypeTournament = "tournament" // 锦标赛的名次
+)
+
+// 排行榜的时间范围
+const (
+	LeaderboardDaily   = "daily"   // 今天
+	LeaderboardWeekly  = "weekly"  // 本周（从周一开始）
+	LeaderboardAllTime = "alltime" // 全部
+)
+
+// 结果保存的文件（每行一条记录，测试时替换为临时文件）
+var resultsFile = "results.jsonl"
+
+// 一名玩家的一条结果记录，排行榜由这些记录计算
+type ResultRecord struct {
+	Type       string    `json:"type"`
+	At         time.Time `json:"at"`
+	RoomID     string    `json:"roomId"`
+	HandNumber int       `json:"handNumber,omitempty"`
+	Name       string    `json:"name"`
+	Net        int       `json:"net,omitempty"`      // 现金局这一局的输赢
+	Won        int       `json:"won,omitempty"`      // 现金局赢得的底池（包括自己投入的部分）
+	Place      int       `json:"place,omitempty"`    // 锦标赛名次
+	Entrants   int       `json:"entrants,omitempty"` // 锦标赛参赛人数
+	Points     int       `json:"points,omitempty"`   // 锦标赛积分
+}
+
+var resultRecords []ResultRecord
+var resultsVersion int // 每次有新结果时加一，用于判断是否需要推送排行榜
+var resultsMutex sync.RWMutex
+
+// 排行榜中的一名玩家
+type LeaderboardEntry struct {
+	Rank  int    `json:"rank"`
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+}
+
+type Leaderboard struct {
+	Period           string             `json:"period"`
+	Since            *time.Time         `json:"since,omitempty"`  // 时间范围的开始（全部时为空）
+	NetChips         []LeaderboardEntry `json:"netChips"`         // 现金局累计输赢
+	BiggestPot       []LeaderboardEntry `json:"biggestPot"`       // 现金局赢得的最大底池
+	TournamentPoints []LeaderboardEntry `json:"tournamentPoints"` // 锦标赛积分
+}
+
+// 订阅了排行榜的玩家
+type leaderboardSubscription struct {
+	Player *Player
+	Period string
+	Limit  int
+}
+
+var leaderboardSubscribers = make(map[string]*leaderboardSubscription)
+var leaderboardMutex sync.Mutex
+
+// 启动时从文件加载结果记录，文件不存在时没有记录，无法解析的行会被跳过
+func loadResults() error {
+	file, err := os.Open(resultsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var records []ResultRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record ResultRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Printf("跳过无法解析的结果记录: %v", err)
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	resultsMutex.Lock()
+	resultRecords = append(resultRecords, records...)
+	resultsVersion++
+	resultsMutex.Unlock()
+	log.Printf("加载了 %d 条结果记录", len(records))
+	return nil
+}
+
+// 保存新的结果记录（追加到文件）
+func appendResults(records []ResultRecord) {
+	if len(records) == 0 {
+		return
+	}
+	resultsMutex.Lock()
+	defer resultsMutex.Unlock()
+	resultRecords = append(resultRecords, records...)
+	resultsVersion++
+
+	file, err := os.OpenFile(resultsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("保存结果记录失败: %v", err)
+		return
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			log.Printf("保存结果记录失败: %v", err)
+			return
+		}
+	}
+}
+
+// 记录现金局一局每个玩家的输赢和赢得的底池（调用者需持有写锁），锦标赛的筹码不计入
+func (room *GameRoom) recordHandResults(history *HandHistory) {
+	if room.Tournament != nil || room.MTT != nil {
+		return
+	}
+	winners := make(map[string]bool, len(history.Winners))
+	for _, name := range history.Winners {
+		winners[name] = true
+	}
+	committed := handCommitted(history)
+	nets := handNets(history, room.seatedIDs())
+
+	records := make([]ResultRecord, 0, len(history.Players))
+	for _, hp := range history.Players {
+		record := ResultRecord{
+			Type:       ResultTypeHand,
+			At:         history.EndedAt,
+			RoomID:     history.RoomID,
+			HandNumber: history.HandNumber,
+			Name:       hp.Name,
+			Net:        nets[hp.ID],
+		}
+		if winners[hp.Name] {
+			record.Won = nets[hp.ID] + committed[hp.ID]
+		}
+		records = append(records, record)
+	}
+	appendResults(records)
+}
+
+// 锦标赛积分：参赛人数减名次加一，冠军得分最多
+func tournamentPoints(place, entrants int) int {
+	return entrants - place + 1
+}
+
+// 记录锦标赛的名次和积分
+func recordTournamentResults(roomID string, results []TournamentResult) {
+	now := time.Now()
+	records := make([]ResultRecord, len(results))
+	for i, result := range results {
+		records[i] = ResultRecord{
+			Type:     ResultTypeTournament,
+			At:       now,
+			RoomID:   roomID,
+			Name:     result.Name,
+			Place:    result.Place,
+			Entrants: len(results),
+			Points:   tournamentPoints(result.Place, len(results)),
+		}
+	}
+	appendResults(records)
+}
+
+// 时间范围的开始，全部时返回零值
+func leaderboardSince(period string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case LeaderboardDaily:
+		return today, nil
+	case LeaderboardWeekly:
+		// 周一为一周的第一天
+		return today.AddDate(0, 0, -(int(today.Weekday())+6)%7), nil
+	case LeaderboardAllTime:
+		return time.Time{}, nil
+	}
+	return time.Time{}, errors.New("排行榜时间范围无效")
+}
+
+// 按数值从大到小排列，取前limit名
+func rankEntries(values map[string]int, limit int) []LeaderboardEntry {
+	entries := make([]LeaderboardEntry, 0, len(values))
+	for name, value := range values {
+		entries = append(entries, LeaderboardEntry{Name: name, Value: value})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Value != entries[j].Value {
+			return entries[i].Value > entries[j].Value
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+	return entries
+}
+
+// 根据结果记录计算排行榜
+func buildLeaderboard(period string, limit int, now time.Time) (*Leaderboard, error) {
+	since, err := leaderboardSince(period, now)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = LEADERBOARD_SIZE
+	}
+	if limit > MAX_LEADERBOARD_SIZE {
+		limit = MAX_LEADERBOARD_SIZE
+	}
+
+	netChips := make(map[string]int)
+	biggestPot := make(map[string]int)
+	points := make(map[string]int)
+	resultsMutex.RLock()
+	for _, record := range resultRecords {
+		if record.At.Before(since) {
+			continue
+		}
+		switch record.Type {
+		case ResultTypeHand:
+			netChips[record.Name] += record.Net
+			if record.Won > biggestPot[record.Name] {
+				biggestPot[record.Name] = record.Won
+			}
+		case ResultTypeTournament:
+			points[record.Name] += record.Points
+		}
+	}
+	resultsMutex.RUnlock()
+
+	leaderboard := &Leaderboard{
+		Period:           period,
+		NetChips:         rankEntries(netChips, limit),
+		BiggestPot:       rankEntries(biggestPot, limit),
+		TournamentPoints: rankEntries(points, limit),
+	}
+	if !since.IsZero() {
+		leaderboard.Since = &since
+	}
+	return leaderboard, nil
+}
+
+// 请求中的时间范围和人数，默认本周前10名
+func leaderboardParams(data map[string]interface{}) (string, int) {
+	period, _ := data["period"].(string)
+	if period == "" {
+		period = LeaderboardWeekly
+	}
+	limit, _ := data["limit"].(float64)
+	return period, int(limit)
+}
+
+// 订阅排行榜：立即返回排行榜，之后有新的结果时推送leaderboardUpdated
+func subscribeLeaderboard(player *Player, msg *Message) {
+	data, _ := msg.Data.(map[string]interface{})
+	period, limit := leaderboardParams(data)
+	leaderboard, err := buildLeaderboard(period, limit, time.Now())
+	if err != nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+
+	leaderboardMutex.Lock()
+	leaderboardSubscribers[player.ID] = &leaderboardSubscription{Player: player, Period: period, Limit: limit}
+	leaderboardMutex.Unlock()
+
+	sendMessage(player, Message{
+		Type: "leaderboard",
+		Data: map[string]interface{}{
+			"leaderboard": leaderboard,
+		},
+	})
+}
+
+// 取消订阅排行榜（断开连接时也会调用）
+func unsubscribeLeaderboard(player *Player) {
+	leaderboardMutex.Lock()
+	delete(leaderboardSubscribers, player.ID)
+	leaderboardMutex.Unlock()
+}
+
+// HTTP接口：GET /api/leaderboard?period=weekly&limit=10
+func handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = LeaderboardWeekly
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	leaderboard, err := buildLeaderboard(period, limit, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(leaderboard)
+}
+
+// 定期检查是否有新的结果，有的话把最新的排行榜推送给所有订阅者
+func startLeaderboardBroadcaster() {
+	lastVersion := -1
+	ticker := time.NewTicker(LEADERBOARD_UPDATE_INTERVAL)
+	for range ticker.C {
+		resultsMutex.RLock()
+		version := resultsVersion
+		resultsMutex.RUnlock()
+		if version == lastVersion {
+			continue
+		}
+		lastVersion = version
+
+		leaderboardMutex.Lock()
+		subscriptions := make([]*leaderboardSubscription, 0, len(leaderboardSubscribers))
+		for _, s := range leaderboardSubscribers {
+			subscriptions = append(subscriptions, s)
+		}
+		leaderboardMutex.Unlock()
+
+		now := time.Now()
+		for _, s := range subscriptions {
+			leaderboard, err := buildLeaderboard(s.Period, s.Limit, now)
+			if err != nil {
+				continue
+			}
+			sendMessage(s.Player, Message{
+				Type: "leaderboardUpdated",
+				Data: map[string]interface{}{
+					"leaderboard": leaderboard,
+				},
+			})
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 测试中牌局和锦标赛的结果写入临时文件
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "poker-test")
+	if err != nil {
+		panic(err)
+	}
+	resultsFile = filepath.Join(dir, "results.jsonl")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// 测试排行榜：按时间范围累加现金局输赢、取最大底池、累加锦标赛积分，并能从文件重新加载
+func TestLeaderboard(t *testing.T) {
+	resultsMutex.Lock()
+	saved, savedFile := resultRecords, resultsFile
+	resultRecords, resultsFile = nil, filepath.Join(t.TempDir(), "results.jsonl")
+	resultsMutex.Unlock()
+	defer func() {
+		resultsMutex.Lock()
+		resultRecords, resultsFile = saved, savedFile
+		resultsMutex.Unlock()
+	}()
+
+	now := time.Date(2024, 5, 16, 15, 0, 0, 0, time.Local) // 周四
+	lastWeek := now.AddDate(0, 0, -7)
+	monday := time.Date(2024, 5, 13, 9, 0, 0, 0, time.Local)
+	appendResults([]ResultRecord{
+		{Type: ResultTypeHand, At: lastWeek, Name: "Alice", Net: 5000, Won: 6000},
+		{Type: ResultTypeHand, At: monday, Name: "Alice", Net: -200},
+		{Type: ResultTypeHand, At: monday, Name: "Bob", Net: 200, Won: 400},
+		{Type: ResultTypeHand, At: now, Name: "Alice", Net: 300, Won: 500},
+		{Type: ResultTypeHand, At: now, Name: "Bob", Net: -300},
+	})
+	recordTournamentResults("test_leaderboard", []TournamentResult{
+		{Name: "Carol", Place: 1}, {Name: "Bob", Place: 2}, {Name: "Alice", Place: 3},
+	})
+
+	daily, err := buildLeaderboard(LeaderboardDaily, 0, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if daily.NetChips[0].Name != "Alice" || daily.NetChips[0].Value != 300 || daily.NetChips[1].Value != -300 {
+		t.Errorf("Unexpected daily net chips: %+v", daily.NetChips)
+	}
+	weekly, _ := buildLeaderboard(LeaderboardWeekly, 0, now)
+	if !weekly.Since.Equal(time.Date(2024, 5, 13, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("Week should start on Monday, got %v", weekly.Since)
+	}
+	if weekly.NetChips[0].Value != 100 || weekly.NetChips[1].Value != -100 {
+		t.Errorf("Unexpected weekly net chips: %+v", weekly.NetChips)
+	}
+	if weekly.BiggestPot[0].Name != "Alice" || weekly.BiggestPot[0].Value != 500 {
+		t.Errorf("Unexpected weekly biggest pot: %+v", weekly.BiggestPot)
+	}
+
+	allTime, _ := buildLeaderboard(LeaderboardAllTime, 2, now)
+	if allTime.Since != nil || len(allTime.BiggestPot) != 2 || allTime.BiggestPot[0].Value != 6000 {
+		t.Errorf("Unexpected all-time biggest pot: %+v", allTime.BiggestPot)
+	}
+	if p := allTime.TournamentPoints; p[0].Name != "Carol" || p[0].Value != 3 || p[0].Rank != 1 || p[1].Value != 2 {
+		t.Errorf("Unexpected tournament points: %+v", p)
+	}
+	if _, err := buildLeaderboard("monthly", 0, now); err == nil {
+		t.Error("Unknown period should be rejected")
+	}
+
+	resultsMutex.Lock()
+	resultRecords = nil
+	resultsMutex.Unlock()
+	if err := loadResults(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, _ := buildLeaderboard(LeaderboardAllTime, 0, now)
+	if reloaded.NetChips[0].Value != 5100 || len(reloaded.TournamentPoints) != 3 {
+		t.Errorf("Reloaded leaderboard differs: %+v", reloaded)
+	}
+}
@@ -0,0 +1,189 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	ROOM_IDLE_MINUTES     = 30          // 默认没有玩家在座多久后关闭房间（分钟）
+	MAX_ROOM_IDLE_MINUTES = 24 * 60     // 房间空闲时间最长可以设置的分钟数
+	ROOM_GC_INTERVAL      = time.Minute // 检查空闲房间的间隔
+)
+
+// 关闭房间：停止计时器，结算筹码，从房间列表中删除并通知房间中的所有人
+// 计时器回调会检查房间是否还在房间列表中，所以删除后不会再触发
+// 房间删除后无法再加入，保存的筹码也一起删除，最终筹码在roomClosed中发给所有人
+func (room *GameRoom) close(reason string) {
+	room.closeIf(reason, nil)
+}
+
+// 满足条件时关闭房间：check在写锁内执行，返回错误时不关闭，检查和关闭之间房间状态不会改变
+// 房间已经关闭时什么也不做
+func (room *GameRoom) closeIf(reason string, check func() error) error {
+	room.Mutex.Lock()
+	if room.GamePhase == "closed" {
+		room.Mutex.Unlock()
+		return nil
+	}
+	if check != nil {
+		if err := check(); err != nil {
+			room.Mutex.Unlock()
+			return err
+		}
+	}
+	roomsMutex.Lock()
+	delete(rooms, room.ID)
+	roomsMutex.Unlock()
+
+	if room.TurnTimer != nil {
+		room.TurnTimer.Stop()
+		room.TurnTimer = nil
+	}
+	if room.RunItVote != nil && room.RunItVote.Timer != nil {
+		room.RunItVote.Timer.Stop()
+	}
+	for _, e := range room.Waitlist {
+		if e.OfferTimer != nil {
+			e.OfferTimer.Stop()
+		}
+	}
+	if room.Tournament != nil && room.Tournament.LevelTimer != nil {
+		room.Tournament.LevelTimer.Stop()
+		room.Tournament.LevelTimer = nil
+	}
+	recipients := room.recipients()
+	room.refundCurrentHand()
+	cashGame := room.Tournament == nil && room.MTT == nil
+	// 现金局的筹码和还没开始的锦标赛的买入存回钱包
+	walletChanged := cashGame || (room.Tournament != nil && !room.Tournament.Started)
+	balances := room.settleRoomChips()
+	var report *SessionReport
+	if cashGame {
+		report = room.sessionReport()
+	}
+	room.GamePhase = "closed"
+	room.Mutex.Unlock()
+
+	closeOverlayFeeds(room.ID)
+	log.Printf("房间 %s 已关闭: %s，结算 %d 名玩家的筹码", room.ID, reason, len(balances))
+	data := map[string]interface{}{
+		"roomId":   room.ID,
+		"reason":   reason,
+		"balances": balances,
+	}
+	// 现金局附带整场的结算报告
+	if report != nil {
+		data["report"] = report
+	}
+	sendToPlayers(recipients, Message{
+		Type: "roomClosed",
+		Data: data,
+	})
+	if walletChanged {
+		for _, p := range recipients {
+			sendWallet(p)
+		}
+	}
+	return nil
+}
+
+// 牌局进行中关闭房间时退还每个玩家本局投入的筹码（调用者需持有写锁）
+// 坐着的玩家退回到筹码中随后一起结算，现金局中途离开的玩家（离开时已经结算过）存入钱包
+func (room *GameRoom) refundCurrentHand() {
+	history := room.CurrentHand
+	if history == nil {
+		return
+	}
+	room.CurrentHand = nil
+	committed := handCommitted(history)
+	seated := make(map[string]*Player, len(room.Players)+len(room.WaitingPlayers))
+	for _, p := range append(append([]*Player{}, room.Players...), room.WaitingPlayers...) {
+		seated[p.ID] = p
+	}
+	for _, hp := range history.Players {
+		amount := committed[hp.ID]
+		if amount <= 0 {
+			continue
+		}
+		if p := seated[hp.ID]; p != nil {
+			p.Chips += amount
+		} else if hp.player != nil && room.Tournament == nil && room.MTT == nil {
+			walletDeposit(hp.player, amount)
+		}
+		log.Printf("房间 %s 关闭，退还玩家 %s 本局投入的 %d 筹码", room.ID, hp.Name, amount)
+	}
+	for _, p := range room.Players {
+		p.Bet = 0
+	}
+	room.Pot = 0
+}
+
+// 结算坐着的玩家的筹码（调用者需持有写锁）：现金局把筹码存回钱包并记录输赢，锦标赛只报告筹码，
+// 还没开始的锦标赛退还买入；返回玩家昵称 -> 筹码
+func (room *GameRoom) settleRoomChips() map[string]int {
+	seated := append(append([]*Player{}, room.Players...), room.WaitingPlayers...)
+	balances := make(map[string]int, len(seated))
+	for _, p := range seated {
+		if room.Tournament == nil && room.MTT == nil {
+			balances[p.Name] = room.recordCashOut(p).Chips
+		} else {
+			balances[p.Name] = p.Chips
+			room.refundTournamentBuyIn(p)
+		}
+	}
+	for name, chips := range balances {
+		log.Printf("结算筹码: 房间=%s, 玩家=%s, 筹码=%d", room.ID, name, chips)
+	}
+	return balances
+}
+
+// 房间空闲多久后关闭
+func (room *GameRoom) idleTimeout() time.Duration {
+	minutes := room.Settings.IdleMinutes
+	if minutes <= 0 {
+		minutes = ROOM_IDLE_MINUTES
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// 检查房间是否空闲太久（调用者需持有写锁）
+// 只有观战的人不算在座，房间里有人坐下或等待上桌时更新最后活跃时间
+func (room *GameRoom) idleExpired(now time.Time) bool {
+	if room.LastActive.IsZero() || len(room.Players)+len(room.WaitingPlayers) > 0 {
+		room.LastActive = now
+		return false
+	}
+	return now.Sub(room.LastActive) >= room.idleTimeout()
+}
+
+// 关闭所有空闲太久的房间
+func collectIdleRooms(now time.Time) {
+	roomsMutex.RLock()
+	all := make([]*GameRoom, 0, len(rooms))
+	for _, room := range rooms {
+		all = append(all, room)
+	}
+	roomsMutex.RUnlock()
+
+	for _, room := range all {
+		room.closeIf("房间长时间没有玩家，已自动关闭", func() error {
+			if !room.idleExpired(now) {
+				return errors.New("房间没有空闲")
+			}
+			return nil
+		})
+	}
+}
+
+// 定期关闭空闲的房间
+func startRoomCollector() {
+	ticker := time.NewTicker(ROOM_GC_INTERVAL)
+	for now := range ticker.C {
+		collectIdleRooms(now)
+	}
+}
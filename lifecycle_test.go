@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// 测试没有玩家在座的房间空闲太久后被关闭
+func TestCollectIdleRooms(t *testing.T) {
+	now := time.Now()
+	idle := &GameRoom{
+		ID:         "test_idle",
+		Spectators: []*Player{{ID: "s", Name: "Spectator", Chips: 300}},
+		LastActive: now.Add(-31 * time.Minute),
+	}
+	busy := &GameRoom{
+		ID:         "test_busy",
+		Players:    []*Player{{ID: "p", Name: "Player", Chips: 500}},
+		LastActive: now.Add(-time.Hour),
+	}
+	short := &GameRoom{
+		ID:         "test_short",
+		Settings:   RoomSettings{IdleMinutes: 5},
+		LastActive: now.Add(-6 * time.Minute),
+	}
+	roomsMutex.Lock()
+	for _, room := range []*GameRoom{idle, busy, short} {
+		rooms[room.ID] = room
+	}
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(rooms, busy.ID)
+		roomsMutex.Unlock()
+	}()
+	collectIdleRooms(now)
+
+	roomsMutex.RLock()
+	_, idleExists := rooms[idle.ID]
+	_, busyExists := rooms[busy.ID]
+	_, shortExists := rooms[short.ID]
+	roomsMutex.RUnlock()
+	if idleExists || shortExists {
+		t.Error("Idle rooms should be closed")
+	}
+	if !busyExists || !busy.LastActive.Equal(now) {
+		t.Error("Room with seated players should stay open and be marked active")
+	}
+	if idle.GamePhase != "closed" {
+		t.Errorf("Expected closed phase, got %s", idle.GamePhase)
+	}
+}
+
+// 测试关闭房间时坐着的玩家的筹码存回钱包，锦标赛的筹码不存入钱包
+func TestSettleRoomChips(t *testing.T) {
+	alice := &Player{ID: "la", Name: "SettleAlice", Chips: 100}
+	bob := &Player{ID: "lb", Name: "SettleBob", Chips: 250}
+	room := &GameRoom{
+		ID:             "test_settle",
+		Players:        []*Player{alice},
+		WaitingPlayers: []*Player{bob},
+		Spectators:     []*Player{{ID: "lc", Name: "SettleCarol"}},
+		BuyIns:         map[string]int{"SettleAlice": 500, "SettleBob": 200},
+	}
+	balances := room.settleRoomChips()
+	if balances["SettleAlice"] != 100 || balances["SettleBob"] != 250 || len(balances) != 2 {
+		t.Errorf("Unexpected balances: %v", balances)
+	}
+	if walletBalance(alice) != WALLET_INITIAL_BALANCE+100 || alice.Chips != 0 || len(room.CashOuts) != 2 {
+		t.Errorf("Seated chips should go back to the wallet, got %d", walletBalance(alice))
+	}
+
+	dave := &Player{ID: "ld", Name: "SettleDave", Chips: 3000}
+	tournament := &GameRoom{ID: "test_settle_tournament", Players: []*Player{dave}, Tournament: &Tournament{}}
+	if balances := tournament.settleRoomChips(); balances["SettleDave"] != 3000 || walletBalance(dave) != WALLET_INITIAL_BALANCE {
+		t.Errorf("Tournament chips should not go to the wallet: %v", balances)
+	}
+}
+
+// 测试牌局进行中关闭房间：每个玩家本局投入的筹码退还，所有钱包的总额不变
+func TestCloseRoomMidHand(t *testing.T) {
+	alice := &Player{ID: "lm_a", Name: "MidHandAlice"}
+	bob := &Player{ID: "lm_b", Name: "MidHandBob"}
+	carol := &Player{ID: "lm_c", Name: "MidHandCarol"}
+	players := []*Player{alice, bob, carol}
+	total := 0
+	for _, p := range players {
+		total += walletBalance(p)
+	}
+
+	room := &GameRoom{ID: "test_close_mid_hand", HostID: alice.ID, GamePhase: "waiting", SmallBlind: 10, BigBlind: 20}
+	for _, p := range players {
+		if err := room.buyInFromWallet(p, 500); err != nil {
+			t.Fatal(err)
+		}
+	}
+	room.Players = append([]*Player{}, players...)
+	room.beginHandHistory()
+	room.GamePhase = "preflop"
+	room.postForcedBet(alice, 10, "smallBlind", true)
+	room.postForcedBet(bob, 20, "bigBlind", true)
+	carol.Chips -= 60
+	carol.Bet = 60
+	room.Pot += 60
+	room.recordAction(carol, "raise", 60)
+
+	// 房主不能在牌局进行中关闭房间
+	roomsMutex.Lock()
+	rooms[room.ID] = room
+	roomsMutex.Unlock()
+	closeRoomCommand(alice, &Message{})
+	roomsMutex.RLock()
+	_, exists := rooms[room.ID]
+	roomsMutex.RUnlock()
+	if !exists || room.GamePhase != "preflop" {
+		t.Fatal("Host should not close the room during a hand")
+	}
+
+	// Carol加注后离开，离开时剩下的筹码已经存回钱包
+	room.recordCashOut(carol)
+	room.Players = room.Players[:2]
+
+	// 不经过房主命令在牌局进行中关闭房间时退还本局投入的筹码
+	room.close("测试")
+	if room.Pot != 0 || room.CurrentHand != nil {
+		t.Errorf("Pot should be refunded, got %d", room.Pot)
+	}
+	after := 0
+	for _, p := range players {
+		after += walletBalance(p)
+		if balance := walletBalance(p); balance != WALLET_INITIAL_BALANCE {
+			t.Errorf("%s should get every chip back, wallet %d", p.Name, balance)
+		}
+	}
+	if after != total {
+		t.Errorf("Wallet totals should be conserved: before %d, after %d", total, after)
+	}
+
+	// 已经关闭的房间不会再次结算
+	if err := room.closeIf("测试", func() error { return errors.New("不应执行") }); err != nil {
+		t.Errorf("Closing a closed room should do nothing, got %v", err)
+	}
+}
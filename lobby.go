@@ -0,0 +1,169 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	LOBBY_UPDATE_INTERVAL = 2 * time.Second // 检查大厅房间列表变化并推送给订阅者的间隔
+)
+
+// 大厅中显示的房间信息
+type LobbyRoom struct {
+	ID         string `json:"id"`
+	GameType   string `json:"gameType"` // cash或tournament（多桌锦标赛的牌桌由赛事分配座位，不在大厅中列出）
+	SmallBlind int    `json:"smallBlind"`
+	BigBlind   int    `json:"bigBlind"`
+	Ante       int    `json:"ante"`
+	Password   bool   `json:"password"` // 加入需要密码
+	Seated     int    `json:"seated"`
+	MaxPlayers int    `json:"maxPlayers"`
+	Waiting    int    `json:"waiting"`    // 等待上桌的人数（下一局上桌的和等候名单中的）
+	Waitlist   int    `json:"waitlist"`   // 其中等候名单中排队等座位的人数
+	Spectators int    `json:"spectators"` // 观战人数
+	AveragePot int    `json:"averagePot"` // 最近牌局的平均底池
+	Phase      string `json:"phase"`
+	HandCount  int    `json:"handCount"`
+}
+
+// 订阅了大厅实时更新的玩家
+var lobbySubscribers = make(map[string]*Player)
+var lobbyMutex sync.Mutex
+
+// 大厅房间信息（调用者需持有锁）
+func (room *GameRoom) lobbyInfo() LobbyRoom {
+	gameType := "cash"
+	if room.MTT != nil {
+		gameType = "mtt"
+	} else if room.Tournament != nil {
+		gameType = "tournament"
+	}
+
+	averagePot, finished := 0, 0
+	for _, history := range room.HandHistories {
+		if !history.EndedAt.IsZero() {
+			averagePot += history.Pot
+			finished++
+		}
+	}
+	if finished > 0 {
+		averagePot /= finished
+	}
+
+	return LobbyRoom{
+		ID:         room.ID,
+		GameType:   gameType,
+		SmallBlind: room.SmallBlind,
+		BigBlind:   room.BigBlind,
+		Ante:       room.Ante,
+		Password:   room.hasPassword(),
+		Seated:     len(room.Players),
+		MaxPlayers: MAX_PLAYERS,
+		Waiting:    len(room.WaitingPlayers) + len(room.Waitlist),
+		Waitlist:   len(room.Waitlist),
+		Spectators: len(room.Spectators),
+		AveragePot: averagePot,
+		Phase:      room.GamePhase,
+		HandCount:  room.HandCount,
+	}
+}
+
+// 列出所有公开房间（不包括私密房间和多桌锦标赛的牌桌），坐下人数多的排在前面
+func listLobbyRooms() []LobbyRoom {
+	roomsMutex.RLock()
+	all := make([]*GameRoom, 0, len(rooms))
+	for _, room := range rooms {
+		all = append(all, room)
+	}
+	roomsMutex.RUnlock()
+
+	list := make([]LobbyRoom, 0, len(all))
+	for _, room := range all {
+		room.Mutex.RLock()
+		if room.Settings.Visibility != RoomVisibilityPrivate && room.MTT == nil {
+			list = append(list, room.lobbyInfo())
+		}
+		room.Mutex.RUnlock()
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Seated != list[j].Seated {
+			return list[i].Seated > list[j].Seated
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// 订阅大厅：立即返回房间列表，之后列表变化时推送lobbyUpdated
+func subscribeLobby(player *Player, msg *Message) {
+	lobbyMutex.Lock()
+	lobbySubscribers[player.ID] = player
+	lobbyMutex.Unlock()
+
+	log.Printf("玩家 %s 订阅大厅", player.ID)
+	sendMessage(player, Message{
+		Type: "lobbyRooms",
+		Data: map[string]interface{}{
+			"rooms": listLobbyRooms(),
+		},
+	})
+}
+
+// 取消订阅大厅（断开连接时也会调用）
+func unsubscribeLobby(player *Player) {
+	lobbyMutex.Lock()
+	delete(lobbySubscribers, player.ID)
+	lobbyMutex.Unlock()
+}
+
+// HTTP接口：GET /api/rooms 返回公开房间列表
+func handleLobbyRooms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rooms": listLobbyRooms(),
+	})
+}
+
+// 定期检查房间列表，有变化时推送给所有大厅订阅者
+func startLobbyBroadcaster() {
+	var last []byte
+	ticker := time.NewTicker(LOBBY_UPDATE_INTERVAL)
+	for range ticker.C {
+		lobbyMutex.Lock()
+		subscribers := make([]*Player, 0, len(lobbySubscribers))
+		for _, p := range lobbySubscribers {
+			subscribers = append(subscribers, p)
+		}
+		lobbyMutex.Unlock()
+		if len(subscribers) == 0 {
+			last = nil
+			continue
+		}
+
+		list := listLobbyRooms()
+		data, err := json.Marshal(list)
+		if err != nil || bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+		sendToPlayers(subscribers, Message{
+			Type: "lobbyUpdated",
+			Data: map[string]interface{}{
+				"rooms": list,
+			},
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 测试大厅房间信息和HTTP接口
+func TestLobbyRooms(t *testing.T) {
+	room := &GameRoom{
+		ID:             "test_lobby",
+		Players:        []*Player{{ID: "a"}, {ID: "b"}},
+		WaitingPlayers: []*Player{{ID: "c"}},
+		Waitlist:       []*WaitlistEntry{{Player: &Player{ID: "d"}}},
+		GamePhase:      "flop",
+		SmallBlind:     5,
+		BigBlind:       10,
+		HandHistories: []*HandHistory{
+			{Pot: 100, EndedAt: time.Now()},
+			{Pot: 300, EndedAt: time.Now()},
+			{Pot: 999}, // 进行中的一局不计入平均底池
+		},
+	}
+	info := room.lobbyInfo()
+	if info.GameType != "cash" || info.Seated != 2 || info.Waiting != 2 || info.Waitlist != 1 || info.AveragePot != 200 || info.Phase != "flop" {
+		t.Errorf("Unexpected lobby info: %+v", info)
+	}
+	room.Tournament = newTournament(defaultTournamentSettings())
+	if room.lobbyInfo().GameType != "tournament" {
+		t.Error("Expected tournament game type")
+	}
+
+	roomsMutex.Lock()
+	rooms[room.ID] = room
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(rooms, room.ID)
+		roomsMutex.Unlock()
+	}()
+
+	recorder := httptest.NewRecorder()
+	handleLobbyRooms(recorder, httptest.NewRequest("GET", "/api/rooms", nil))
+	var body struct {
+		Rooms []LobbyRoom `json:"rooms"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, r := range body.Rooms {
+		if r.ID == room.ID {
+			found = r.BigBlind == 10 && r.MaxPlayers == MAX_PLAYERS
+		}
+	}
+	if !found {
+		t.Errorf("Expected room in lobby listing, got %+v", body.Rooms)
+	}
+
+	recorder = httptest.NewRecorder()
+	handleLobbyRooms(recorder, httptest.NewRequest("POST", "/api/rooms", nil))
+	if recorder.Code != 405 {
+		t.Errorf("Expected 405 for POST, got %d", recorder.Code)
+	}
+}
+
+// 测试多桌锦标赛的牌桌不在大厅中列出
+func TestLobbyHidesMTTTables(t *testing.T) {
+	table := &GameRoom{ID: "test_lobby_mtt", MTT: &MTT{ID: "test_lobby_mtt"}}
+	roomsMutex.Lock()
+	rooms[table.ID] = table
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(rooms, table.ID)
+		roomsMutex.Unlock()
+	}()
+
+	for _, r := range listLobbyRooms() {
+		if r.ID == table.ID {
+			t.Error("MTT tables should not be listed in the lobby")
+		}
+	}
+}
@@ -48,7 +48,7 @@ const (
 type Player struct {
 	ID            string          `json:"id"`
 	Name          string          `json:"name"`
-	Conn          *websocket.Conn `json:"-"`
+	Conn          Connection      `json:"-"`
 	Hand          []Card          `json:"hand"`
 	Chips         int             `json:"chips"`
 	Bet           int             `json:"bet"`
@@ -61,6 +61,13 @@ type Player struct {
 	LastHeartbeat time.Time       `json:"-"`             // 最后心跳时间
 	HeartbeatTimer *time.Timer    `json:"-"`             // 心跳超时定时器
 	HeartbeatTimeout bool         `json:"-"`             // 心跳超时标记（游戏结束后移入观战）
+	ShowLosingHands bool          `json:"-"`             // 摊牌时是否亮出输的牌（默认自动盖牌）
+	RemoteAddr    string          `json:"-"`             // 客户端地址（用于限制猜测房间ID和密码）
+	StandUpPending bool           `json:"-"`             // 本局结束后离桌兑现
+	Account       string          `json:"-"`             // 登录的账号（游客为空），登录的玩家总是使用用户名作为昵称
+	writeMutex    sync.Mutex                              // 同一个连接不能同时写入（延迟发送的消息在另一个goroutine中发送）
+	spectatorView spectatorView                           // 观战延迟的设置和等待发送的消息
+	walletClosed  bool                                    // 游客断开连接后钱包已清除（由guestWalletsMutex保护）
 }
 
 // 游戏房间
@@ -73,20 +80,55 @@ type GameRoom struct {
 	Pot               int          `json:"pot"`
 	CurrentBet        int          `json:"currentBet"`
 	DealerIndex       int          `json:"dealerIndex"`
+	BigBlindIndex     int          `json:"-"`                 // 本局大盲注的座位（两人时是庄家的下一位）
 	CurrentTurn       int          `json:"currentTurn"`
 	GamePhase         string       `json:"gamePhase"`         // preflop, flop, turn, river, showdown, waiting
 	LastRaiseIndex    int          `json:"lastRaiseIndex"`    // 最后加注的玩家索引，用于判断是否所有人都行动过一轮
 	BettingStartIndex int          `json:"bettingStartIndex"` // 当前下注轮开始行动的玩家索引
 	TurnTimer         *time.Timer  `json:"-"`                 // 当前回合的超时定时器
+	TurnDeadline      time.Time    `json:"-"`                 // 当前回合的超时时间
+	TurnRemaining     time.Duration `json:"-"`                // 暂停时当前回合剩余的时间，继续时按剩余时间计时
+	TurnTimerGen      int          `json:"-"`                 // 回合计时的编号，每次开始计时加一，已经触发的旧定时器据此放弃
 	Deck              []Card       `json:"-"`
 	BuyHandCount      map[string]int `json:"buyHandCount"`    // 玩家买一手次数（按昵称）
+	BuyIns            map[string]int `json:"-"`               // 玩家本次坐下以来从钱包买入的筹码（按昵称）
+	Settings          RoomSettings `json:"settings"`          // 房间设置
+	RunBoards         [][]Card     `json:"runBoards"`         // 多次发牌时每次的公共牌（只发一次时为空）
+	RunItVote         *RunItVote   `json:"-"`                 // 进行中的多次发牌投票
+	AllInRunout       bool         `json:"allInRunout"`       // 所有玩家全押，正在发完公共牌
+	LastAggressorID   string       `json:"-"`                 // 当前下注轮最后下注或加注的玩家ID，摊牌时先亮牌
+	ShowCardsOffer    *ShowCardsOffer `json:"-"`              // 无人跟注赢得底池后，获胜者可以选择亮牌
+	RabbitOffer       *RabbitOffer `json:"-"`                 // 无人跟注结束后没发出的公共牌
+	BombPot           *BombPot     `json:"bombPot"`           // 本局是炸弹底池时不为空
+	NextBombPot       *BombPot     `json:"nextBombPot"`       // 预约的下一局炸弹底池
+	SmallBlind        int          `json:"smallBlind"`        // 当前小盲注
+	BigBlind          int          `json:"bigBlind"`          // 当前大盲注
+	Ante              int          `json:"ante"`              // 当前前注（锦标赛后期级别）
+	Tournament        *Tournament  `json:"tournament"`        // 锦标赛状态（现金局为空）
+	MTT               *MTT         `json:"-"`                 // 所属的多桌锦标赛（普通房间为空）
+	PasswordSalt      []byte       `json:"-"`
+	PasswordHash      []byte       `json:"-"`                 // 房间密码的哈希（没有密码时为空）
+	CashOuts          []*CashOut   `json:"-"`                 // 玩家离桌兑现记录（按时间顺序）
+	Waitlist          []*WaitlistEntry `json:"-"`            // 房间坐满时排队等座位的玩家（按加入顺序）
+	HostID            string       `json:"hostId"`            // 房主（创建者，离开后转给下一个人）
+	Paused            bool         `json:"paused"`            // 房主暂停了游戏
+	BannedNames       map[string]bool `json:"-"`              // 被房主禁止加入的昵称
+	BannedAddrs       map[string]bool `json:"-"`              // 被房主禁止加入的地址
+	LastActive        time.Time    `json:"-"`                 // 最后有玩家在座的时间，空闲太久的房间会被关闭
+	HandCount         int          `json:"handCount"`         // 已开始的局数
+	CurrentHand       *HandHistory   `json:"-"`               // 当前牌局记录
+	HandHistories     []*HandHistory `json:"-"`               // 最近的牌局记录
+	Stats             map[string]*PlayerStats `json:"-"`      // 房间中的玩家统计（按昵称）
+	Sessions          map[string]*SessionHands `json:"-"`     // 现金局每个玩家的局数和单局最大输赢（按昵称）
+	ChatHistory       []*ChatMessage `json:"-"`               // 最近的聊天消息
+	ChatSeq           int          `json:"-"`                 // 聊天消息编号
+	ChatTimes         map[string][]time.Time `json:"-"`       // 每个玩家最近的发言时间（按玩家ID），用于限制发言频率
+	MutedNames        map[string]bool `json:"-"`              // 被房主禁言的昵称
+	OverlayKey        string       `json:"-"`                 // 直播画面的密钥（房主生成，为空时不能连接）
+	OverlayDelay      int          `json:"-"`                 // 直播画面延迟的秒数
 	Mutex             sync.RWMutex `json:"-"`
 }
 
-// 筹码存储（按房间ID+玩家昵称）
-var chipsStorage = make(map[string]map[string]int) // roomID -> playerName -> chips
-var chipsMutex sync.RWMutex
-
 // 用于JSON序列化的房间数据
 func (room *GameRoom) ToJSON() map[string]interface{} {
 	// 注意：调用此函数时不应该持有写锁，只应该持有读锁或没有锁
@@ -110,6 +152,7 @@ func (room *GameRoom) ToJSON() map[string]interface{} {
 			"isBig":    p.IsBig,
 			"allIn":    p.AllIn,
 			"status":   p.Status,
+			"standingUp": p.StandUpPending,
 		}
 	}
 
@@ -135,6 +178,11 @@ func (room *GameRoom) ToJSON() map[string]interface{} {
 		}
 	}
 
+	mttID := ""
+	if room.MTT != nil {
+		mttID = room.MTT.ID
+	}
+
 	result := map[string]interface{}{
 		"id":             room.ID,
 		"players":        playersData,
@@ -146,6 +194,21 @@ func (room *GameRoom) ToJSON() map[string]interface{} {
 		"dealerIndex":    room.DealerIndex,
 		"currentTurn":    room.CurrentTurn,
 		"gamePhase":      room.GamePhase,
+		"runBoards":      room.RunBoards,
+		"bombPot":        room.BombPot,
+		"nextBombPot":    room.NextBombPot,
+		"smallBlind":     room.SmallBlind,
+		"bigBlind":       room.BigBlind,
+		"ante":           room.Ante,
+		"tournament":     room.Tournament,
+		"mttId":          mttID,
+		"hasPassword":    room.hasPassword(),
+		"hostId":         room.HostID,
+		"paused":         room.Paused,
+		"turnTimeLeft":   room.turnTimeLeft(),
+		"waitlist":       room.waitlistInfo(),
+		"settings":       room.Settings,
+		"handCount":      room.HandCount,
 	}
 
 	log.Printf("ToJSON: 序列化完成，房间 %s", room.ID)
@@ -166,14 +229,44 @@ var roomsMutex sync.RWMutex
 func main() {
 	rand.Seed(time.Now().UnixNano())
 
+	if err := loadAccounts(); err != nil {
+		log.Fatalf("加载账号失败: %v", err)
+	}
+	if err := loadResults(); err != nil {
+		log.Fatalf("加载结果记录失败: %v", err)
+	}
+	if err := loadChatFilter(); err != nil {
+		log.Fatalf("加载聊天屏蔽词失败: %v", err)
+	}
+
 	http.HandleFunc("/ws", handleWebSocket)
+	http.HandleFunc("/api/rooms", handleLobbyRooms)
+	http.HandleFunc("/api/leaderboard", handleLeaderboard)
+	http.HandleFunc("/api/overlay", handleOverlay)
+	// WebSocket被屏蔽时的备用连接
+	http.HandleFunc("/api/connect", handleHTTPConnect)
+	http.HandleFunc("/api/stream", handleHTTPStream)
+	http.HandleFunc("/api/poll", handleHTTPPoll)
+	http.HandleFunc("/api/send", handleHTTPSend)
+	http.HandleFunc("/api/register", handleRegister)
+	http.HandleFunc("/api/login", handleLogin)
+	http.HandleFunc("/api/logout", handleLogout)
 	http.HandleFunc("/", serveStatic)
 
+	go startLobbyBroadcaster()
+	go startLeaderboardBroadcaster()
+	go startRoomCollector()
+
 	log.Printf("德州扑克服务器启动在端口 %s", PORT)
 	log.Fatal(http.ListenAndServe(PORT, nil))
 }
 
 func serveStatic(w http.ResponseWriter, r *http.Request) {
+	// 账号文件中有密码哈希，不能当作静态文件访问
+	if isAccountsFile(r.URL.Path) {
+		http.NotFound(w, r)
+		return
+	}
 	if r.URL.Path == "/" {
 		http.ServeFile(w, r, "index.html")
 	} else {
@@ -183,6 +276,12 @@ func serveStatic(w http.ResponseWriter, r *http.Request) {
 
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	log.Printf("收到WebSocket连接请求: %s", r.RemoteAddr)
+
+	account, ok := connectionAccount(w, r)
+	if !ok {
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket升级失败: %v", err)
@@ -197,6 +296,8 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		Chips:         INITIAL_CHIPS, // 初始筹码（一手）
 		Status:        PlayerStatusSpectating,
 		LastHeartbeat: time.Now(),
+		RemoteAddr:    clientAddr(r),
+		Account:       account,
 	}
 
 	log.Printf("新玩家连接成功: ID=%s, 地址=%s", playerID, r.RemoteAddr)
@@ -217,7 +318,7 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		err := conn.ReadJSON(&msg)
 		if err != nil {
 			log.Printf("读取消息失败 (玩家=%s): %v", playerID, err)
-			removePlayer(player)
+			playerDisconnected(player)
 			break
 		}
 
@@ -230,6 +331,14 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// 连接断开（WebSocket或HTTP连接）后移除玩家并取消订阅
+func playerDisconnected(player *Player) {
+	removePlayer(player)
+	unsubscribeLobby(player)
+	unsubscribeLeaderboard(player)
+	closeGuestWallet(player)
+}
+
 func handleMessage(player *Player, msg *Message) {
 	log.Printf("收到消息: 玩家=%s, 类型=%s", player.ID, msg.Type)
 	switch msg.Type {
@@ -247,6 +356,82 @@ func handleMessage(player *Player, msg *Message) {
 		buyHand(player, msg)
 	case "getBuyHandStats":
 		getBuyHandStats(player, msg)
+	case "getSessionReport":
+		getSessionReport(player, msg)
+	case "runItTwice":
+		handleRunItTwice(player, msg)
+	case "getHandHistory":
+		getHandHistory(player, msg)
+	case "getStats":
+		getStats(player, msg)
+	case "showCards":
+		handleShowCards(player, msg)
+	case "setAutoMuck":
+		setAutoMuck(player, msg)
+	case "rabbitHunt":
+		handleRabbitHunt(player, msg)
+	case "scheduleBombPot":
+		scheduleBombPot(player, msg)
+	case "createMTT":
+		createMTT(player, msg)
+	case "registerMTT":
+		registerMTT(player, msg)
+	case "unregisterMTT":
+		unregisterMTT(player, msg)
+	case "startMTT":
+		startMTT(player, msg)
+	case "getMTT":
+		getMTT(player, msg)
+	case "standUp", "cashOut":
+		standUp(player, msg)
+	case "joinWaitlist":
+		joinWaitlist(player, msg)
+	case "leaveWaitlist":
+		leaveWaitlist(player, msg)
+	case "acceptSeat":
+		acceptSeat(player, msg)
+	case "declineSeat":
+		declineSeat(player, msg)
+	case "transferHost":
+		transferHostCommand(player, msg)
+	case "kickPlayer":
+		kickPlayer(player, msg, false)
+	case "banPlayer":
+		kickPlayer(player, msg, true)
+	case "pauseGame":
+		setPaused(player, msg, true)
+	case "resumeGame":
+		setPaused(player, msg, false)
+	case "updateSettings":
+		updateRoomSettings(player, msg)
+	case "closeRoom":
+		closeRoomCommand(player, msg)
+	case "chat":
+		sendChat(player, msg)
+	case "getChatHistory":
+		getChatHistory(player, msg)
+	case "mutePlayer":
+		setMuted(player, msg, true)
+	case "unmutePlayer":
+		setMuted(player, msg, false)
+	case "createInvite":
+		createInvite(player, msg)
+	case "createOverlayKey":
+		createOverlayKey(player, msg)
+	case "subscribeLobby":
+		subscribeLobby(player, msg)
+	case "unsubscribeLobby":
+		unsubscribeLobby(player)
+	case "subscribeLeaderboard":
+		subscribeLeaderboard(player, msg)
+	case "unsubscribeLeaderboard":
+		unsubscribeLeaderboard(player)
+	case "getDealQuote":
+		getDealQuote(player, msg)
+	case "proposeDeal":
+		proposeDeal(player, msg)
+	case "respondDeal":
+		respondDeal(player, msg)
 	case "heartbeat":
 		// 心跳消息，已在连接层处理
 		player.LastHeartbeat = time.Now()
@@ -257,6 +442,9 @@ func handleMessage(player *Player, msg *Message) {
 
 func createRoom(player *Player, msg *Message) {
 	log.Printf("创建房间请求: 玩家=%s", player.ID)
+	if !checkNotRegisteredMTT(player) {
+		return
+	}
 
 	data, ok := msg.Data.(map[string]interface{})
 	if ok {
@@ -268,13 +456,27 @@ func createRoom(player *Player, msg *Message) {
 	if player.Name == "" {
 		player.Name = "玩家" + player.ID[:4]
 	}
+	name, err := accountPlayerName(player, player.Name)
+	if err != nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+	player.Name = name
 
 	roomID := generateID()
 	
-	// 加载玩家筹码
-	player.Chips = loadPlayerChips(roomID, player.Name)
+	// 观战时没有筹码，上桌时从钱包买入
+	player.Chips = 0
 	player.Status = PlayerStatusSpectating // 新玩家默认观战状态
 
+	settings := defaultRoomSettings()
+	if ok {
+		settings = parseRoomSettings(data)
+	}
+
 	room := &GameRoom{
 		ID:             roomID,
 		Players:        []*Player{},
@@ -283,13 +485,25 @@ func createRoom(player *Player, msg *Message) {
 		GamePhase:      "waiting",
 		CommunityCards: []Card{},
 		BuyHandCount:   make(map[string]int), // 初始化买一手次数统计
+		Settings:       settings,
+		SmallBlind:     SMALL_BLIND,
+		BigBlind:       BIG_BLIND,
+		HostID:         player.ID,
+		LastActive:     time.Now(),
+	}
+	if settings.Tournament != nil {
+		room.Tournament = newTournament(settings.Tournament)
+		room.applyBlindLevel()
+	}
+	if ok {
+		room.setPassword(roomPassword(data))
 	}
 
 	roomsMutex.Lock()
 	rooms[roomID] = room
 	roomsMutex.Unlock()
 
-	log.Printf("房间创建成功: 房间ID=%s, 玩家=%s(%s), 筹码=%d", roomID, player.Name, player.ID, player.Chips)
+	log.Printf("房间创建成功: 房间ID=%s, 玩家=%s(%s)", roomID, player.Name, player.ID)
 
 	// 发送房间信息（包含完整房间数据）
 	sendMessage(player, Message{
@@ -300,6 +514,7 @@ func createRoom(player *Player, msg *Message) {
 			"isSpectating": true,
 		},
 	})
+	sendWallet(player)
 }
 
 func joinRoom(player *Player, msg *Message) {
@@ -324,20 +539,41 @@ func joinRoom(player *Player, msg *Message) {
 		})
 		return
 	}
+	if !checkNotRegisteredMTT(player) {
+		return
+	}
 
 	playerName, _ := data["playerName"].(string)
 	if playerName == "" {
 		playerName = "玩家" + player.ID[:4]
 	}
+	playerName, err := accountPlayerName(player, playerName)
+	if err != nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
 	player.Name = playerName
 
 	log.Printf("尝试加入房间: 房间ID=%s, 玩家=%s", roomID, player.Name)
 
+	// 限制反复猜测房间ID和密码
+	if joinBlocked(player) {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "尝试次数过多，请稍后再试"},
+		})
+		return
+	}
+
 	roomsMutex.RLock()
 	room, exists := rooms[roomID]
 	roomsMutex.RUnlock()
 
 	if !exists {
+		recordJoinFailure(player)
 		log.Printf("加入房间失败: 房间不存在, 房间ID=%s", roomID)
 		sendMessage(player, Message{
 			Type: "error",
@@ -398,8 +634,42 @@ func joinRoom(player *Player, msg *Message) {
 		}
 	}
 
-	// 新玩家：加载筹码并加入观战状态
-	player.Chips = loadPlayerChips(roomID, player.Name)
+	// 新玩家：被房主禁止的玩家不能加入
+	if room.isBanned(player) {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "您已被禁止进入该房间"},
+		})
+		return
+	}
+
+	// 新玩家：私密房间或有密码的房间需要邀请链接或密码
+	password, _ := data["password"].(string)
+	invite, _ := data["invite"].(string)
+	if err := room.authorizeJoin(password, invite); err != nil {
+		room.Mutex.Unlock()
+		recordJoinFailure(player)
+		log.Printf("加入房间失败: %v, 房间ID=%s, 玩家=%s", err, roomID, player.Name)
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+
+	// 新玩家：观战人数已满时不能加入
+	if room.Settings.MaxSpectators > 0 && len(room.Spectators) >= room.Settings.MaxSpectators {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "观战人数已满"},
+		})
+		return
+	}
+
+	// 新玩家：加入观战状态，上桌时从钱包买入
+	player.Chips = 0
 	player.Status = PlayerStatusSpectating
 	
 	// 添加到观战列表
@@ -411,7 +681,7 @@ func joinRoom(player *Player, msg *Message) {
 	copy(players, room.Players)
 	room.Mutex.Unlock()
 
-	log.Printf("玩家 %s 加入房间 %s 观战，筹码=%d", player.Name, roomID, player.Chips)
+	log.Printf("玩家 %s 加入房间 %s 观战", player.Name, roomID)
 
 	// 发送房间信息给新加入的玩家
 	sendMessage(player, Message{
@@ -422,6 +692,7 @@ func joinRoom(player *Player, msg *Message) {
 			"message":      "您已进入观战状态，点击'上桌'按钮加入游戏",
 		},
 	})
+	sendWallet(player)
 
 	// 广播玩家加入消息
 	roomData := room.ToJSON()
@@ -462,12 +733,58 @@ func startGame(player *Player, msg *Message) {
 	room.Mutex.Lock()
 	log.Printf("🔍 开始游戏检查: 玩家=%s, 房间=%s, 玩家数=%d, 游戏阶段=%s", player.ID, room.ID, len(room.Players), room.GamePhase)
 
-	if len(room.Players) < MIN_PLAYERS {
+	// 锦标赛开始后，剩下两名玩家也可以继续比赛
+	minPlayers := MIN_PLAYERS
+	if room.Tournament != nil && room.Tournament.Started {
+		minPlayers = MIN_TOURNAMENT_PLAYERS
+	}
+	if room.MTT != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "锦标赛由赛事自动发牌"},
+		})
+		return
+	}
+	if room.HostID != "" && room.HostID != player.ID {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "只有房主可以开始游戏"},
+		})
+		return
+	}
+	if room.Paused {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "游戏已暂停"},
+		})
+		return
+	}
+	if room.Tournament != nil && room.Tournament.Deal != nil && !room.Tournament.Deal.Agreed {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "正在协商奖金分配，请等待所有玩家确认"},
+		})
+		return
+	}
+	if room.Tournament != nil && room.Tournament.Finished {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "锦标赛已结束"},
+		})
+		return
+	}
+
+	if len(room.Players) < minPlayers {
 		room.Mutex.Unlock()
-		log.Printf("开始游戏失败: 玩家数不足，玩家=%s, 当前玩家数=%d, 需要=%d", player.ID, len(room.Players), MIN_PLAYERS)
+		log.Printf("开始游戏失败: 玩家数不足，玩家=%s, 当前玩家数=%d, 需要=%d", player.ID, len(room.Players), minPlayers)
 		sendMessage(player, Message{
 			Type: "error",
-			Data: map[string]string{"message": "至少需要4个玩家才能开始游戏"},
+			Data: map[string]string{"message": fmt.Sprintf("至少需要%d个玩家才能开始游戏", minPlayers)},
 		})
 		return
 	}
@@ -481,6 +798,11 @@ func startGame(player *Player, msg *Message) {
 
 	log.Printf("✅ 玩家 %s 开始游戏，房间 %s，玩家数: %d, 游戏阶段: %s", player.Name, room.ID, len(room.Players), room.GamePhase)
 
+	// 锦标赛第一局开始时记录参赛人数并启动盲注级别定时器
+	if room.Tournament != nil && !room.Tournament.Started {
+		room.startTournament()
+	}
+
 	// 开始新游戏（startNewHand会自己管理锁）
 	room.Mutex.Unlock()
 	log.Printf("准备调用startNewHand，房间 %s", room.ID)
@@ -493,6 +815,35 @@ func startGame(player *Player, msg *Message) {
 	log.Printf("startNewHand执行完成，房间 %s", room.ID)
 }
 
+// 下强制注（盲注或前注），筹码不足时全押，返回实际下注金额（调用者需持有写锁）
+// countsAsBet为true时计入本轮下注（盲注），前注只进入底池
+func (room *GameRoom) postForcedBet(p *Player, amount int, action string, countsAsBet bool) int {
+	if amount > p.Chips {
+		amount = p.Chips
+	}
+	p.Chips -= amount
+	if countsAsBet {
+		p.Bet += amount
+	}
+	room.Pot += amount
+	if p.Chips == 0 {
+		p.AllIn = true
+	}
+	room.recordAction(p, action, amount)
+	return amount
+}
+
+// 是否已经没有玩家需要行动：没有未全押的玩家，或者只剩一个且他已经跟上当前下注（调用者需持有锁）
+func (room *GameRoom) noActionLeft() bool {
+	canAct := []*Player{}
+	for _, p := range room.Players {
+		if !p.Folded && !p.AllIn {
+			canAct = append(canAct, p)
+		}
+	}
+	return len(canAct) == 0 || (len(canAct) == 1 && canAct[0].Bet >= room.CurrentBet)
+}
+
 func startNewHand(room *GameRoom) {
 	// 注意：调用此函数时不应该持有room.Mutex锁
 	log.Printf("startNewHand开始执行，房间 %s", room.ID)
@@ -510,6 +861,12 @@ func startNewHand(room *GameRoom) {
 	room.Pot = 0
 	room.CurrentBet = 0
 	room.CommunityCards = []Card{}
+	room.RunBoards = nil
+	room.RunItVote = nil
+	room.AllInRunout = false
+	room.LastAggressorID = ""
+	room.ShowCardsOffer = nil
+	room.RabbitOffer = nil
 	room.GamePhase = "preflop"
 	log.Printf("游戏状态已重置，房间 %s", room.ID)
 
@@ -528,9 +885,17 @@ func startNewHand(room *GameRoom) {
 	// 设置庄家
 	room.DealerIndex = (room.DealerIndex + 1) % len(room.Players)
 
-	// 设置大小盲注
+	// 设置大小盲注（只剩两名玩家时庄家下小盲注）
 	smallBlindIndex := (room.DealerIndex + 1) % len(room.Players)
 	bigBlindIndex := (room.DealerIndex + 2) % len(room.Players)
+	if len(room.Players) == 2 {
+		smallBlindIndex = room.DealerIndex
+		bigBlindIndex = (room.DealerIndex + 1) % len(room.Players)
+	}
+	room.BigBlindIndex = bigBlindIndex
+	if room.Tournament != nil {
+		room.applyBlindLevel()
+	}
 
 	for i, p := range room.Players {
 		p.IsDealer = (i == room.DealerIndex)
@@ -559,23 +924,46 @@ func startNewHand(room *GameRoom) {
 		}
 	}
 
-	// 下大小盲注
-	room.Players[smallBlindIndex].Bet = SMALL_BLIND
-	room.Players[smallBlindIndex].Chips -= SMALL_BLIND
-	room.Players[bigBlindIndex].Bet = BIG_BLIND
-	room.Players[bigBlindIndex].Chips -= BIG_BLIND
+	// 开始记录本局（在下盲注之前记录起始筹码）
+	room.beginHandHistory()
+
+	// 预约了炸弹底池时，本局所有玩家下前注，直接从翻牌开始
+	room.BombPot = room.NextBombPot
+	room.NextBombPot = nil
+	if room.BombPot != nil {
+		if err := room.startBombPot(); err != nil {
+			log.Printf("炸弹底池发翻牌失败: %v，房间 %s", err, room.ID)
+			room.Mutex.Unlock()
+			return
+		}
+		log.Printf("本局为炸弹底池，前注 %d，双公共牌: %v，房间 %s", room.BombPot.Ante, room.BombPot.DoubleBoard, room.ID)
+	} else {
+		// 下前注（锦标赛后期级别）
+		if room.Ante > 0 {
+			for _, p := range room.Players {
+				room.postForcedBet(p, room.Ante, "ante", false)
+			}
+		}
+
+		// 下大小盲注（筹码不足时全押）
+		room.postForcedBet(room.Players[smallBlindIndex], room.SmallBlind, "smallBlind", true)
+		room.postForcedBet(room.Players[bigBlindIndex], room.BigBlind, "bigBlind", true)
+		room.CurrentBet = room.BigBlind
 
-	room.Pot = SMALL_BLIND + BIG_BLIND
-	room.CurrentBet = BIG_BLIND
-	room.CurrentTurn = (bigBlindIndex + 1) % len(room.Players)
-	// 在翻牌前，初始化为-1，表示还没有人加注（大盲注不算加注，只是初始下注）
-	// 在nextTurn中会特殊处理翻牌前的情况，确保大盲注也行动后才能进入下一阶段
-	room.LastRaiseIndex = -1
-	room.BettingStartIndex = (bigBlindIndex + 1) % len(room.Players) // 翻牌前从大盲注下一位开始
+		room.CurrentTurn = (bigBlindIndex + 1) % len(room.Players)
+		// 在翻牌前，初始化为-1，表示还没有人加注（大盲注不算加注，只是初始下注）
+		// 在nextTurn中会特殊处理翻牌前的情况，确保大盲注也行动后才能进入下一阶段
+		room.LastRaiseIndex = -1
+		room.BettingStartIndex = (bigBlindIndex + 1) % len(room.Players) // 翻牌前从大盲注下一位开始
+	}
+
+	// 强制下注后可能已经没有玩家可以行动（短筹码全押）
+	noActionLeft := room.noActionLeft()
+	handNumber := room.HandCount
 
 	// 跳过已弃牌和全押的玩家，找到第一个可以行动的玩家并启动超时定时器
 	startTurn := room.CurrentTurn
-	for i := 0; i < len(room.Players); i++ {
+	for i := 0; i < len(room.Players) && !noActionLeft; i++ {
 		p := room.Players[room.CurrentTurn]
 		if !p.Folded && !p.AllIn {
 			// 启动超时定时器（1分钟）
@@ -644,6 +1032,16 @@ func startNewHand(room *GameRoom) {
 	}
 
 	log.Printf("✅ 游戏已开始，房间 %s，已广播给 %d 个玩家，%d 个观战者，%d 个等待玩家收到等待消息", room.ID, len(players), len(spectators), len(waitingPlayers))
+
+	// 强制下注后没有玩家可以行动，直接发完公共牌并开牌
+	if noActionLeft {
+		room.Mutex.Lock()
+		if room.HandCount == handNumber && room.GamePhase != "waiting" && !room.AllInRunout {
+			finishAllInHand(room) // 会释放锁
+		} else {
+			room.Mutex.Unlock()
+		}
+	}
 }
 
 func handleAction(player *Player, msg *Message) {
@@ -655,6 +1053,16 @@ func handleAction(player *Player, msg *Message) {
 	room.Mutex.Lock()
 	// 注意：不在defer中解锁，因为需要在函数中间解锁
 
+	// 暂停期间不接受任何操作（也不取消回合计时）
+	if room.Paused {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "游戏已暂停"},
+		})
+		return
+	}
+
 	// 取消当前回合的超时定时器
 	if room.TurnTimer != nil {
 		room.TurnTimer.Stop()
@@ -687,6 +1095,17 @@ func handleAction(player *Player, msg *Message) {
 		return
 	}
 
+	// 所有玩家已全押（包括多次发牌投票期间），不再接受下注操作
+	if room.AllInRunout {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "所有玩家已全押，正在发牌"},
+		})
+		return
+	}
+
+	committed := 0 // 本次行动投入的筹码，用于牌局记录
 	switch action {
 	case "fold":
 		room.Players[playerIndex].Folded = true
@@ -703,6 +1122,7 @@ func handleAction(player *Player, msg *Message) {
 			room.Players[playerIndex].Bet += callAmount
 			room.Players[playerIndex].Chips -= callAmount
 			room.Pot += callAmount
+			committed = callAmount
 			// 如果跟注后筹码为0，确保AllIn标志已设置
 			if room.Players[playerIndex].Chips == 0 {
 				room.Players[playerIndex].AllIn = true
@@ -712,11 +1132,11 @@ func handleAction(player *Player, msg *Message) {
 	case "raise":
 		raiseAmount := int(amount)
 		// 验证最小加注金额（最小加注 = 大盲注）
-		if raiseAmount < BIG_BLIND {
+		if raiseAmount < room.BigBlind {
 			room.Mutex.Unlock()
 			sendMessage(player, Message{
 				Type: "error",
-				Data: map[string]string{"message": fmt.Sprintf("最小加注金额为 %d", BIG_BLIND)},
+				Data: map[string]string{"message": fmt.Sprintf("最小加注金额为 %d", room.BigBlind)},
 			})
 			return
 		}
@@ -766,6 +1186,7 @@ func handleAction(player *Player, msg *Message) {
 		room.Players[playerIndex].Bet = newTotalBet
 		room.Players[playerIndex].Chips -= totalNeeded
 		room.Pot += totalNeeded
+		committed = totalNeeded
 
 		// 如果玩家全押后筹码为0，确保AllIn标志已设置
 		if room.Players[playerIndex].Chips == 0 {
@@ -777,6 +1198,7 @@ func handleAction(player *Player, msg *Message) {
 		if newTotalBet > room.CurrentBet {
 			room.CurrentBet = newTotalBet
 			room.LastRaiseIndex = playerIndex // 记录最后加注的玩家
+			room.LastAggressorID = room.Players[playerIndex].ID
 		}
 	case "check":
 		// 检查是否可以过牌
@@ -789,6 +1211,7 @@ func handleAction(player *Player, msg *Message) {
 			return
 		}
 	}
+	room.recordAction(room.Players[playerIndex], action, committed)
 
 	// 移动到下一个玩家
 	gameEnded := nextTurn(room)
@@ -850,14 +1273,12 @@ func nextTurn(room *GameRoom) bool {
 	if len(activePlayers) == 1 {
 		log.Printf("只剩一个未弃牌玩家 %s，自动获胜，房间 %s", activePlayers[0].Name, room.ID)
 		activePlayers[0].Chips += room.Pot
-		// 保存获胜者筹码
-		savePlayerChips(room.ID, activePlayers[0].Name, activePlayers[0].Chips)
 		room.GamePhase = "showdown"
 		
-		// 保存所有玩家的筹码
-		for _, p := range room.Players {
-			savePlayerChips(room.ID, p.Name, p.Chips)
-		}
+		room.offerShowCards(activePlayers[0])
+		room.offerRabbit()
+		rabbitAvailable := room.RabbitOffer != nil
+		room.finishHandHistory(room.Pot, activePlayers, "", nil)
 		
 		// 检查并处理心跳超时的玩家（游戏结束后移入观战）
 		timeoutPlayers := []*Player{}
@@ -890,6 +1311,10 @@ func nextTurn(room *GameRoom) bool {
 				p.HeartbeatTimeout = false
 				room.Spectators = append(room.Spectators, p)
 			}
+			// 现金局离开座位时筹码存回钱包
+			if room.Tournament == nil && room.MTT == nil {
+				room.recordCashOut(p)
+			}
 		}
 		
 		// 准备广播消息（在释放锁之前复制所有需要的数据）
@@ -905,26 +1330,19 @@ func nextTurn(room *GameRoom) bool {
 		copy(communityCardsCopy, room.CommunityCards)
 		room.Mutex.Unlock()
 
-		// 准备所有玩家的手牌信息
-		allPlayersHands := make([]map[string]interface{}, len(players))
-		for i, p := range players {
-			allPlayersHands[i] = map[string]interface{}{
-				"id":     p.ID,
-				"name":   p.Name,
-				"hand":   p.Hand,
-				"folded": p.Folded,
-				"chips":  p.Chips,
-			}
-		}
+		// 无人跟注，不亮出任何手牌（获胜者可以之后选择亮牌）
+		allPlayersHands := showdownHands(players, nil)
 
 		msg := Message{
 			Type: "gameEnded",
 			Data: map[string]interface{}{
-				"winner":         activePlayers[0],
+				"winner":         publicPlayer(activePlayers[0]),
 				"pot":            potCopy,
 				"winningHand":    "",
 				"allHands":       allPlayersHands,
 				"communityCards": communityCardsCopy,
+				"uncontested":    true,
+				"rabbitHunt":     rabbitAvailable, // 可以请求查看没发出的公共牌
 			},
 		}
 		// 广播给游戏中的玩家
@@ -959,11 +1377,13 @@ func nextTurn(room *GameRoom) bool {
 				r.TurnTimer = nil
 				log.Printf("游戏结束，已停止超时定时器，房间 %s", r.ID)
 			}
+			tournamentMsgs := r.checkTournamentEliminations()
 			r.GamePhase = "waiting"
 			// 重置游戏状态（为新一局游戏做准备）
 			r.Pot = 0
 			r.CurrentBet = 0
 			r.CommunityCards = []Card{}
+			r.RunBoards = nil
 			r.LastRaiseIndex = -1
 			r.BettingStartIndex = -1
 			r.CurrentTurn = -1
@@ -984,23 +1404,7 @@ func nextTurn(room *GameRoom) bool {
 			// 将等待列表中的玩家加入到游戏中
 			if len(r.WaitingPlayers) > 0 {
 				log.Printf("游戏结束，将 %d 个等待玩家加入到游戏中，房间 %s", len(r.WaitingPlayers), r.ID)
-				for _, waitingPlayer := range r.WaitingPlayers {
-					if len(r.Players) < MAX_PLAYERS {
-						r.Players = append(r.Players, waitingPlayer)
-						waitingPlayer.Hand = []Card{}
-						waitingPlayer.Bet = 0
-						waitingPlayer.Folded = false
-						waitingPlayer.AllIn = false
-						waitingPlayer.IsDealer = false
-						waitingPlayer.IsSmall = false
-						waitingPlayer.IsBig = false
-						if waitingPlayer.Chips == 0 {
-							waitingPlayer.Chips = INITIAL_CHIPS
-						}
-						log.Printf("等待玩家 %s 已加入游戏，房间 %s，当前玩家数: %d", waitingPlayer.Name, r.ID, len(r.Players))
-					}
-				}
-				r.WaitingPlayers = []*Player{}
+				r.seatWaitingPlayers()
 
 				allPlayers := make([]*Player, len(r.Players))
 				copy(allPlayers, r.Players)
@@ -1039,6 +1443,9 @@ func nextTurn(room *GameRoom) bool {
 					}
 				}
 			}
+			r.sendTournamentMessages(tournamentMsgs)
+			r.processStandUps()
+			r.refreshWaitlist(false)
 			log.Printf("✅ 游戏状态已重置为waiting，房间 %s，玩家数: %d，游戏阶段: %s", r.ID, len(r.Players), r.GamePhase)
 		}
 		return true // 游戏结束，锁已释放
@@ -1080,7 +1487,7 @@ func nextTurn(room *GameRoom) bool {
 			// 需要确保所有人都行动过一轮
 			if room.GamePhase == "preflop" {
 				// 翻牌前，需要轮到大盲注且大盲注已行动
-				bigBlindIndex := (room.DealerIndex + 2) % len(room.Players)
+				bigBlindIndex := room.BigBlindIndex
 				bigBlindPlayer := room.Players[bigBlindIndex]
 				// 如果当前轮到大盲注，且大盲注已行动（下注相等或全押），可以进入下一阶段
 				if room.CurrentTurn == bigBlindIndex && (bigBlindPlayer.Bet == room.CurrentBet || bigBlindPlayer.AllIn || bigBlindPlayer.Folded) {
@@ -1128,123 +1535,9 @@ func nextTurn(room *GameRoom) bool {
 		// 如果所有玩家都已全押，需要特殊处理：直接发完所有公共牌并开牌
 		if allAllIn && activePlayersCount > 1 {
 			log.Printf("所有玩家都已全押，直接发完公共牌并开牌，房间 %s，当前阶段: %s", room.ID, oldPhase)
-			// 如果还没发完所有公共牌，先发完
-			if oldPhase == "preflop" {
-				// 发翻牌
-				for i := 0; i < 3; i++ {
-					card, err := drawCard(&room.Deck)
-					if err != nil {
-						log.Printf("发翻牌失败（全押）: %v，房间 %s", err, room.ID)
-						room.Mutex.Unlock()
-						return true
-					}
-					room.CommunityCards = append(room.CommunityCards, card)
-				}
-				room.GamePhase = "flop"
-				log.Printf("发翻牌，房间 %s", room.ID)
-			}
-			if room.GamePhase == "flop" {
-				// 发转牌
-				card, err := drawCard(&room.Deck)
-				if err != nil {
-					log.Printf("发转牌失败（全押）: %v，房间 %s", err, room.ID)
-					room.Mutex.Unlock()
-					return true
-				}
-				room.CommunityCards = append(room.CommunityCards, card)
-				room.GamePhase = "turn"
-				log.Printf("发转牌，房间 %s", room.ID)
-			}
-			if room.GamePhase == "turn" {
-				// 发河牌
-				card, err := drawCard(&room.Deck)
-				if err != nil {
-					log.Printf("发河牌失败（全押）: %v，房间 %s", err, room.ID)
-					room.Mutex.Unlock()
-					return true
-				}
-				room.CommunityCards = append(room.CommunityCards, card)
-				room.GamePhase = "river"
-				log.Printf("发河牌，房间 %s", room.ID)
-			}
-
-			// 先广播公共牌更新，让前端显示所有公共牌
-			allPlayersForUpdate := make([]*Player, len(room.Players))
-			copy(allPlayersForUpdate, room.Players)
-			// 在释放锁之前复制需要的数据
-			communityCardsCopy := make([]Card, len(room.CommunityCards))
-			copy(communityCardsCopy, room.CommunityCards)
-			potCopy := room.Pot
-			currentBetCopy := room.CurrentBet
-			dealerIndexCopy := room.DealerIndex
-			currentTurnCopy := room.CurrentTurn
-			gamePhaseCopy := room.GamePhase
-			// 复制等待列表中的玩家（观战者）- 必须在锁内复制
-			waitingPlayersForUpdate := make([]*Player, len(room.WaitingPlayers))
-			copy(waitingPlayersForUpdate, room.WaitingPlayers)
-			room.Mutex.Unlock()
-
-			// 在锁外构建房间数据
-			playersDataForUpdate := make([]map[string]interface{}, len(allPlayersForUpdate))
-			for i, p := range allPlayersForUpdate {
-				playersDataForUpdate[i] = map[string]interface{}{
-					"id":       p.ID,
-					"name":     p.Name,
-					"chips":    p.Chips,
-					"bet":      p.Bet,
-					"folded":   p.Folded,
-					"allIn":    p.AllIn,
-					"hand":     p.Hand,
-					"isDealer": p.IsDealer,
-					"isSmall":  p.IsSmall,
-					"isBig":    p.IsBig,
-				}
-			}
-			roomDataForUpdate := map[string]interface{}{
-				"id":             room.ID,
-				"players":        playersDataForUpdate,
-				"communityCards": communityCardsCopy,
-				"pot":            potCopy,
-				"currentBet":     currentBetCopy,
-				"dealerIndex":    dealerIndexCopy,
-				"currentTurn":    currentTurnCopy,
-				"gamePhase":      gamePhaseCopy,
-			}
-
-			updateMsg := Message{
-				Type: "roomUpdated",
-				Data: map[string]interface{}{
-					"room": roomDataForUpdate,
-				},
-			}
-			// 广播给游戏中的玩家
-			for _, p := range allPlayersForUpdate {
-				if p.Conn != nil {
-					sendMessage(p, updateMsg)
-				}
-			}
-			// 也广播给等待列表中的玩家（观战者）
-			for _, p := range waitingPlayersForUpdate {
-				if p.Conn != nil {
-					sendMessage(p, updateMsg)
-				}
-			}
-
-			// 等待一小段时间让前端显示公共牌
-			time.Sleep(500 * time.Millisecond)
-
-			// 重新获取锁并进入比牌阶段
-			roomsMutex.RLock()
-			r, exists := rooms[room.ID]
-			roomsMutex.RUnlock()
-			if !exists {
-				return true
-			}
-			r.Mutex.Lock()
-			room = r
-			room.GamePhase = "showdown"
-			determineWinner(room)
-			return true // 游戏结束，锁已被determineWinner释放
+			// 发完剩余公共牌并开牌（可能先询问是否多次发牌），会释放锁
+			finishAllInHand(room)
+			return true // 游戏结束，锁已释放
 		}
 
 		// 如果当前阶段是river，调用advancePhase会进入showdown并调用determineWinner
@@ -1282,11 +1575,13 @@ func nextTurn(room *GameRoom) bool {
 						// 可以过牌，自动过牌
 						log.Printf("玩家 %s 自动过牌（下注已匹配）", p.Name)
 						// 过牌不需要改变状态，继续循环找下一个玩家
+						room.recordAutoAction(p, "check")
 						continue
 					} else {
 						// 无法过牌，自动弃牌
 						log.Printf("玩家 %s 无法过牌（需要跟注 %d），自动弃牌", p.Name, room.CurrentBet-p.Bet)
 						p.Folded = true
+						room.recordAutoAction(p, "fold")
 						// 继续循环找下一个玩家
 						continue
 					}
@@ -1316,126 +1611,18 @@ func nextTurn(room *GameRoom) bool {
 			}
 
 			if len(remainingActivePlayers) > 1 {
-				// 如果还没发完所有公共牌，先发完
-				if room.GamePhase == "preflop" {
-					// 发翻牌
-					for i := 0; i < 3; i++ {
-						card, err := drawCard(&room.Deck)
-						if err != nil {
-							log.Printf("发翻牌失败（无人可行动）: %v，房间 %s", err, room.ID)
-							room.Mutex.Unlock()
-							return true
-						}
-						room.CommunityCards = append(room.CommunityCards, card)
-					}
-					room.GamePhase = "flop"
-					log.Printf("发翻牌，房间 %s", room.ID)
-				}
-				if room.GamePhase == "flop" {
-					// 发转牌
-					card, err := drawCard(&room.Deck)
-					if err != nil {
-						log.Printf("发转牌失败（无人可行动）: %v，房间 %s", err, room.ID)
-						room.Mutex.Unlock()
-						return true
-					}
-					room.CommunityCards = append(room.CommunityCards, card)
-					room.GamePhase = "turn"
-					log.Printf("发转牌，房间 %s", room.ID)
-				}
-				if room.GamePhase == "turn" {
-					// 发河牌
-					card, err := drawCard(&room.Deck)
-					if err != nil {
-						log.Printf("发河牌失败（无人可行动）: %v，房间 %s", err, room.ID)
-						room.Mutex.Unlock()
-						return true
-					}
-					room.CommunityCards = append(room.CommunityCards, card)
-					room.GamePhase = "river"
-					log.Printf("发河牌，房间 %s", room.ID)
-				}
-
-				// 先广播公共牌更新，让前端显示所有公共牌
-				allPlayersForUpdate := make([]*Player, len(room.Players))
-				copy(allPlayersForUpdate, room.Players)
-				// 在释放锁之前复制需要的数据
-				communityCardsCopy := make([]Card, len(room.CommunityCards))
-				copy(communityCardsCopy, room.CommunityCards)
-				potCopy := room.Pot
-				currentBetCopy := room.CurrentBet
-				dealerIndexCopy := room.DealerIndex
-				currentTurnCopy := room.CurrentTurn
-				gamePhaseCopy := room.GamePhase
-				// 复制等待列表中的玩家（观战者）
-				waitingPlayersForUpdate := make([]*Player, len(room.WaitingPlayers))
-				copy(waitingPlayersForUpdate, room.WaitingPlayers)
-				room.Mutex.Unlock()
-
-				// 在锁外构建房间数据
-				playersDataForUpdate := make([]map[string]interface{}, len(allPlayersForUpdate))
-				for i, p := range allPlayersForUpdate {
-					playersDataForUpdate[i] = map[string]interface{}{
-						"id":       p.ID,
-						"name":     p.Name,
-						"chips":    p.Chips,
-						"bet":      p.Bet,
-						"folded":   p.Folded,
-						"allIn":    p.AllIn,
-						"hand":     p.Hand,
-						"isDealer": p.IsDealer,
-						"isSmall":  p.IsSmall,
-						"isBig":    p.IsBig,
-					}
-				}
-				roomDataForUpdate := map[string]interface{}{
-					"id":             room.ID,
-					"players":        playersDataForUpdate,
-					"communityCards": communityCardsCopy,
-					"pot":            potCopy,
-					"currentBet":     currentBetCopy,
-					"dealerIndex":    dealerIndexCopy,
-					"currentTurn":    currentTurnCopy,
-					"gamePhase":      gamePhaseCopy,
-				}
-
-				// 广播公共牌更新（包括等待列表中的玩家）
-				updateMsg := Message{
-					Type: "roomUpdated",
-					Data: map[string]interface{}{
-						"room": roomDataForUpdate,
-					},
-				}
-				// 广播给游戏中的玩家
-				for _, p := range allPlayersForUpdate {
-					if p.Conn != nil {
-						sendMessage(p, updateMsg)
-					}
-				}
-				// 也广播给等待列表中的玩家（观战者）
-				for _, p := range waitingPlayersForUpdate {
-					if p.Conn != nil {
-						sendMessage(p, updateMsg)
-					}
-				}
-
-				// 等待一小段时间让前端显示公共牌
-				time.Sleep(500 * time.Millisecond)
-
-				// 重新获取锁并进入比牌阶段
-				room.Mutex.Lock()
-				room.GamePhase = "showdown"
-				room.LastRaiseIndex = -1
-				log.Printf("所有玩家都已全押，直接进入比牌阶段，房间 %s", room.ID)
-
-				// 调用determineWinner（会释放锁）
-				determineWinner(room)
-				return true // 游戏结束，锁已被determineWinner释放
+				// 发完剩余公共牌并开牌（可能先询问是否多次发牌），会释放锁
+				finishAllInHand(room)
+				return true // 游戏结束，锁已释放
 			} else if len(remainingActivePlayers) == 1 {
 				// 只剩一个玩家，直接获胜
 				log.Printf("只剩一个活跃玩家，直接获胜，房间 %s", room.ID)
 				remainingActivePlayers[0].Chips += room.Pot
 				room.GamePhase = "showdown"
+				room.offerShowCards(remainingActivePlayers[0])
+				room.offerRabbit()
+				rabbitAvailable := room.RabbitOffer != nil
+				room.finishHandHistory(room.Pot, remainingActivePlayers, "", nil)
 				// 准备广播消息（在释放锁之前复制所有需要的数据）
 				players := make([]*Player, len(room.Players))
 				copy(players, room.Players)
@@ -1447,26 +1634,19 @@ func nextTurn(room *GameRoom) bool {
 				winnerCopy := remainingActivePlayers[0]
 				room.Mutex.Unlock()
 
-				// 准备所有玩家的手牌信息
-				allPlayersHands := make([]map[string]interface{}, len(players))
-				for i, p := range players {
-					allPlayersHands[i] = map[string]interface{}{
-						"id":     p.ID,
-						"name":   p.Name,
-						"hand":   p.Hand,
-						"folded": p.Folded,
-						"chips":  p.Chips,
-					}
-				}
+				// 无人跟注，不亮出任何手牌（获胜者可以之后选择亮牌）
+				allPlayersHands := showdownHands(players, nil)
 
 				msg := Message{
 					Type: "gameEnded",
 					Data: map[string]interface{}{
-						"winner":         winnerCopy,
+						"winner":         publicPlayer(winnerCopy),
 						"pot":            potCopy,
 						"winningHand":    "",
 						"allHands":       allPlayersHands,
 						"communityCards": communityCardsCopy,
+						"uncontested":    true,
+						"rabbitHunt":     rabbitAvailable, // 可以请求查看没发出的公共牌
 					},
 				}
 				for _, p := range players {
@@ -1494,11 +1674,13 @@ func nextTurn(room *GameRoom) bool {
 						r.TurnTimer = nil
 						log.Printf("游戏结束，已停止超时定时器，房间 %s", r.ID)
 					}
+					tournamentMsgs := r.checkTournamentEliminations()
 					r.GamePhase = "waiting"
 					// 重置游戏状态（为新一局游戏做准备）
 					r.Pot = 0
 					r.CurrentBet = 0
 					r.CommunityCards = []Card{}
+					r.RunBoards = nil
 					r.LastRaiseIndex = -1
 					r.BettingStartIndex = -1
 					r.CurrentTurn = -1
@@ -1519,23 +1701,7 @@ func nextTurn(room *GameRoom) bool {
 					// 将等待列表中的玩家加入到游戏中
 					if len(r.WaitingPlayers) > 0 {
 						log.Printf("游戏结束，将 %d 个等待玩家加入到游戏中，房间 %s", len(r.WaitingPlayers), r.ID)
-						for _, waitingPlayer := range r.WaitingPlayers {
-							if len(r.Players) < MAX_PLAYERS {
-								r.Players = append(r.Players, waitingPlayer)
-								waitingPlayer.Hand = []Card{}
-								waitingPlayer.Bet = 0
-								waitingPlayer.Folded = false
-								waitingPlayer.AllIn = false
-								waitingPlayer.IsDealer = false
-								waitingPlayer.IsSmall = false
-								waitingPlayer.IsBig = false
-								if waitingPlayer.Chips == 0 {
-									waitingPlayer.Chips = 1000
-								}
-								log.Printf("等待玩家 %s 已加入游戏，房间 %s，当前玩家数: %d", waitingPlayer.Name, r.ID, len(r.Players))
-							}
-						}
-						r.WaitingPlayers = []*Player{}
+						r.seatWaitingPlayers()
 
 						allPlayers := make([]*Player, len(r.Players))
 						copy(allPlayers, r.Players)
@@ -1574,6 +1740,9 @@ func nextTurn(room *GameRoom) bool {
 							}
 						}
 					}
+					r.sendTournamentMessages(tournamentMsgs)
+					r.processStandUps()
+					r.refreshWaitlist(false)
 					log.Printf("✅ 游戏状态已重置为waiting，房间 %s，玩家数: %d，游戏阶段: %s", r.ID, len(r.Players), r.GamePhase)
 				}
 				return true
@@ -1591,6 +1760,13 @@ func (room *GameRoom) startTurnTimer() {
 		room.TurnTimer = nil
 	}
 
+	// 暂停后继续时按暂停时剩余的时间计时
+	timeout := TURN_TIMEOUT * time.Second
+	if room.TurnRemaining > 0 {
+		timeout = room.TurnRemaining
+		room.TurnRemaining = 0
+	}
+
 	// 检查当前玩家是否有效
 	if room.CurrentTurn < 0 || room.CurrentTurn >= len(room.Players) {
 		return
@@ -1601,12 +1777,21 @@ func (room *GameRoom) startTurnTimer() {
 		return
 	}
 
-	// 保存房间ID和玩家索引，避免在goroutine中访问room
+	// 暂停期间轮到新的玩家（比如有人离开），继续后给他完整的时间
+	if room.Paused {
+		room.TurnRemaining = TURN_TIMEOUT * time.Second
+		return
+	}
+
+	// 保存房间ID、玩家索引和计时编号，避免在goroutine中访问room
 	roomID := room.ID
 	playerIndex := room.CurrentTurn
+	room.TurnTimerGen++
+	gen := room.TurnTimerGen
 
 	// 创建新的定时器
-	room.TurnTimer = time.AfterFunc(TURN_TIMEOUT*time.Second, func() {
+	room.TurnDeadline = time.Now().Add(timeout)
+	room.TurnTimer = time.AfterFunc(timeout, func() {
 		// 超时处理
 		roomsMutex.RLock()
 		r, exists := rooms[roomID]
@@ -1618,6 +1803,12 @@ func (room *GameRoom) startTurnTimer() {
 
 		r.Mutex.Lock()
 
+		// 等待锁的时候定时器可能已经被停止、替换（行动、暂停后继续）或者游戏被暂停
+		if r.TurnTimerGen != gen || r.TurnTimer == nil || r.Paused {
+			r.Mutex.Unlock()
+			return
+		}
+
 		// 检查游戏状态和当前回合
 		if r.GamePhase == "showdown" || r.GamePhase == "waiting" {
 			r.Mutex.Unlock()
@@ -1663,10 +1854,12 @@ func (room *GameRoom) startTurnTimer() {
 			// 可以过牌，自动过牌
 			log.Printf("玩家 %s 自动过牌（下注已匹配）", player.Name)
 			// 过牌不需要改变状态，直接进入下一回合
+			r.recordAutoAction(player, "check")
 		} else {
 			// 无法过牌，自动弃牌
 			log.Printf("玩家 %s 无法过牌（需要跟注 %d），自动弃牌", player.Name, r.CurrentBet-player.Bet)
 			player.Folded = true
+			r.recordAutoAction(player, "fold")
 		}
 
 		// 移动到下一个玩家
@@ -1711,12 +1904,14 @@ func advancePhase(room *GameRoom) {
 			}
 			room.CommunityCards = append(room.CommunityCards, card)
 		}
+		room.recordStreet()
 		// 重置下注（新的一轮）
 		for _, p := range room.Players {
 			p.Bet = 0
 		}
 		room.CurrentBet = 0
 		room.LastRaiseIndex = -1 // 重置最后加注位置
+		room.LastAggressorID = ""
 		// 翻牌后从小盲注（庄家下一位）开始
 		smallBlindIndex := (room.DealerIndex + 1) % len(room.Players)
 		room.CurrentTurn = smallBlindIndex
@@ -1730,12 +1925,18 @@ func advancePhase(room *GameRoom) {
 			return
 		}
 		room.CommunityCards = append(room.CommunityCards, card)
+		room.recordStreet()
+		if err := room.dealSecondBoard(); err != nil {
+			log.Printf("第二套公共牌发转牌失败: %v，房间 %s", err, room.ID)
+			return
+		}
 		// 重置下注
 		for _, p := range room.Players {
 			p.Bet = 0
 		}
 		room.CurrentBet = 0
 		room.LastRaiseIndex = -1 // 重置最后加注位置
+		room.LastAggressorID = ""
 		// 从小盲注开始
 		smallBlindIndex := (room.DealerIndex + 1) % len(room.Players)
 		room.CurrentTurn = smallBlindIndex
@@ -1749,12 +1950,18 @@ func advancePhase(room *GameRoom) {
 			return
 		}
 		room.CommunityCards = append(room.CommunityCards, card)
+		room.recordStreet()
+		if err := room.dealSecondBoard(); err != nil {
+			log.Printf("第二套公共牌发河牌失败: %v，房间 %s", err, room.ID)
+			return
+		}
 		// 重置下注
 		for _, p := range room.Players {
 			p.Bet = 0
 		}
 		room.CurrentBet = 0
 		room.LastRaiseIndex = -1 // 重置最后加注位置
+		room.LastAggressorID = ""
 		// 从小盲注开始
 		smallBlindIndex := (room.DealerIndex + 1) % len(room.Players)
 		room.CurrentTurn = smallBlindIndex
@@ -1797,6 +2004,11 @@ func determineWinner(room *GameRoom) {
 
 	var winners []*Player
 	var winningHand string
+	var runResults []map[string]interface{} // 多次发牌时每次的结算结果
+	isTie := false
+	var showdownOrder []*Player          // 摊牌亮牌顺序
+	rabbitAvailable := false            // 无人跟注时可以查看没发出的公共牌
+	revealed := make(map[string][]Card) // 摊牌时亮出的手牌
 	pot := room.Pot
 
 	if len(activePlayers) == 1 {
@@ -1804,58 +2016,67 @@ func determineWinner(room *GameRoom) {
 		winners = []*Player{activePlayers[0]}
 		winners[0].Chips += pot
 		winningHand = ""
+		room.offerShowCards(winners[0])
+		room.offerRabbit()
+		rabbitAvailable = room.RabbitOffer != nil
 	} else {
-		// 计算每个玩家的最佳牌型，找出所有获胜者（可能打平）
-		var bestRank HandRank
-		bestRank.Rank = -1 // 初始化为无效值
-
-		for _, p := range activePlayers {
-			handRank := evaluateHand(p.Hand, room.CommunityCards)
-			comparison := compareHandRanks(handRank, bestRank)
-
-			if comparison > 0 {
-				// 发现更好的牌型，重置获胜者列表
-				bestRank = handRank
-				winners = []*Player{p}
-			} else if comparison == 0 {
-				// 牌型相同，加入获胜者列表（打平）
-				winners = append(winners, p)
+		// 多次发牌时底池按次数平分，每次独立结算；只发一次时就是整个底池
+		boards := room.RunBoards
+		if len(boards) < 2 {
+			boards = [][]Card{room.CommunityCards}
+		}
+		share := pot / len(boards)
+		remainder := pot % len(boards)
+		for i, board := range boards {
+			amount := share
+			if i == 0 {
+				amount += remainder // 余数给第一次发牌
+			}
+			runWinners, bestRank := awardPot(room, activePlayers, board, amount)
+			runHand := bestRank.Description
+			if len(runWinners) > 1 {
+				runHand += " (多人打平)"
+			}
+			if i == 0 {
+				winningHand = runHand
 			}
-		}
 
-		// 如果有多个获胜者，平分底池
-		if len(winners) > 1 {
-			share := pot / len(winners)
-			remainder := pot % len(winners)
-			for i, w := range winners {
-				w.Chips += share
-				// 余数给第一个玩家（或可以随机分配，这里简单处理）
-				if i == 0 {
-					w.Chips += remainder
+			// 合并获胜者（同一玩家可能赢得多次）
+			runWinnersData := make([]map[string]interface{}, len(runWinners))
+			for j, w := range runWinners {
+				runWinnersData[j] = map[string]interface{}{
+					"id":   w.ID,
+					"name": w.Name,
+				}
+				alreadyWinner := false
+				for _, existing := range winners {
+					if existing.ID == w.ID {
+						alreadyWinner = true
+						break
+					}
+				}
+				if !alreadyWinner {
+					winners = append(winners, w)
 				}
 			}
-			winningHand = bestRank.Description + " (多人打平)"
-			log.Printf("多人打平，房间 %s，获胜者数: %d，底池: %d，每人分得: %d，余数: %d",
-				room.ID, len(winners), pot, share, remainder)
-		} else if len(winners) == 1 {
-			// 只有一个获胜者
-			winners[0].Chips += pot
-			winningHand = bestRank.Description
-		} else {
-			// 理论上不应该到这里，但为了安全还是处理
-			log.Printf("警告：未找到获胜者，房间 %s", room.ID)
-			if len(activePlayers) > 0 {
-				winners = []*Player{activePlayers[0]}
-				winners[0].Chips += pot
+			if len(boards) > 1 {
+				runResults = append(runResults, map[string]interface{}{
+					"run":         i + 1,
+					"board":       board,
+					"amount":      amount,
+					"winners":     runWinnersData,
+					"winningHand": runHand,
+				})
 			}
 		}
-	}
+		isTie = len(boards) == 1 && len(winners) > 1
 
-	// 保存所有玩家的筹码
-	for _, p := range room.Players {
-		savePlayerChips(room.ID, p.Name, p.Chips)
+		// 按摊牌顺序亮牌，输的牌可以盖掉
+		showdownOrder, revealed = room.showdownReveal(activePlayers, boards)
 	}
 
+	room.finishHandHistory(pot, winners, winningHand, revealed)
+
 	// 检查并处理心跳超时的玩家（游戏结束后移入观战）
 	timeoutPlayers := []*Player{}
 	for _, p := range room.Players {
@@ -1887,6 +2108,10 @@ func determineWinner(room *GameRoom) {
 			p.HeartbeatTimeout = false
 			room.Spectators = append(room.Spectators, p)
 		}
+		// 现金局离开座位时筹码存回钱包
+		if room.Tournament == nil && room.MTT == nil {
+			room.recordCashOut(p)
+		}
 	}
 
 	// 准备广播消息（需要在锁外发送）
@@ -1899,18 +2124,17 @@ func determineWinner(room *GameRoom) {
 	// 复制公共牌（必须在锁内复制）
 	communityCardsCopy := make([]Card, len(room.CommunityCards))
 	copy(communityCardsCopy, room.CommunityCards)
+	var boardsCopy [][]Card
+	for _, board := range room.RunBoards {
+		boardsCopy = append(boardsCopy, append([]Card{}, board...))
+	}
 	room.Mutex.Unlock()
 
-	// 准备所有玩家的手牌信息（包括已弃牌的玩家）
-	allPlayersHands := make([]map[string]interface{}, len(players))
-	for i, p := range players {
-		allPlayersHands[i] = map[string]interface{}{
-			"id":     p.ID,
-			"name":   p.Name,
-			"hand":   p.Hand,
-			"folded": p.Folded,
-			"chips":  p.Chips,
-		}
+	// 准备玩家的手牌信息（只包含摊牌时亮出的手牌）
+	allPlayersHands := showdownHands(players, revealed)
+	showdownOrderIDs := make([]string, len(showdownOrder))
+	for i, p := range showdownOrder {
+		showdownOrderIDs[i] = p.ID
 	}
 
 	// 广播消息（此时锁已释放）
@@ -1918,13 +2142,16 @@ func determineWinner(room *GameRoom) {
 	msgData := map[string]interface{}{
 		"pot":            pot,
 		"winningHand":    winningHand,
-		"allHands":       allPlayersHands,    // 所有玩家的手牌
+		"allHands":       allPlayersHands,    // 玩家的手牌（盖牌和弃牌的玩家不包含手牌）
 		"communityCards": communityCardsCopy, // 公共牌（使用复制的数据）
+		"showdownOrder":  showdownOrderIDs,   // 摊牌亮牌顺序
+		"uncontested":    len(activePlayers) == 1,
+		"rabbitHunt":     rabbitAvailable, // 可以请求查看没发出的公共牌
 	}
 
 	// 兼容旧代码：winner字段（第一个获胜者）
 	if len(winners) > 0 {
-		msgData["winner"] = winners[0]
+		msgData["winner"] = publicPlayer(winners[0])
 	} else {
 		msgData["winner"] = nil
 	}
@@ -1939,7 +2166,13 @@ func determineWinner(room *GameRoom) {
 		}
 	}
 	msgData["winners"] = winnersData
-	msgData["isTie"] = len(winners) > 1 // 是否打平
+	msgData["isTie"] = isTie // 是否打平
+
+	// 多次发牌：每次的公共牌和结算结果
+	if len(runResults) > 0 {
+		msgData["boards"] = boardsCopy
+		msgData["runs"] = runResults
+	}
 
 	msg := Message{
 		Type: "gameEnded",
@@ -2011,11 +2244,14 @@ func determineWinner(room *GameRoom) {
 			}
 		}
 		
+		tournamentMsgs := r.checkTournamentEliminations()
 		r.GamePhase = "waiting"
 		// 重置游戏状态（为新一局游戏做准备）
 		r.Pot = 0
 		r.CurrentBet = 0
 		r.CommunityCards = []Card{}
+		r.RunBoards = nil
+		r.AllInRunout = false
 		r.LastRaiseIndex = -1
 		r.BettingStartIndex = -1
 		r.CurrentTurn = -1
@@ -2036,26 +2272,7 @@ func determineWinner(room *GameRoom) {
 		// 将等待列表中的玩家加入到游戏中
 		if len(r.WaitingPlayers) > 0 {
 			log.Printf("游戏结束，将 %d 个等待玩家加入到游戏中，房间 %s", len(r.WaitingPlayers), r.ID)
-			for _, waitingPlayer := range r.WaitingPlayers {
-				// 检查是否超过最大玩家数
-				if len(r.Players) < MAX_PLAYERS {
-					r.Players = append(r.Players, waitingPlayer)
-					// 初始化等待玩家的状态
-					waitingPlayer.Hand = []Card{}
-					waitingPlayer.Bet = 0
-					waitingPlayer.Folded = false
-					waitingPlayer.AllIn = false
-					waitingPlayer.IsDealer = false
-					waitingPlayer.IsSmall = false
-					waitingPlayer.IsBig = false
-					if waitingPlayer.Chips == 0 {
-						waitingPlayer.Chips = INITIAL_CHIPS // 给新玩家初始筹码
-					}
-					log.Printf("等待玩家 %s 已加入游戏，房间 %s，当前玩家数: %d", waitingPlayer.Name, r.ID, len(r.Players))
-				}
-			}
-			// 清空等待列表
-			r.WaitingPlayers = []*Player{}
+			r.seatWaitingPlayers()
 
 			// 通知所有玩家房间状态更新
 			allPlayers := make([]*Player, len(r.Players))
@@ -2096,10 +2313,62 @@ func determineWinner(room *GameRoom) {
 				}
 			}
 		}
+		r.sendTournamentMessages(tournamentMsgs)
+		r.processStandUps()
+		r.refreshWaitlist(false)
 		log.Printf("✅ 游戏状态已重置为waiting，房间 %s，玩家数: %d，游戏阶段: %s", r.ID, len(r.Players), r.GamePhase)
 	}
 }
 
+// 按一套公共牌比牌并把amount分给获胜者（可能打平），返回获胜者和最佳牌型
+// 注意：调用此函数时应该持有写锁
+func awardPot(room *GameRoom, activePlayers []*Player, board []Card, amount int) ([]*Player, HandRank) {
+	var winners []*Player
+	var bestRank HandRank
+	bestRank.Rank = -1 // 初始化为无效值
+
+	for _, p := range activePlayers {
+		handRank := evaluateHand(p.Hand, board)
+		comparison := compareHandRanks(handRank, bestRank)
+
+		if comparison > 0 {
+			// 发现更好的牌型，重置获胜者列表
+			bestRank = handRank
+			winners = []*Player{p}
+		} else if comparison == 0 {
+			// 牌型相同，加入获胜者列表（打平）
+			winners = append(winners, p)
+		}
+	}
+
+	if len(winners) > 1 {
+		// 如果有多个获胜者，平分
+		share := amount / len(winners)
+		remainder := amount % len(winners)
+		for i, w := range winners {
+			w.Chips += share
+			// 余数给第一个玩家（或可以随机分配，这里简单处理）
+			if i == 0 {
+				w.Chips += remainder
+			}
+		}
+		log.Printf("多人打平，房间 %s，获胜者数: %d，底池: %d，每人分得: %d，余数: %d",
+			room.ID, len(winners), amount, share, remainder)
+	} else if len(winners) == 1 {
+		// 只有一个获胜者
+		winners[0].Chips += amount
+	} else {
+		// 理论上不应该到这里，但为了安全还是处理
+		log.Printf("警告：未找到获胜者，房间 %s", room.ID)
+		if len(activePlayers) > 0 {
+			winners = []*Player{activePlayers[0]}
+			winners[0].Chips += amount
+		}
+	}
+
+	return winners, bestRank
+}
+
 func createDeck() []Card {
 	suits := []string{"spades", "hearts", "diamonds", "clubs"}
 	ranks := []string{"2", "3", "4", "5", "6", "7", "8", "9", "10", "J", "Q", "K", "A"}
@@ -2173,18 +2442,27 @@ func findPlayerRoom(player *Player) *GameRoom {
 }
 
 func removePlayer(player *Player) {
+	leaveMTTRegistrations(player)
 	room := findPlayerRoom(player)
 	if room != nil {
 		room.Mutex.Lock()
 		
 		// 从游戏玩家列表中移除
 		removed := false
+		seated := false
+		leftMTT := false // 多桌锦标赛中离开的玩家视为被淘汰
 		for i, p := range room.Players {
 			if p.ID == player.ID {
-				// 保存筹码
-				savePlayerChips(room.ID, player.Name, player.Chips)
+				// 现金局筹码存回钱包并记录离桌输赢，还没开始的锦标赛退还买入
+				if room.Tournament == nil && room.MTT == nil {
+					room.recordCashOut(player)
+				} else {
+					room.refundTournamentBuyIn(player)
+				}
 				room.Players = append(room.Players[:i], room.Players[i+1:]...)
 				removed = true
+				seated = true
+				leftMTT = room.MTT != nil
 				break
 			}
 		}
@@ -2193,8 +2471,6 @@ func removePlayer(player *Player) {
 		if !removed {
 			for i, p := range room.Spectators {
 				if p.ID == player.ID {
-					// 保存筹码
-					savePlayerChips(room.ID, player.Name, player.Chips)
 					room.Spectators = append(room.Spectators[:i], room.Spectators[i+1:]...)
 					removed = true
 					break
@@ -2206,13 +2482,32 @@ func removePlayer(player *Player) {
 		if !removed {
 			for i, p := range room.WaitingPlayers {
 				if p.ID == player.ID {
-					savePlayerChips(room.ID, player.Name, player.Chips)
+					if room.Tournament == nil && room.MTT == nil {
+						room.recordCashOut(player)
+					}
 					room.WaitingPlayers = append(room.WaitingPlayers[:i], room.WaitingPlayers[i+1:]...)
+					seated = true
+					leftMTT = room.MTT != nil
 					break
 				}
 			}
 		}
 
+		// 已经开始的锦标赛中离开的玩家视为被淘汰
+		var tournamentMsgs []Message
+		if room.Tournament != nil {
+			tournamentMsgs = room.tournamentPlayerLeft(player, seated)
+		}
+
+		// 离开等候名单，空出来的座位留给等候名单中的下一个人
+		waitlistChanged := room.removeFromWaitlist(player.ID) || len(room.Waitlist) > 0
+
+		// 房主离开后转给下一个人
+		var newHost *Player
+		if room.HostID == player.ID {
+			newHost = room.transferHost()
+		}
+
 		// 准备广播消息（需要在锁外发送）
 		players := make([]*Player, len(room.Players))
 		copy(players, room.Players)
@@ -2239,6 +2534,16 @@ func removePlayer(player *Player) {
 				sendMessage(p, msg)
 			}
 		}
+		if newHost != nil {
+			room.sendHostChanged(newHost)
+		}
+		if waitlistChanged {
+			room.refreshWaitlist(true)
+		}
+		if leftMTT {
+			room.MTT.tableHandEnded(room.ID, []*Player{player})
+		}
+		room.sendTournamentMessages(tournamentMsgs)
 	}
 }
 
@@ -2260,9 +2565,67 @@ func broadcastToRoom(room *GameRoom, msg Message) {
 	}
 }
 
+// 本局结束后等待列表中的玩家上桌（调用者需持有写锁）
+// 筹码只来自买入：没有筹码的玩家回到观战，需要重新上桌买入；座位已满时继续等待
+func (room *GameRoom) seatWaitingPlayers() {
+	waiting := []*Player{}
+	for _, p := range room.WaitingPlayers {
+		if p.Chips <= 0 {
+			p.Status = PlayerStatusSpectating
+			room.Spectators = append(room.Spectators, p)
+			log.Printf("等待玩家 %s 没有筹码，回到观战，房间 %s", p.Name, room.ID)
+			continue
+		}
+		if len(room.Players) >= MAX_PLAYERS {
+			waiting = append(waiting, p)
+			continue
+		}
+		p.Hand = []Card{}
+		p.Bet = 0
+		p.Folded = false
+		p.AllIn = false
+		p.IsDealer = false
+		p.IsSmall = false
+		p.IsBig = false
+		p.Status = PlayerStatusPlaying
+		room.Players = append(room.Players, p)
+		log.Printf("等待玩家 %s 已加入游戏，房间 %s，当前玩家数: %d", p.Name, room.ID, len(room.Players))
+	}
+	room.WaitingPlayers = waiting
+}
+
+// 复制房间内需要接收广播的所有玩家（游戏中、观战、等待），调用者需持有锁
+func (room *GameRoom) recipients() []*Player {
+	recipients := make([]*Player, 0, len(room.Players)+len(room.Spectators)+len(room.WaitingPlayers))
+	recipients = append(recipients, room.Players...)
+	recipients = append(recipients, room.Spectators...)
+	recipients = append(recipients, room.WaitingPlayers...)
+	return recipients
+}
+
+// 向一组玩家发送消息（在锁外调用）
+func sendToPlayers(players []*Player, msg Message) {
+	for _, p := range players {
+		if p.Conn != nil {
+			sendMessage(p, msg)
+		}
+	}
+}
+
+// 发送消息：房间信息中只保留收到的人自己的手牌，观战的人按房间的观战设置处理
 func sendMessage(player *Player, msg Message) {
+	if player.Status == PlayerStatusSpectating {
+		player.sendSpectatorMessage(msg)
+		return
+	}
+	writeMessage(player, viewFor(msg, player.ID, false))
+}
+
+func writeMessage(player *Player, msg Message) {
 	if player.Conn != nil {
+		player.writeMutex.Lock()
 		err := player.Conn.WriteJSON(msg)
+		player.writeMutex.Unlock()
 		if err != nil {
 			log.Printf("发送消息失败 (玩家=%s, 类型=%s): %v", player.ID, msg.Type, err)
 		} else {
@@ -2286,6 +2649,67 @@ func buyHand(player *Player, msg *Message) {
 
 	room.Mutex.Lock()
 
+	// 多桌锦标赛不能买一手也不能重购
+	if room.MTT != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "多桌锦标赛不能买一手"},
+		})
+		return
+	}
+
+	// 锦标赛不能买一手，只能在重购期内重购
+	if room.Tournament != nil {
+		if err := room.tournamentRebuy(player); err != nil {
+			room.Mutex.Unlock()
+			sendMessage(player, Message{
+				Type: "error",
+				Data: map[string]string{"message": err.Error()},
+			})
+			return
+		}
+		newChips := player.Chips
+		recipients := room.recipients()
+		room.Mutex.Unlock()
+
+		sendMessage(player, Message{
+			Type: "buyHandSuccess",
+			Data: map[string]interface{}{
+				"chips":  newChips,
+				"rebuy":  true,
+				"wallet": walletBalance(player),
+			},
+		})
+		sendToPlayers(recipients, Message{
+			Type: "roomUpdated",
+			Data: map[string]interface{}{
+				"room": room.ToJSON(),
+			},
+		})
+		return
+	}
+
+	// 检查买入限制（只能在两局之间补充筹码，不能超过最大买入和买一手次数上限），从钱包取出筹码
+	requested := BUY_IN_AMOUNT
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		if amount, ok := data["amount"].(float64); ok && amount > 0 {
+			requested = int(amount)
+		}
+	}
+	amount, err := room.topUpAmount(player, requested)
+	if err == nil {
+		err = walletWithdraw(player, amount)
+	}
+	if err != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+
 	// 找到玩家在房间中的位置
 	playerIndex := -1
 	for i, p := range room.Players {
@@ -2296,58 +2720,26 @@ func buyHand(player *Player, msg *Message) {
 	}
 
 	if playerIndex == -1 {
-		// 检查是否在观战列表中
-		spectatorIndex := -1
-		for i, p := range room.Spectators {
-			if p.ID == player.ID {
-				spectatorIndex = i
-				break
-			}
-		}
-		
-		if spectatorIndex != -1 {
-			// 给观战玩家增加筹码
-			room.Spectators[spectatorIndex].Chips += 500
-			newChips := room.Spectators[spectatorIndex].Chips
-			// 保存筹码
-			savePlayerChips(room.ID, player.Name, newChips)
-			// 增加买一手次数
-			if room.BuyHandCount == nil {
-				room.BuyHandCount = make(map[string]int)
-			}
-			room.BuyHandCount[player.Name]++
-			log.Printf("观战玩家 %s 买一手，筹码: %d，累计买一手次数: %d", player.Name, newChips, room.BuyHandCount[player.Name])
-			room.Mutex.Unlock()
-			// 立即发送成功消息
-			sendMessage(player, Message{
-				Type: "buyHandSuccess",
-				Data: map[string]interface{}{
-					"chips": newChips,
-				},
-			})
-			return
-		}
-		
 		// 检查是否在等待列表中
 		for i, p := range room.WaitingPlayers {
 			if p.ID == player.ID {
 				// 给等待玩家增加筹码
-				room.WaitingPlayers[i].Chips += BUY_IN_AMOUNT
+				room.WaitingPlayers[i].Chips += amount
 				newChips := room.WaitingPlayers[i].Chips
-				// 保存筹码
-				savePlayerChips(room.ID, player.Name, newChips)
 				// 增加买一手次数
 				if room.BuyHandCount == nil {
 					room.BuyHandCount = make(map[string]int)
 				}
 				room.BuyHandCount[player.Name]++
+				room.recordTopUp(player.Name, amount)
 				log.Printf("等待玩家 %s 买一手，筹码: %d，累计买一手次数: %d", player.Name, newChips, room.BuyHandCount[player.Name])
 				room.Mutex.Unlock()
 				// 立即发送成功消息
 				sendMessage(player, Message{
 					Type: "buyHandSuccess",
 					Data: map[string]interface{}{
-						"chips": newChips,
+						"chips":  newChips,
+						"wallet": walletBalance(player),
 					},
 				})
 				return
@@ -2362,15 +2754,14 @@ func buyHand(player *Player, msg *Message) {
 	}
 
 	// 增加筹码
-	room.Players[playerIndex].Chips += BUY_IN_AMOUNT
+	room.Players[playerIndex].Chips += amount
 	newChips := room.Players[playerIndex].Chips
-	// 保存筹码
-	savePlayerChips(room.ID, player.Name, newChips)
 	// 增加买一手次数
 	if room.BuyHandCount == nil {
 		room.BuyHandCount = make(map[string]int)
 	}
 	room.BuyHandCount[player.Name]++
+	room.recordTopUp(player.Name, amount)
 	log.Printf("玩家 %s 买一手，筹码: %d，累计买一手次数: %d", player.Name, newChips, room.BuyHandCount[player.Name])
 
 	// 立即发送成功消息给玩家（在广播之前）
@@ -2378,7 +2769,8 @@ func buyHand(player *Player, msg *Message) {
 	sendMessage(player, Message{
 		Type: "buyHandSuccess",
 		Data: map[string]interface{}{
-			"chips": newChips,
+			"chips":  newChips,
+			"wallet": walletBalance(player),
 		},
 	})
 
@@ -2524,7 +2916,7 @@ func handleTimeoutAction(room *GameRoom, playerIndex int) {
 	}
 
 	player := room.Players[playerIndex]
-	if player == nil || player.Folded || player.AllIn {
+	if player == nil || player.Folded || player.AllIn || room.AllInRunout || room.Paused {
 		return
 	}
 
@@ -2541,10 +2933,12 @@ func handleTimeoutAction(room *GameRoom, playerIndex int) {
 		// 可以过牌，自动过牌
 		log.Printf("玩家 %s 自动过牌（下注已匹配）", player.Name)
 		// 过牌不需要改变状态，直接进入下一回合
+		room.recordAutoAction(player, "check")
 	} else {
 		// 无法过牌，自动弃牌
 		log.Printf("玩家 %s 无法过牌（需要跟注 %d），自动弃牌", player.Name, room.CurrentBet-player.Bet)
 		player.Folded = true
+		room.recordAutoAction(player, "fold")
 	}
 
 	// 移动到下一个玩家
@@ -2604,8 +2998,10 @@ func movePlayerToSpectating(player *Player) {
 	// 从玩家列表中移除
 	for i, p := range room.Players {
 		if p.ID == player.ID {
-			// 保存筹码
-			savePlayerChips(room.ID, player.Name, player.Chips)
+			// 现金局筹码存回钱包
+			if room.Tournament == nil && room.MTT == nil {
+				room.recordCashOut(player)
+			}
 			// 移除玩家
 			room.Players = append(room.Players[:i], room.Players[i+1:]...)
 			break
@@ -2653,33 +3049,6 @@ func movePlayerToSpectating(player *Player) {
 	}
 }
 
-// 保存玩家筹码
-func savePlayerChips(roomID, playerName string, chips int) {
-	chipsMutex.Lock()
-	defer chipsMutex.Unlock()
-
-	if chipsStorage[roomID] == nil {
-		chipsStorage[roomID] = make(map[string]int)
-	}
-	chipsStorage[roomID][playerName] = chips
-	log.Printf("保存玩家筹码: 房间=%s, 玩家=%s, 筹码=%d", roomID, playerName, chips)
-}
-
-// 加载玩家筹码
-func loadPlayerChips(roomID, playerName string) int {
-	chipsMutex.RLock()
-	defer chipsMutex.RUnlock()
-
-	if chipsStorage[roomID] != nil {
-		if chips, exists := chipsStorage[roomID][playerName]; exists {
-			log.Printf("加载玩家筹码: 房间=%s, 玩家=%s, 筹码=%d", roomID, playerName, chips)
-			return chips
-		}
-	}
-	// 默认筹码
-	return 500
-}
-
 // 检查房间中是否有同名玩家
 func hasPlayerWithName(room *GameRoom, name string, excludeID string) bool {
 	// 检查游戏中的玩家
@@ -2738,6 +3107,16 @@ func joinTable(player *Player, msg *Message) {
 		return
 	}
 
+	// 多桌锦标赛的座位由赛事分配
+	if room.MTT != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "多桌锦标赛的座位由赛事分配，只能观战"},
+		})
+		return
+	}
+
 	// 检查游戏是否正在进行
 	if room.GamePhase != "waiting" {
 		room.Mutex.Unlock()
@@ -2748,16 +3127,33 @@ func joinTable(player *Player, msg *Message) {
 		return
 	}
 
-	// 检查房间是否已满
-	if len(room.Players) >= MAX_PLAYERS {
+	// 检查房间是否已满（为等候名单保留的座位不能直接坐）
+	if room.freeSeats() <= 0 {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间已满，请加入等候名单"},
+		})
+		return
+	}
+	if room.waitlistQueued() {
 		room.Mutex.Unlock()
 		sendMessage(player, Message{
 			Type: "error",
-			Data: map[string]string{"message": "房间已满"},
+			Data: map[string]string{"message": "有玩家在等候名单中排队，请加入等候名单"},
 		})
 		return
 	}
 
+	// 现金局从钱包买入（检查最低/最大买入和离桌后回来的筹码），锦标赛在开始前支付买入并发放起始筹码
+	if err := room.buyInFromWallet(player, requestedBuyIn(msg)); err != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
 	// 从观战列表移除
 	room.Spectators = append(room.Spectators[:spectatorIndex], room.Spectators[spectatorIndex+1:]...)
 
@@ -2792,6 +3188,8 @@ func joinTable(player *Player, msg *Message) {
 		}
 	}
 
+	sendWallet(player)
+
 	log.Printf("玩家 %s 成功上桌，房间 %s", player.Name, room.ID)
 }
 
@@ -0,0 +1,851 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	MTT_TABLE_SIZE      = 9    // 多桌锦标赛默认每桌人数
+	MTT_MIN_PLAYERS     = 2    // 多桌锦标赛开始的最少报名人数
+	MTT_NEXT_HAND_DELAY = 3000 // 一局结束后自动开始下一局的间隔（毫秒）
+)
+
+// 多桌锦标赛：赛事协调者管理多张牌桌（每张牌桌是一个GameRoom）
+// 负责随机分配座位、盲注级别、平衡各桌人数、拆桌合并到决赛桌，以及泡沫期的逐手同步
+// 锁顺序：先持有mtt.Mutex，再持有room.Mutex；持有房间锁时不能再获取赛事锁
+type MTT struct {
+	ID           string
+	Name         string
+	CreatorID    string
+	TableSize    int
+	Settings     *TournamentSettings
+	Registrants  []*Player          // 报名的玩家
+	Tables       []string           // 还在比赛的牌桌（房间ID）
+	Alive        map[string]*Player // 还没被淘汰的玩家
+	Entries      int
+	PrizePool    int
+	Started      bool
+	Finished     bool
+	Level        int
+	LevelEndsAt  time.Time
+	LevelTimer   *time.Timer
+	Busted       []*TournamentEntry // 按淘汰顺序
+	Results      []TournamentResult
+	HandForHand  bool            // 泡沫期逐手同步：所有牌桌打完一手后才一起开始下一手
+	PendingDeals map[string]bool // 已经安排了下一局的牌桌
+	Mutex        sync.Mutex
+}
+
+var mtts = make(map[string]*MTT)
+var mttsMutex sync.RWMutex
+
+// 当前盲注级别（调用者需持有mtt锁）
+func (mtt *MTT) currentLevel() BlindLevel {
+	if mtt.Level >= len(mtt.Settings.Levels) {
+		return mtt.Settings.Levels[len(mtt.Settings.Levels)-1]
+	}
+	return mtt.Settings.Levels[mtt.Level]
+}
+
+// 进入钱圈的名次数（调用者需持有mtt锁）
+func (mtt *MTT) paidPlaces() int {
+	if len(mtt.Settings.Payouts) < mtt.Entries {
+		return len(mtt.Settings.Payouts)
+	}
+	return mtt.Entries
+}
+
+// 用于JSON序列化的赛事数据（调用者需持有mtt锁）
+func (mtt *MTT) toJSON() map[string]interface{} {
+	registrants := make([]string, len(mtt.Registrants))
+	for i, p := range mtt.Registrants {
+		registrants[i] = p.Name
+	}
+	level := mtt.currentLevel()
+	return map[string]interface{}{
+		"id":          mtt.ID,
+		"name":        mtt.Name,
+		"tableSize":   mtt.TableSize,
+		"settings":    mtt.Settings,
+		"registrants": registrants,
+		"tables":      mtt.Tables,
+		"started":     mtt.Started,
+		"finished":    mtt.Finished,
+		"entries":     mtt.Entries,
+		"remaining":   len(mtt.Alive),
+		"prizePool":   mtt.PrizePool,
+		"level":       mtt.Level,
+		"smallBlind":  level.SmallBlind,
+		"bigBlind":    level.BigBlind,
+		"ante":        level.Ante,
+		"levelEndsAt": mtt.LevelEndsAt,
+		"handForHand": mtt.HandForHand,
+		"busted":      mtt.Busted,
+		"results":     mtt.Results,
+	}
+}
+
+// 找到赛事
+func findMTT(player *Player, msg *Message) *MTT {
+	mttID := ""
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		mttID, _ = data["mttId"].(string)
+	}
+	mttsMutex.RLock()
+	mtt, exists := mtts[mttID]
+	mttsMutex.RUnlock()
+	if !exists {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "赛事不存在"},
+		})
+		return nil
+	}
+	return mtt
+}
+
+// 创建多桌锦标赛
+func createMTT(player *Player, msg *Message) {
+	data, _ := msg.Data.(map[string]interface{})
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	if playerName, ok := data["playerName"].(string); ok && playerName != "" {
+		player.Name = playerName
+	}
+	name, err := accountPlayerName(player, player.Name)
+	if err != nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+	player.Name = name
+
+	mtt := &MTT{
+		ID:           generateID(),
+		CreatorID:    player.ID,
+		TableSize:    MTT_TABLE_SIZE,
+		Settings:     defaultTournamentSettings(),
+		Alive:        make(map[string]*Player),
+		PendingDeals: make(map[string]bool),
+	}
+	mtt.Name, _ = data["name"].(string)
+	if mtt.Name == "" {
+		mtt.Name = "多桌锦标赛 " + mtt.ID
+	}
+	if tableSize, ok := data["tableSize"].(float64); ok {
+		mtt.TableSize = int(tableSize)
+	}
+	if mtt.TableSize < MIN_TOURNAMENT_PLAYERS {
+		mtt.TableSize = MIN_TOURNAMENT_PLAYERS
+	}
+	if mtt.TableSize > MAX_PLAYERS {
+		mtt.TableSize = MAX_PLAYERS
+	}
+	if settings := parseRoomSettings(data); settings.Tournament != nil {
+		mtt.Settings = settings.Tournament
+	}
+
+	mttsMutex.Lock()
+	mtts[mtt.ID] = mtt
+	mttsMutex.Unlock()
+
+	log.Printf("玩家 %s 创建多桌锦标赛 %s（%s），每桌 %d 人", player.Name, mtt.ID, mtt.Name, mtt.TableSize)
+	mtt.Mutex.Lock()
+	mttData := mtt.toJSON()
+	mtt.Mutex.Unlock()
+	sendMessage(player, Message{
+		Type: "mttCreated",
+		Data: map[string]interface{}{
+			"mtt": mttData,
+		},
+	})
+}
+
+// 报名多桌锦标赛（已经在房间中的玩家需要先离开房间）
+func registerMTT(player *Player, msg *Message) {
+	mtt := findMTT(player, msg)
+	if mtt == nil {
+		return
+	}
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		if playerName, ok := data["playerName"].(string); ok && playerName != "" {
+			player.Name = playerName
+		}
+	}
+	if player.Name == "" {
+		player.Name = "玩家" + player.ID[:4]
+	}
+	name, err := accountPlayerName(player, player.Name)
+	if err != nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+	player.Name = name
+	if findPlayerRoom(player) != nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "请先离开当前房间再报名"},
+		})
+		return
+	}
+
+	mtt.Mutex.Lock()
+	if mtt.Started {
+		mtt.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "锦标赛已开始，无法报名"},
+		})
+		return
+	}
+	for _, p := range mtt.Registrants {
+		if p.ID == player.ID || p.Name == player.Name {
+			mtt.Mutex.Unlock()
+			sendMessage(player, Message{
+				Type: "error",
+				Data: map[string]string{"message": "已经报名或昵称重复"},
+			})
+			return
+		}
+	}
+	// 报名时从钱包支付买入，开始前退出时退还
+	if err := walletWithdraw(player, mtt.Settings.BuyIn); err != nil {
+		mtt.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+	mtt.Registrants = append(mtt.Registrants, player)
+	registrants := append([]*Player{}, mtt.Registrants...)
+	mttData := mtt.toJSON()
+	mtt.Mutex.Unlock()
+
+	log.Printf("玩家 %s 报名多桌锦标赛 %s，已报名 %d 人", player.Name, mtt.ID, len(registrants))
+	sendToPlayers(registrants, Message{
+		Type: "mttRegistered",
+		Data: map[string]interface{}{
+			"playerId": player.ID,
+			"name":     player.Name,
+			"mtt":      mttData,
+		},
+	})
+	sendWallet(player)
+}
+
+// 退出还没开始的多桌锦标赛并退还买入，没有报名或已经开始时返回false
+func (mtt *MTT) unregister(player *Player) bool {
+	mtt.Mutex.Lock()
+	index := -1
+	if !mtt.Started {
+		for i, p := range mtt.Registrants {
+			if p.ID == player.ID {
+				index = i
+				break
+			}
+		}
+	}
+	if index < 0 {
+		mtt.Mutex.Unlock()
+		return false
+	}
+	mtt.Registrants = append(mtt.Registrants[:index], mtt.Registrants[index+1:]...)
+	walletDeposit(player, mtt.Settings.BuyIn)
+	registrants := append([]*Player{}, mtt.Registrants...)
+	mttData := mtt.toJSON()
+	mtt.Mutex.Unlock()
+
+	log.Printf("玩家 %s 退出多桌锦标赛 %s，已报名 %d 人", player.Name, mtt.ID, len(registrants))
+	data := map[string]interface{}{
+		"playerId": player.ID,
+		"name":     player.Name,
+		"mtt":      mttData,
+	}
+	sendToPlayers(registrants, Message{Type: "mttUnregistered", Data: data})
+	// 退出的玩家自己收到的消息带有left
+	self := map[string]interface{}{"left": true}
+	for k, v := range data {
+		self[k] = v
+	}
+	sendMessage(player, Message{Type: "mttUnregistered", Data: self})
+	sendWallet(player)
+	return true
+}
+
+// 退出报名（开始前）
+func unregisterMTT(player *Player, msg *Message) {
+	mtt := findMTT(player, msg)
+	if mtt == nil {
+		return
+	}
+	if !mtt.unregister(player) {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "没有报名或锦标赛已开始"},
+		})
+	}
+}
+
+// 玩家报名了的、还没开始的多桌锦标赛，没有时返回nil
+func registeredMTT(player *Player) *MTT {
+	mttsMutex.RLock()
+	defer mttsMutex.RUnlock()
+	for _, mtt := range mtts {
+		mtt.Mutex.Lock()
+		registered := false
+		if !mtt.Started {
+			for _, p := range mtt.Registrants {
+				if p.ID == player.ID {
+					registered = true
+					break
+				}
+			}
+		}
+		mtt.Mutex.Unlock()
+		if registered {
+			return mtt
+		}
+	}
+	return nil
+}
+
+// 报名了多桌锦标赛的玩家在开始前不能加入或创建其他房间，返回false时已经发送了错误
+func checkNotRegisteredMTT(player *Player) bool {
+	if registeredMTT(player) == nil {
+		return true
+	}
+	sendMessage(player, Message{
+		Type: "error",
+		Data: map[string]string{"message": "已报名多桌锦标赛，请先退出报名再加入其他房间"},
+	})
+	return false
+}
+
+// 断开连接的玩家退出所有还没开始的多桌锦标赛，避免开始后给不在线的玩家发座位
+func leaveMTTRegistrations(player *Player) {
+	mttsMutex.RLock()
+	list := make([]*MTT, 0, len(mtts))
+	for _, mtt := range mtts {
+		list = append(list, mtt)
+	}
+	mttsMutex.RUnlock()
+	for _, mtt := range list {
+		mtt.unregister(player)
+	}
+}
+
+// 获取赛事状态
+func getMTT(player *Player, msg *Message) {
+	mtt := findMTT(player, msg)
+	if mtt == nil {
+		return
+	}
+	mtt.Mutex.Lock()
+	mttData := mtt.toJSON()
+	mtt.Mutex.Unlock()
+	sendMessage(player, Message{
+		Type: "mttState",
+		Data: map[string]interface{}{
+			"mtt": mttData,
+		},
+	})
+}
+
+// 开始多桌锦标赛：随机分配座位，每张牌桌创建一个房间并开始发牌（只有创建者可以开始）
+func startMTT(player *Player, msg *Message) {
+	mtt := findMTT(player, msg)
+	if mtt == nil {
+		return
+	}
+
+	mtt.Mutex.Lock()
+	if mtt.CreatorID != player.ID {
+		mtt.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "只有创建者可以开始锦标赛"},
+		})
+		return
+	}
+	if mtt.Started {
+		mtt.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "锦标赛已开始"},
+		})
+		return
+	}
+	if len(mtt.Registrants) < MTT_MIN_PLAYERS {
+		mtt.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": fmt.Sprintf("至少需要%d名玩家报名", MTT_MIN_PLAYERS)},
+		})
+		return
+	}
+
+	mtt.Started = true
+	mtt.Entries = len(mtt.Registrants)
+	mtt.PrizePool = mtt.Entries * mtt.Settings.BuyIn
+
+	// 随机分配座位，尽量平均分到每张牌桌
+	seats := append([]*Player{}, mtt.Registrants...)
+	rand.Shuffle(len(seats), func(i, j int) { seats[i], seats[j] = seats[j], seats[i] })
+	tableCount := (len(seats) + mtt.TableSize - 1) / mtt.TableSize
+	tables := make([]*GameRoom, tableCount)
+	level := mtt.currentLevel()
+	for i := range tables {
+		tables[i] = &GameRoom{
+			ID:             generateID(),
+			Players:        []*Player{},
+			Spectators:     []*Player{},
+			WaitingPlayers: []*Player{},
+			GamePhase:      "waiting",
+			CommunityCards: []Card{},
+			BuyHandCount:   make(map[string]int),
+			Settings:       defaultRoomSettings(),
+			SmallBlind:     level.SmallBlind,
+			BigBlind:       level.BigBlind,
+			Ante:           level.Ante,
+			MTT:            mtt,
+		}
+	}
+	for i, p := range seats {
+		p.Chips = mtt.Settings.StartingStack
+		p.Status = PlayerStatusPlaying
+		tables[i%tableCount].Players = append(tables[i%tableCount].Players, p)
+		mtt.Alive[p.ID] = p
+	}
+
+	roomsMutex.Lock()
+	for _, table := range tables {
+		rooms[table.ID] = table
+		mtt.Tables = append(mtt.Tables, table.ID)
+	}
+	roomsMutex.Unlock()
+
+	mtt.scheduleLevelUp()
+	mtt.updateHandForHand()
+	mttData := mtt.toJSON()
+	mtt.Mutex.Unlock()
+
+	log.Printf("多桌锦标赛 %s 开始，%d 名玩家，%d 张牌桌，奖池 %d", mtt.ID, len(seats), tableCount, mtt.PrizePool)
+	for _, table := range tables {
+		roomData := table.ToJSON()
+		for _, p := range table.Players {
+			sendMessage(p, Message{
+				Type: "mttStarted",
+				Data: map[string]interface{}{
+					"mttId":  mtt.ID,
+					"roomId": table.ID,
+					"room":   roomData,
+					"mtt":    mttData,
+				},
+			})
+		}
+	}
+	for _, table := range tables {
+		go startNewHand(table)
+	}
+}
+
+// 启动下一次升级盲注的定时器（调用者需持有mtt锁）
+func (mtt *MTT) scheduleLevelUp() {
+	duration := time.Duration(mtt.Settings.LevelSeconds) * time.Second
+	mtt.LevelEndsAt = time.Now().Add(duration)
+	mtt.LevelTimer = time.AfterFunc(duration, func() {
+		mtt.Mutex.Lock()
+		if mtt.Finished {
+			mtt.Mutex.Unlock()
+			return
+		}
+		if mtt.Level < len(mtt.Settings.Levels)-1 {
+			mtt.Level++
+		}
+		mtt.scheduleLevelUp()
+
+		// 新的盲注从每张牌桌的下一局开始生效
+		level := mtt.currentLevel()
+		recipients := []*Player{}
+		for _, table := range mtt.tableRooms() {
+			table.Mutex.Lock()
+			table.SmallBlind = level.SmallBlind
+			table.BigBlind = level.BigBlind
+			table.Ante = level.Ante
+			recipients = append(recipients, table.recipients()...)
+			table.Mutex.Unlock()
+		}
+		levelEndsAt := mtt.LevelEndsAt
+		mtt.Mutex.Unlock()
+
+		log.Printf("多桌锦标赛 %s 盲注升级到第 %d 级", mtt.ID, mtt.Level+1)
+		sendToPlayers(recipients, Message{
+			Type: "blindLevelUp",
+			Data: map[string]interface{}{
+				"mttId":       mtt.ID,
+				"level":       mtt.Level + 1,
+				"smallBlind":  level.SmallBlind,
+				"bigBlind":    level.BigBlind,
+				"ante":        level.Ante,
+				"levelEndsAt": levelEndsAt,
+				"message":     fmt.Sprintf("盲注升至 %d/%d，前注 %d，下一局生效", level.SmallBlind, level.BigBlind, level.Ante),
+			},
+		})
+	})
+}
+
+// 还在比赛的牌桌房间（调用者需持有mtt锁）
+func (mtt *MTT) tableRooms() []*GameRoom {
+	tables := []*GameRoom{}
+	roomsMutex.RLock()
+	for _, id := range mtt.Tables {
+		if room, exists := rooms[id]; exists {
+			tables = append(tables, room)
+		}
+	}
+	roomsMutex.RUnlock()
+	return tables
+}
+
+// 多桌锦标赛的牌桌一局结束：把输光的玩家移入观战，之后由赛事协调者处理淘汰、平衡和发下一局
+// 调用者需持有房间写锁，赛事的处理在单独的goroutine中进行（避免在持有房间锁时获取赛事锁）
+func (room *GameRoom) mttHandEnded() []Message {
+	startChips := make(map[string]int)
+	if n := len(room.HandHistories); n > 0 {
+		for _, hp := range room.HandHistories[n-1].Players {
+			startChips[hp.ID] = hp.StartChips
+		}
+	}
+
+	busted := []*Player{}
+	remaining := []*Player{}
+	for _, p := range room.Players {
+		if p.Chips <= 0 {
+			busted = append(busted, p)
+		} else {
+			remaining = append(remaining, p)
+		}
+	}
+	// 同一局被淘汰的玩家，本局开始时筹码少的名次靠后
+	sort.SliceStable(busted, func(i, j int) bool {
+		return startChips[busted[i].ID] < startChips[busted[j].ID]
+	})
+
+	msgs := []Message{}
+	for _, p := range busted {
+		p.Chips = 0
+		p.Status = PlayerStatusSpectating
+		room.Spectators = append(room.Spectators, p)
+		msgs = append(msgs, eliminatedMessage(&TournamentEntry{PlayerID: p.ID, Name: p.Name}, false))
+	}
+	room.Players = remaining
+
+	go room.MTT.tableHandEnded(room.ID, busted)
+	return msgs
+}
+
+// 处理一张牌桌打完一局：记录淘汰名次，结束比赛或平衡牌桌，然后安排下一局
+func (mtt *MTT) tableHandEnded(roomID string, busted []*Player) {
+	mtt.Mutex.Lock()
+	if mtt.Finished {
+		mtt.Mutex.Unlock()
+		return
+	}
+
+	notices := []func(){}
+	for _, p := range busted {
+		place := len(mtt.Alive)
+		delete(mtt.Alive, p.ID)
+		mtt.Busted = append(mtt.Busted, &TournamentEntry{PlayerID: p.ID, Name: p.Name})
+		log.Printf("多桌锦标赛 %s 玩家 %s 被淘汰，第 %d 名", mtt.ID, p.Name, place)
+		player := p
+		notices = append(notices, func() {
+			sendMessage(player, Message{
+				Type: "mttEliminated",
+				Data: map[string]interface{}{
+					"mttId": mtt.ID,
+					"place": place,
+				},
+			})
+		})
+	}
+
+	if len(mtt.Alive) <= 1 {
+		msg := mtt.finish()
+		registrants := append([]*Player{}, mtt.Registrants...)
+		winners := prizeWinners(mtt.Results, mtt.entrants())
+		mtt.Mutex.Unlock()
+		// 结束的比赛从赛事列表中删除，名次已经记入排行榜
+		mttsMutex.Lock()
+		delete(mtts, mtt.ID)
+		mttsMutex.Unlock()
+		for _, notice := range notices {
+			notice()
+		}
+		sendToPlayers(registrants, msg)
+		for _, p := range winners {
+			sendWallet(p)
+		}
+		return
+	}
+
+	mtt.balanceTables()
+	mtt.updateHandForHand()
+
+	// 逐手同步时等所有牌桌都打完这一手再一起发牌，否则空闲的牌桌（包括平衡后重新凑够人的牌桌）各自开始下一局
+	idleTables := []*GameRoom{}
+	for _, table := range mtt.tableRooms() {
+		table.Mutex.RLock()
+		idle := table.GamePhase == "waiting"
+		table.Mutex.RUnlock()
+		if idle {
+			idleTables = append(idleTables, table)
+		} else if mtt.HandForHand {
+			idleTables = nil
+			break
+		}
+	}
+	for _, table := range idleTables {
+		mtt.dealNextHand(table)
+	}
+	mtt.Mutex.Unlock()
+
+	for _, notice := range notices {
+		notice()
+	}
+}
+
+// 平衡各牌桌人数（调用者需持有mtt锁）
+// 总人数可以少开一张牌桌时拆掉人数最少的空闲牌桌；否则从人数最多的空闲牌桌移动玩家到人数最少的牌桌
+// 正在打牌的牌桌不会移出玩家，移入的玩家会在该桌本局结束后上桌
+func (mtt *MTT) balanceTables() {
+	for {
+		tables := mtt.tableRooms()
+		if len(tables) <= 1 {
+			return
+		}
+
+		counts := make(map[string]int)
+		idle := make(map[string]bool)
+		for _, table := range tables {
+			table.Mutex.RLock()
+			counts[table.ID] = len(table.Players) + len(table.WaitingPlayers)
+			idle[table.ID] = table.GamePhase == "waiting" && len(table.WaitingPlayers) == 0
+			table.Mutex.RUnlock()
+		}
+		sort.SliceStable(tables, func(i, j int) bool {
+			return counts[tables[i].ID] < counts[tables[j].ID]
+		})
+
+		needed := (len(mtt.Alive) + mtt.TableSize - 1) / mtt.TableSize
+		if needed < len(tables) {
+			// 拆掉人数最少的空闲牌桌
+			for _, table := range tables {
+				if idle[table.ID] {
+					mtt.breakTable(table, tables)
+					break
+				}
+			}
+			if len(mtt.tableRooms()) == len(tables) {
+				return // 没有可以拆的空闲牌桌，等下一桌打完
+			}
+			continue
+		}
+
+		smallest := tables[0]
+		var source *GameRoom
+		for i := len(tables) - 1; i > 0; i-- {
+			if idle[tables[i].ID] && counts[tables[i].ID]-counts[smallest.ID] > 1 {
+				source = tables[i]
+				break
+			}
+		}
+		if source == nil {
+			return
+		}
+
+		source.Mutex.Lock()
+		index := rand.Intn(len(source.Players))
+		moved := source.Players[index]
+		source.Players = append(source.Players[:index], source.Players[index+1:]...)
+		source.Mutex.Unlock()
+		log.Printf("多桌锦标赛 %s 平衡牌桌：玩家 %s 从 %s 移到 %s", mtt.ID, moved.Name, source.ID, smallest.ID)
+		mtt.seatAtTable(moved, source.ID, smallest)
+	}
+}
+
+// 拆桌：把玩家分配到其他牌桌中人数最少的位置，然后关闭这张牌桌（调用者需持有mtt锁）
+func (mtt *MTT) breakTable(table *GameRoom, tables []*GameRoom) {
+	table.Mutex.Lock()
+	players := append(table.Players, table.WaitingPlayers...)
+	table.Players = []*Player{}
+	table.WaitingPlayers = []*Player{}
+	table.Mutex.Unlock()
+
+	remaining := []string{}
+	for _, id := range mtt.Tables {
+		if id != table.ID {
+			remaining = append(remaining, id)
+		}
+	}
+	mtt.Tables = remaining
+	log.Printf("多桌锦标赛 %s 拆桌 %s，%d 名玩家分到其他牌桌，剩余 %d 张牌桌", mtt.ID, table.ID, len(players), len(mtt.Tables))
+
+	for _, p := range players {
+		var target *GameRoom
+		targetCount := 0
+		for _, other := range tables {
+			if other == table {
+				continue
+			}
+			other.Mutex.RLock()
+			count := len(other.Players) + len(other.WaitingPlayers)
+			other.Mutex.RUnlock()
+			if target == nil || count < targetCount {
+				target = other
+				targetCount = count
+			}
+		}
+		mtt.seatAtTable(p, table.ID, target)
+	}
+	// 从房间列表中删除，留在这张牌桌观战的人（被淘汰的玩家）收到roomClosed
+	table.close("牌桌已拆分")
+}
+
+// 把玩家安排到另一张牌桌：空闲牌桌直接上桌，正在打牌的牌桌本局结束后上桌（调用者需持有mtt锁）
+func (mtt *MTT) seatAtTable(p *Player, fromRoomID string, table *GameRoom) {
+	table.Mutex.Lock()
+	p.Hand = []Card{}
+	p.Bet = 0
+	p.Folded = false
+	p.AllIn = false
+	p.IsDealer = false
+	p.IsSmall = false
+	p.IsBig = false
+	p.Status = PlayerStatusPlaying
+	if table.GamePhase == "waiting" {
+		table.Players = append(table.Players, p)
+	} else {
+		table.WaitingPlayers = append(table.WaitingPlayers, p)
+	}
+	table.Mutex.Unlock()
+
+	sendMessage(p, Message{
+		Type: "tableMoved",
+		Data: map[string]interface{}{
+			"mttId":      mtt.ID,
+			"fromRoomId": fromRoomID,
+			"roomId":     table.ID,
+			"room":       table.ToJSON(),
+		},
+	})
+}
+
+// 根据剩余人数开启或关闭逐手同步：剩余人数比钱圈多一人（泡沫）且还有多张牌桌时开启（调用者需持有mtt锁）
+func (mtt *MTT) updateHandForHand() {
+	enabled := len(mtt.Tables) > 1 && len(mtt.Alive) == mtt.paidPlaces()+1
+	if enabled == mtt.HandForHand {
+		return
+	}
+	mtt.HandForHand = enabled
+	log.Printf("多桌锦标赛 %s 逐手同步: %v，剩余 %d 人", mtt.ID, enabled, len(mtt.Alive))
+
+	recipients := []*Player{}
+	for _, p := range mtt.Alive {
+		recipients = append(recipients, p)
+	}
+	go sendToPlayers(recipients, Message{
+		Type: "handForHand",
+		Data: map[string]interface{}{
+			"mttId":   mtt.ID,
+			"enabled": enabled,
+		},
+	})
+}
+
+// 延迟一段时间后开始牌桌的下一局，同一张牌桌只安排一次（调用者需持有mtt锁）
+func (mtt *MTT) dealNextHand(table *GameRoom) {
+	if mtt.PendingDeals[table.ID] {
+		return
+	}
+	mtt.PendingDeals[table.ID] = true
+	time.AfterFunc(MTT_NEXT_HAND_DELAY*time.Millisecond, func() {
+		mtt.Mutex.Lock()
+		delete(mtt.PendingDeals, table.ID)
+		active := false
+		for _, id := range mtt.Tables {
+			if id == table.ID {
+				active = !mtt.Finished
+			}
+		}
+		mtt.Mutex.Unlock()
+
+		table.Mutex.RLock()
+		ready := active && table.GamePhase == "waiting" && len(table.Players) >= MIN_TOURNAMENT_PLAYERS
+		table.Mutex.RUnlock()
+		if ready {
+			startNewHand(table)
+		}
+	})
+}
+
+// 报名的玩家（玩家ID -> 玩家），用于发放奖金（调用者需持有mtt锁）
+func (mtt *MTT) entrants() map[string]*Player {
+	entrants := make(map[string]*Player, len(mtt.Registrants))
+	for _, p := range mtt.Registrants {
+		entrants[p.ID] = p
+	}
+	return entrants
+}
+
+// 结束比赛，计算名次和奖金并存入获奖玩家的钱包（调用者需持有mtt锁）
+func (mtt *MTT) finish() Message {
+	mtt.Finished = true
+	if mtt.LevelTimer != nil {
+		mtt.LevelTimer.Stop()
+		mtt.LevelTimer = nil
+	}
+
+	results := []TournamentResult{}
+	for _, p := range mtt.Alive {
+		results = append(results, TournamentResult{PlayerID: p.ID, Name: p.Name})
+	}
+	for i := len(mtt.Busted) - 1; i >= 0; i-- {
+		results = append(results, TournamentResult{PlayerID: mtt.Busted[i].PlayerID, Name: mtt.Busted[i].Name})
+	}
+	prizes := calculatePayouts(mtt.PrizePool, mtt.Settings.Payouts, len(results))
+	for i := range results {
+		results[i].Place = i + 1
+		if i < len(prizes) {
+			results[i].Prize = prizes[i]
+		}
+	}
+	mtt.Results = results
+	recordTournamentResults(mtt.ID, results)
+	depositPrizes(results, mtt.entrants())
+
+	log.Printf("多桌锦标赛 %s 结束，冠军 %s，奖池 %d", mtt.ID, results[0].Name, mtt.PrizePool)
+	return Message{
+		Type: "mttFinished",
+		Data: map[string]interface{}{
+			"mttId":     mtt.ID,
+			"results":   results,
+			"prizePool": mtt.PrizePool,
+		},
+	}
+}
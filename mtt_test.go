@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// 创建测试用的多桌锦标赛，每张牌桌坐指定人数
+func newTestMTT(tableSize int, counts ...int) (*MTT, []*GameRoom) {
+	mtt := &MTT{
+		ID:           "test_mtt",
+		TableSize:    tableSize,
+		Settings:     defaultTournamentSettings(),
+		Alive:        make(map[string]*Player),
+		PendingDeals: make(map[string]bool),
+		Started:      true,
+	}
+	tables := []*GameRoom{}
+	roomsMutex.Lock()
+	for i, count := range counts {
+		table := &GameRoom{ID: fmt.Sprintf("test_mtt_table_%d", i), GamePhase: "waiting", MTT: mtt}
+		for j := 0; j < count; j++ {
+			p := &Player{ID: fmt.Sprintf("p%d_%d", i, j), Name: fmt.Sprintf("P%d_%d", i, j), Chips: 1500}
+			table.Players = append(table.Players, p)
+			mtt.Alive[p.ID] = p
+			mtt.Registrants = append(mtt.Registrants, p)
+		}
+		rooms[table.ID] = table
+		mtt.Tables = append(mtt.Tables, table.ID)
+		tables = append(tables, table)
+	}
+	roomsMutex.Unlock()
+	mtt.Entries = len(mtt.Alive)
+	return mtt, tables
+}
+
+func removeTestMTT(tables []*GameRoom) {
+	roomsMutex.Lock()
+	for _, table := range tables {
+		delete(rooms, table.ID)
+	}
+	roomsMutex.Unlock()
+}
+
+// 测试平衡牌桌：各桌人数相差不超过1，人数减少后拆桌
+func TestMTTBalanceTables(t *testing.T) {
+	mtt, tables := newTestMTT(4, 4, 4, 1)
+	defer removeTestMTT(tables)
+
+	mtt.balanceTables()
+	for _, table := range tables {
+		if len(table.Players) != 3 {
+			t.Errorf("Expected 3 players at %s, got %d", table.ID, len(table.Players))
+		}
+	}
+
+	// 6人只需要2张牌桌，拆掉一张后剩下两桌各3人
+	for _, table := range tables {
+		for _, p := range table.Players[2:] {
+			delete(mtt.Alive, p.ID)
+		}
+		table.Players = table.Players[:2]
+	}
+	mtt.balanceTables()
+	if len(mtt.Tables) != 2 {
+		t.Fatalf("Expected 2 tables after breaking, got %d", len(mtt.Tables))
+	}
+	roomsMutex.RLock()
+	open := 0
+	for _, table := range tables {
+		if rooms[table.ID] != nil {
+			open++
+		}
+	}
+	roomsMutex.RUnlock()
+	if open != 2 {
+		t.Errorf("Broken table should be removed from rooms, %d tables left", open)
+	}
+	for _, table := range mtt.tableRooms() {
+		if len(table.Players) != 3 {
+			t.Errorf("Expected 3 players at %s, got %d", table.ID, len(table.Players))
+		}
+	}
+
+	// 正在打牌的牌桌不会移出玩家，移入的玩家进入等待列表
+	mtt, tables = newTestMTT(4, 4, 1)
+	defer removeTestMTT(tables)
+	tables[1].GamePhase = "flop"
+	mtt.balanceTables()
+	if len(tables[0].Players) != 3 || len(tables[1].WaitingPlayers) != 1 {
+		t.Errorf("Expected one player moved to waiting list, got %d seated and %d waiting", len(tables[0].Players), len(tables[1].WaitingPlayers))
+	}
+}
+
+// 测试泡沫期逐手同步和比赛结束的名次
+func TestMTTHandForHandAndFinish(t *testing.T) {
+	mtt, tables := newTestMTT(2, 2, 2)
+	defer removeTestMTT(tables)
+	mtt.Settings.Payouts = []int{60, 40}
+	mtt.PrizePool = 400
+
+	// 4人比赛只奖励前2名，剩3人时进入泡沫期
+	mtt.updateHandForHand()
+	if mtt.HandForHand {
+		t.Error("Hand for hand should be off with 4 players left")
+	}
+	busted := tables[0].Players[0]
+	delete(mtt.Alive, busted.ID)
+	mtt.Busted = append(mtt.Busted, &TournamentEntry{PlayerID: busted.ID, Name: busted.Name})
+	mtt.updateHandForHand()
+	if !mtt.HandForHand {
+		t.Error("Hand for hand should be on with 3 players left and 2 paid places")
+	}
+
+	// 淘汰到只剩一人时比赛结束，奖金存入钱包
+	mtt.Tables = []string{tables[1].ID}
+	mttsMutex.Lock()
+	mtts[mtt.ID] = mtt
+	mttsMutex.Unlock()
+	wallets := make(map[string]int)
+	for _, p := range mtt.Registrants {
+		wallets[p.ID] = walletBalance(p)
+	}
+	mtt.tableHandEnded(tables[1].ID, []*Player{tables[0].Players[1], tables[1].Players[0]})
+	if !mtt.Finished {
+		t.Fatal("Tournament should finish with one player left")
+	}
+	mttsMutex.RLock()
+	_, exists := mtts[mtt.ID]
+	mttsMutex.RUnlock()
+	if exists {
+		t.Error("Finished tournament should be removed from the tournament list")
+	}
+	expected := []string{tables[1].Players[1].Name, tables[1].Players[0].Name, tables[0].Players[1].Name, busted.Name}
+	prizes := []int{240, 160, 0, 0}
+	for i, r := range mtt.Results {
+		if r.Name != expected[i] || r.Place != i+1 || r.Prize != prizes[i] {
+			t.Errorf("Place %d: expected %s with %d, got %+v", i+1, expected[i], prizes[i], r)
+		}
+	}
+	for _, p := range mtt.Registrants {
+		prize := 0
+		for _, r := range mtt.Results {
+			if r.PlayerID == p.ID {
+				prize = r.Prize
+			}
+		}
+		if balance := walletBalance(p); balance != wallets[p.ID]+prize {
+			t.Errorf("%s should receive %d in the wallet, got %d", p.Name, prize, balance-wallets[p.ID])
+		}
+	}
+}
+
+// 测试报名：报名时支付买入，报名后不能加入其他房间，退出报名（包括断开连接）时退还买入
+func TestMTTRegistration(t *testing.T) {
+	mtt := &MTT{ID: "test_mtt_registration", Settings: defaultTournamentSettings(), Alive: make(map[string]*Player)}
+	mttsMutex.Lock()
+	mtts[mtt.ID] = mtt
+	mttsMutex.Unlock()
+	defer func() {
+		mttsMutex.Lock()
+		delete(mtts, mtt.ID)
+		mttsMutex.Unlock()
+	}()
+
+	alice := &Player{ID: "mr_a", Name: "Alice"}
+	registerMTT(alice, &Message{Data: map[string]interface{}{"mttId": mtt.ID}})
+	if len(mtt.Registrants) != 1 || registeredMTT(alice) != mtt {
+		t.Fatal("Alice should be registered")
+	}
+	if balance := walletBalance(alice); balance != WALLET_INITIAL_BALANCE-mtt.Settings.BuyIn {
+		t.Errorf("Buy-in should come from the wallet, got %d", balance)
+	}
+	if checkNotRegisteredMTT(alice) {
+		t.Error("Registered players should not join other rooms")
+	}
+
+	if !mtt.unregister(alice) || walletBalance(alice) != WALLET_INITIAL_BALANCE {
+		t.Errorf("Buy-in should be refunded after unregistering, got %d", walletBalance(alice))
+	}
+
+	registerMTT(alice, &Message{Data: map[string]interface{}{"mttId": mtt.ID}})
+	removePlayer(alice)
+	if len(mtt.Registrants) != 0 || registeredMTT(alice) != nil {
+		t.Error("Disconnected players should be unregistered")
+	}
+	if balance := walletBalance(alice); balance != WALLET_INITIAL_BALANCE {
+		t.Errorf("Buy-in should be refunded after disconnecting, got %d", balance)
+	}
+
+	// 钱包余额不足时不能报名
+	poor := &Player{ID: "mr_p", Name: "Poor"}
+	walletWithdraw(poor, WALLET_INITIAL_BALANCE)
+	registerMTT(poor, &Message{Data: map[string]interface{}{"mttId": mtt.ID}})
+	if len(mtt.Registrants) != 0 {
+		t.Error("Players without enough balance should not register")
+	}
+
+	// 开始后不再退出报名
+	registerMTT(alice, &Message{Data: map[string]interface{}{"mttId": mtt.ID}})
+	mtt.Started = true
+	if mtt.unregister(alice) || len(mtt.Registrants) != 1 {
+		t.Error("Players should not unregister after the start")
+	}
+}
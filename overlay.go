@@ -0,0 +1,314 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	OVERLAY_DEFAULT_DELAY = 60  // 直播画面默认延迟的秒数
+	OVERLAY_BUFFER_SIZE   = 256 // 每个直播画面最多缓存的事件数，处理不过来时丢弃新的事件
+)
+
+// 直播画面中的玩家（包括手牌）
+type OverlayPlayer struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Seat   int    `json:"seat"`
+	Chips  int    `json:"chips"`
+	Bet    int    `json:"bet"`
+	Folded bool   `json:"folded"`
+	AllIn  bool   `json:"allIn"`
+	Dealer bool   `json:"dealer"`
+	Hand   []Card `json:"hand"`
+}
+
+// 直播画面中的牌桌状态
+type OverlayState struct {
+	RoomID         string          `json:"roomId"`
+	HandNumber     int             `json:"handNumber"`
+	Phase          string          `json:"phase"`
+	Pot            int             `json:"pot"`
+	CurrentBet     int             `json:"currentBet"`
+	SmallBlind     int             `json:"smallBlind"`
+	BigBlind       int             `json:"bigBlind"`
+	CommunityCards []Card          `json:"communityCards"`
+	Players        []OverlayPlayer `json:"players"`
+	Equity         []PlayerEquity  `json:"equity,omitempty"` // 没有弃牌的玩家的胜率（发送时计算）
+	EquityExact    bool            `json:"equityExact"`      // 胜率是否为精确枚举（false时是翻牌前的抽样估算）
+}
+
+// 直播画面的一个事件：牌局事件（行动、发牌、结算等）和之后的牌桌状态
+type OverlayEvent struct {
+	At    time.Time     `json:"at"` // 实际发生的时间，发送时已经过了延迟
+	Event *HandEvent    `json:"event,omitempty"`
+	State *OverlayState `json:"state"`
+}
+
+// 一个连接中的直播画面
+type overlayFeed struct {
+	events chan OverlayEvent
+	done   chan struct{}
+}
+
+var overlayFeeds = make(map[string]map[*overlayFeed]bool) // 房间ID -> 直播画面
+var overlayMutex sync.Mutex
+
+// 当前的牌桌状态（调用者需持有锁）
+func (room *GameRoom) overlayState() *OverlayState {
+	state := &OverlayState{
+		RoomID:         room.ID,
+		HandNumber:     room.HandCount,
+		Phase:          room.GamePhase,
+		Pot:            room.Pot,
+		CurrentBet:     room.CurrentBet,
+		SmallBlind:     room.SmallBlind,
+		BigBlind:       room.BigBlind,
+		CommunityCards: append([]Card{}, room.CommunityCards...),
+		Players:        make([]OverlayPlayer, len(room.Players)),
+	}
+	for i, p := range room.Players {
+		state.Players[i] = OverlayPlayer{
+			ID:     p.ID,
+			Name:   p.Name,
+			Seat:   i,
+			Chips:  p.Chips,
+			Bet:    p.Bet,
+			Folded: p.Folded,
+			AllIn:  p.AllIn,
+			Dealer: p.IsDealer,
+			Hand:   append([]Card{}, p.Hand...),
+		}
+	}
+	return state
+}
+
+// 把牌局事件和当前的牌桌状态发给房间的直播画面（调用者需持有写锁），没有直播画面时什么都不做
+func (room *GameRoom) publishOverlay(event *HandEvent) {
+	overlayMutex.Lock()
+	defer overlayMutex.Unlock()
+	feeds := overlayFeeds[room.ID]
+	if len(feeds) == 0 {
+		return
+	}
+	e := OverlayEvent{At: time.Now(), Event: event, State: room.overlayState()}
+	for feed := range feeds {
+		select {
+		case feed.events <- e:
+		default:
+			log.Printf("直播画面处理不过来，丢弃房间 %s 的事件", room.ID)
+		}
+	}
+}
+
+// 没有弃牌的玩家的胜率和是否为精确枚举，少于两人或还没有发牌时返回nil
+func overlayEquity(state *OverlayState) ([]PlayerEquity, bool) {
+	var hands []EquityHand
+	for _, p := range state.Players {
+		if !p.Folded && len(p.Hand) == 2 {
+			hands = append(hands, EquityHand{PlayerID: p.ID, Name: p.Name, Hand: p.Hand})
+		}
+	}
+	if len(hands) < 2 || len(state.CommunityCards) > 5 {
+		return nil, false
+	}
+	return calculateEquity(hands, state.CommunityCards)
+}
+
+func newOverlayKey() string {
+	key := make([]byte, 18)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// 关闭房间的所有直播画面（更换或收回密钥、房间关闭时）
+func closeOverlayFeeds(roomID string) {
+	overlayMutex.Lock()
+	defer overlayMutex.Unlock()
+	for feed := range overlayFeeds[roomID] {
+		close(feed.done)
+	}
+	delete(overlayFeeds, roomID)
+}
+
+// 房主生成直播画面的密钥（之前的密钥失效），data.delay为延迟的秒数
+// data.revoke为true时只收回密钥
+func createOverlayKey(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	delay, revoke := OVERLAY_DEFAULT_DELAY, false
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		if d, ok := data["delay"].(float64); ok {
+			delay = int(d)
+		}
+		revoke, _ = data["revoke"].(bool)
+	}
+	// 直播画面能看到所有手牌，延迟和观战全知模式一样至少OMNISCIENT_MIN_DELAY秒
+	if delay < OMNISCIENT_MIN_DELAY {
+		delay = OMNISCIENT_MIN_DELAY
+	}
+	if delay > MAX_SPECTATOR_DELAY {
+		delay = MAX_SPECTATOR_DELAY
+	}
+
+	room.Mutex.Lock()
+	if err := room.requireHost(player); err != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+	room.OverlayKey = ""
+	if !revoke {
+		room.OverlayKey = newOverlayKey()
+		room.OverlayDelay = delay
+	}
+	key := room.OverlayKey
+	room.Mutex.Unlock()
+
+	closeOverlayFeeds(room.ID)
+	if revoke {
+		log.Printf("房主 %s 收回了房间 %s 的直播画面密钥", player.Name, room.ID)
+		sendMessage(player, Message{
+			Type: "overlayKey",
+			Data: map[string]interface{}{
+				"roomId": room.ID,
+			},
+		})
+		return
+	}
+
+	log.Printf("房主 %s 生成了房间 %s 的直播画面密钥，延迟 %d 秒", player.Name, room.ID, delay)
+	query := url.Values{"room": {room.ID}, "key": {key}}.Encode()
+	sendMessage(player, Message{
+		Type: "overlayKey",
+		Data: map[string]interface{}{
+			"roomId": room.ID,
+			"key":    key,
+			"delay":  delay,
+			"url":    fmt.Sprintf("/api/overlay?%s", query),
+			// 可以直接作为OBS浏览器源的页面
+			"overlayUrl": fmt.Sprintf("/overlay.html?%s", query),
+		},
+	})
+}
+
+// 检查直播画面的房间和密钥并注册直播画面，返回房间和延迟
+// 持有房间的锁注册，更换密钥后关闭直播画面时不会漏掉刚用旧密钥连接的画面
+func registerOverlay(roomID, key string) (*GameRoom, *overlayFeed, time.Duration, error) {
+	roomsMutex.RLock()
+	room := rooms[roomID]
+	roomsMutex.RUnlock()
+	if room == nil {
+		return nil, nil, 0, errors.New("房间不存在或密钥错误")
+	}
+	room.Mutex.RLock()
+	defer room.Mutex.RUnlock()
+	if room.OverlayKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(room.OverlayKey)) != 1 {
+		return nil, nil, 0, errors.New("房间不存在或密钥错误")
+	}
+
+	feed := &overlayFeed{events: make(chan OverlayEvent, OVERLAY_BUFFER_SIZE), done: make(chan struct{})}
+	overlayMutex.Lock()
+	if overlayFeeds[room.ID] == nil {
+		overlayFeeds[room.ID] = make(map[*overlayFeed]bool)
+	}
+	overlayFeeds[room.ID][feed] = true
+	overlayMutex.Unlock()
+	return room, feed, time.Duration(room.OverlayDelay) * time.Second, nil
+}
+
+// 断开连接时注销直播画面
+func unregisterOverlay(roomID string, feed *overlayFeed) {
+	overlayMutex.Lock()
+	defer overlayMutex.Unlock()
+	delete(overlayFeeds[roomID], feed)
+	if len(overlayFeeds[roomID]) == 0 {
+		delete(overlayFeeds, roomID)
+	}
+}
+
+// HTTP接口：GET /api/overlay?room=...&key=...
+// 用Server-Sent Events推送延迟后的牌局事件和牌桌状态（包括所有手牌和胜率），只读，与玩家的WebSocket协议分开
+func handleOverlay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limitKey := clientAddr(r)
+	if failuresBlocked(limitKey) {
+		http.Error(w, "失败次数过多，请稍后再试", http.StatusTooManyRequests)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	room, feed, delay, err := registerOverlay(r.URL.Query().Get("room"), r.URL.Query().Get("key"))
+	if err != nil {
+		recordFailure(limitKey)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	defer unregisterOverlay(room.ID, feed)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	hello, _ := json.Marshal(map[string]interface{}{"roomId": room.ID, "delay": int(delay / time.Second)})
+	fmt.Fprintf(w, "event: hello\ndata: %s\n\n", hello)
+	flusher.Flush()
+	log.Printf("直播画面已连接，房间 %s，地址 %s", room.ID, limitKey)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-feed.done:
+			return
+		case e := <-feed.events:
+			// 等到延迟之后再发送，胜率在发送时计算，不占用房间的锁
+			select {
+			case <-time.After(time.Until(e.At.Add(delay))):
+			case <-r.Context().Done():
+				return
+			case <-feed.done:
+				return
+			}
+			state := *e.State
+			state.Equity, state.EquityExact = overlayEquity(&state)
+			e.State = &state
+			data, err := json.Marshal(e)
+			if err != nil {
+				log.Printf("序列化直播画面事件失败: %v", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: update\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+)
+
+// 测试直播画面：密钥检查、牌局事件和包括所有手牌的牌桌状态、胜率
+func TestOverlayFeed(t *testing.T) {
+	alice := &Player{ID: "oa", Name: "Alice", Chips: 990, Hand: []Card{{Suit: "spades", Rank: "A"}, {Suit: "hearts", Rank: "A"}}}
+	bob := &Player{ID: "ob", Name: "Bob", Chips: 980, Hand: []Card{{Suit: "clubs", Rank: "2"}, {Suit: "diamonds", Rank: "7"}}}
+	carol := &Player{ID: "oc", Name: "Carol", Chips: 1000, Folded: true, Hand: []Card{{Suit: "clubs", Rank: "K"}, {Suit: "clubs", Rank: "Q"}}}
+	room := &GameRoom{
+		ID:         "test_overlay",
+		GamePhase:  "preflop",
+		Players:    []*Player{alice, bob, carol},
+		OverlayKey: "secret",
+	}
+	roomsMutex.Lock()
+	rooms[room.ID] = room
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(rooms, room.ID)
+		roomsMutex.Unlock()
+		closeOverlayFeeds(room.ID)
+	}()
+
+	if _, _, _, err := registerOverlay(room.ID, "wrong"); err == nil {
+		t.Fatal("Wrong key should be rejected")
+	}
+	_, feed, _, err := registerOverlay(room.ID, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	room.beginHandHistory()
+	room.recordAction(bob, "bigBlind", 20)
+	if len(feed.events) != 2 {
+		t.Fatalf("Expected 2 overlay events, got %d", len(feed.events))
+	}
+	<-feed.events
+	e := <-feed.events
+	if e.Event == nil || e.Event.Action != "bigBlind" || e.State.HandNumber != 1 {
+		t.Errorf("Unexpected event: %+v", e.Event)
+	}
+	if len(e.State.Players) != 3 || len(e.State.Players[1].Hand) != 2 {
+		t.Error("Overlay should include all hands")
+	}
+
+	equity, exact := overlayEquity(e.State)
+	if exact {
+		t.Error("Preflop equity should be marked as approximate")
+	}
+	if len(equity) != 2 || equity[0].PlayerID != alice.ID || equity[0].Equity < 80 {
+		t.Errorf("Unexpected equity: %+v", equity)
+	}
+
+	// 更换密钥后关闭已连接的直播画面
+	closeOverlayFeeds(room.ID)
+	select {
+	case <-feed.done:
+	default:
+		t.Error("Feed should be closed")
+	}
+	room.recordAction(alice, "call", 20)
+	if len(feed.events) != 0 {
+		t.Error("Closed feed should not receive events")
+	}
+}
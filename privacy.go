@@ -0,0 +1,232 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RoomVisibilityPublic  = "public"  // 显示在大厅中
+	RoomVisibilityPrivate = "private" // 不显示在大厅中，需要邀请链接或密码才能加入
+
+	INVITE_DEFAULT_TTL  = 24 * time.Hour     // 邀请链接默认有效期
+	INVITE_MAX_TTL      = 7 * 24 * time.Hour // 邀请链接最长有效期
+	JOIN_MAX_FAILURES   = 5                  // 时间窗口内允许的加入失败次数（房间ID或密码错误），登录失败同样限制
+	JOIN_FAILURE_WINDOW = time.Minute
+)
+
+// 签名邀请链接的密钥，每次启动随机生成（房间本身也不会在重启后保留）
+var inviteSecret = func() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}()
+
+// 加入房间和登录的失败记录：地址 -> 失败时间
+var joinFailures = make(map[string][]time.Time)
+var joinFailuresMutex sync.Mutex
+
+// 从HTTP请求中取出客户端地址（不含端口），用于限制猜测房间ID和密码
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// 限制加入房间的key：优先使用客户端地址，重新连接也不能绕过
+func joinLimitKey(player *Player) string {
+	if player.RemoteAddr != "" {
+		return player.RemoteAddr
+	}
+	return player.ID
+}
+
+// 是否因为失败次数过多被暂时禁止加入房间
+func joinBlocked(player *Player) bool {
+	return failuresBlocked(joinLimitKey(player))
+}
+
+// 记录一次加入失败
+func recordJoinFailure(player *Player) {
+	key := joinLimitKey(player)
+	log.Printf("加入房间失败，地址 %s 最近失败 %d 次", key, recordFailure(key))
+}
+
+// 时间窗口内失败次数是否达到上限（加入房间和登录共用）
+func failuresBlocked(key string) bool {
+	joinFailuresMutex.Lock()
+	defer joinFailuresMutex.Unlock()
+
+	recent := joinFailures[key][:0]
+	for _, at := range joinFailures[key] {
+		if time.Since(at) < JOIN_FAILURE_WINDOW {
+			recent = append(recent, at)
+		}
+	}
+	if len(recent) == 0 {
+		delete(joinFailures, key)
+		return false
+	}
+	joinFailures[key] = recent
+	return len(recent) >= JOIN_MAX_FAILURES
+}
+
+// 记录一次失败，返回最近的失败次数
+func recordFailure(key string) int {
+	joinFailuresMutex.Lock()
+	defer joinFailuresMutex.Unlock()
+	joinFailures[key] = append(joinFailures[key], time.Now())
+	return len(joinFailures[key])
+}
+
+// 从创建房间请求中取出房间密码
+func roomPassword(data map[string]interface{}) string {
+	raw, ok := data["settings"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	password, _ := raw["password"].(string)
+	return password
+}
+
+// 设置房间密码，只保存加盐的哈希（调用者需持有写锁或房间还未公开）
+func (room *GameRoom) setPassword(password string) {
+	if password == "" {
+		room.PasswordSalt = nil
+		room.PasswordHash = nil
+		return
+	}
+	room.PasswordSalt = make([]byte, 16)
+	if _, err := rand.Read(room.PasswordSalt); err != nil {
+		panic(err)
+	}
+	room.PasswordHash = hashPassword(room.PasswordSalt, password)
+}
+
+func hashPassword(salt []byte, password string) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write([]byte(password))
+	return h.Sum(nil)
+}
+
+// 房间是否设置了密码
+func (room *GameRoom) hasPassword() bool {
+	return len(room.PasswordHash) > 0
+}
+
+// 检查新玩家是否可以加入房间：公开且没有密码的房间任何人都可以加入，
+// 否则需要有效的邀请链接或正确的密码（私密房间没有密码时只能通过邀请加入）（调用者需持有锁）
+func (room *GameRoom) authorizeJoin(password, invite string) error {
+	if invite != "" {
+		return verifyInvite(invite, room.ID)
+	}
+	if room.hasPassword() {
+		if password == "" {
+			return errors.New("该房间需要密码")
+		}
+		if subtle.ConstantTimeCompare(hashPassword(room.PasswordSalt, password), room.PasswordHash) != 1 {
+			return errors.New("密码错误")
+		}
+		return nil
+	}
+	if room.Settings.Visibility == RoomVisibilityPrivate {
+		return errors.New("私密房间需要邀请链接才能加入")
+	}
+	return nil
+}
+
+// 生成邀请令牌：房间ID和过期时间，用HMAC签名
+func createInviteToken(roomID string, expiresAt time.Time) string {
+	payload := fmt.Sprintf("%s|%d", roomID, expiresAt.Unix())
+	mac := hmac.New(sha256.New, inviteSecret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// 验证邀请令牌是否属于该房间且没有过期
+func verifyInvite(token, roomID string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return errors.New("邀请链接无效")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return errors.New("邀请链接无效")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return errors.New("邀请链接无效")
+	}
+	mac := hmac.New(sha256.New, inviteSecret)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return errors.New("邀请链接无效")
+	}
+
+	fields := strings.Split(string(payload), "|")
+	if len(fields) != 2 || fields[0] != roomID {
+		return errors.New("邀请链接不属于该房间")
+	}
+	expires, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return errors.New("邀请链接无效")
+	}
+	if time.Now().Unix() > expires {
+		return errors.New("邀请链接已过期")
+	}
+	return nil
+}
+
+// 房间中的玩家生成邀请链接
+func createInvite(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	ttl := INVITE_DEFAULT_TTL
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		if seconds, ok := data["ttlSeconds"].(float64); ok && seconds > 0 {
+			ttl = time.Duration(seconds) * time.Second
+		}
+	}
+	if ttl > INVITE_MAX_TTL {
+		ttl = INVITE_MAX_TTL
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	token := createInviteToken(room.ID, expiresAt)
+	log.Printf("玩家 %s 生成房间 %s 的邀请链接，有效期至 %s", player.Name, room.ID, expiresAt.Format(time.RFC3339))
+	sendMessage(player, Message{
+		Type: "inviteCreated",
+		Data: map[string]interface{}{
+			"roomId":    room.ID,
+			"token":     token,
+			"expiresAt": expiresAt,
+		},
+	})
+}
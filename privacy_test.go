@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// 测试邀请令牌的签名和过期
+func TestInviteToken(t *testing.T) {
+	token := createInviteToken("123456", time.Now().Add(time.Hour))
+	if err := verifyInvite(token, "123456"); err != nil {
+		t.Errorf("Expected valid invite, got %v", err)
+	}
+	if err := verifyInvite(token, "654321"); err == nil {
+		t.Error("Invite should not work for another room")
+	}
+	if err := verifyInvite(token+"x", "123456"); err == nil {
+		t.Error("Tampered invite should be rejected")
+	}
+	expired := createInviteToken("123456", time.Now().Add(-time.Minute))
+	if err := verifyInvite(expired, "123456"); err == nil {
+		t.Error("Expired invite should be rejected")
+	}
+}
+
+// 测试私密房间和密码房间的加入权限
+func TestAuthorizeJoin(t *testing.T) {
+	room := &GameRoom{ID: "123456", Settings: defaultRoomSettings()}
+	if err := room.authorizeJoin("", ""); err != nil {
+		t.Errorf("Public room without password should be open, got %v", err)
+	}
+
+	room.setPassword("secret")
+	if err := room.authorizeJoin("wrong", ""); err == nil {
+		t.Error("Wrong password should be rejected")
+	}
+	if err := room.authorizeJoin("secret", ""); err != nil {
+		t.Errorf("Correct password should be accepted, got %v", err)
+	}
+
+	room.setPassword("")
+	room.Settings.Visibility = RoomVisibilityPrivate
+	if err := room.authorizeJoin("", ""); err == nil {
+		t.Error("Private room should require an invite")
+	}
+	if err := room.authorizeJoin("", createInviteToken(room.ID, time.Now().Add(time.Hour))); err != nil {
+		t.Errorf("Invite should open a private room, got %v", err)
+	}
+}
+
+// 测试反复加入失败后被暂时禁止
+func TestJoinRateLimit(t *testing.T) {
+	player := &Player{ID: "guesser", RemoteAddr: "192.0.2.1"}
+	for i := 0; i < JOIN_MAX_FAILURES; i++ {
+		if joinBlocked(player) {
+			t.Fatalf("Blocked after only %d failures", i)
+		}
+		recordJoinFailure(player)
+	}
+	if !joinBlocked(player) {
+		t.Error("Expected to be blocked after repeated failures")
+	}
+	// 同一地址的新连接也被限制
+	if !joinBlocked(&Player{ID: "reconnected", RemoteAddr: "192.0.2.1"}) {
+		t.Error("Expected a new connection from the same address to be blocked")
+	}
+}
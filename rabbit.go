@@ -0,0 +1,100 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"log"
+)
+
+// 其他玩家都弃牌结束后还没发出的公共牌（兔子牌）
+type RabbitOffer struct {
+	HandNumber int
+	Board      []Card // 结束时已发出的公共牌
+	Rabbit     []Card // 本来会发出的剩余公共牌
+	Revealed   bool
+}
+
+// 无人跟注结束本局时，保存牌组顶部本来会发出的公共牌（调用者需持有写锁）
+// 只有房间开启了兔子牌且公共牌还没发完时才会保存
+func (room *GameRoom) offerRabbit() {
+	room.RabbitOffer = nil
+	// 双公共牌的炸弹底池两套公共牌交替发牌，不支持兔子牌
+	if !room.Settings.RabbitHunt || len(room.CommunityCards) >= 5 || len(room.RunBoards) > 1 {
+		return
+	}
+
+	need := 5 - len(room.CommunityCards)
+	if len(room.Deck) < need {
+		return
+	}
+	room.RabbitOffer = &RabbitOffer{
+		HandNumber: room.HandCount,
+		Board:      append([]Card{}, room.CommunityCards...),
+		Rabbit:     append([]Card{}, room.Deck[:need]...),
+	}
+}
+
+// 玩家请求查看兔子牌，亮出后记录到牌局记录中，不影响结算结果
+func handleRabbitHunt(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	room.Mutex.Lock()
+	if !room.Settings.RabbitHunt {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间未开启兔子牌"},
+		})
+		return
+	}
+
+	inGame := false
+	for _, p := range room.Players {
+		if p.ID == player.ID {
+			inGame = true
+			break
+		}
+	}
+	offer := room.RabbitOffer
+	if !inGame || offer == nil || offer.Revealed {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "当前没有可以查看的兔子牌"},
+		})
+		return
+	}
+	offer.Revealed = true
+
+	board := append(append([]Card{}, offer.Board...), offer.Rabbit...)
+	room.appendFinishedHandEvent(offer.HandNumber, HandEvent{
+		Type:     HandEventRabbit,
+		PlayerID: player.ID,
+		Name:     player.Name,
+		Cards:    board,
+		Message:  "兔子牌（不影响结果）",
+	})
+	recipients := room.recipients()
+	room.Mutex.Unlock()
+
+	log.Printf("玩家 %s 查看兔子牌，房间 %s，第 %d 局", player.Name, room.ID, offer.HandNumber)
+	sendToPlayers(recipients, Message{
+		Type: "rabbitRevealed",
+		Data: map[string]interface{}{
+			"playerId":       player.ID,
+			"name":           player.Name,
+			"handNumber":     offer.HandNumber,
+			"communityCards": offer.Board,
+			"rabbitCards":    offer.Rabbit,
+			"board":          board,
+		},
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+// 测试兔子牌取自牌组顶部且不改变牌组
+func TestOfferRabbit(t *testing.T) {
+	room := &GameRoom{
+		ID:             "test_rabbit",
+		Deck:           createDeck(),
+		CommunityCards: []Card{{"clubs", "2"}, {"diamonds", "7"}, {"clubs", "9"}},
+		HandCount:      3,
+	}
+	deckSize := len(room.Deck)
+
+	room.offerRabbit()
+	if room.RabbitOffer != nil {
+		t.Fatal("Rabbit hunt should be unavailable when the room setting is off")
+	}
+
+	room.Settings.RabbitHunt = true
+	room.offerRabbit()
+	offer := room.RabbitOffer
+	if offer == nil {
+		t.Fatal("Expected a rabbit offer")
+	}
+	if len(offer.Rabbit) != 2 || offer.Rabbit[0] != room.Deck[0] || offer.Rabbit[1] != room.Deck[1] {
+		t.Errorf("Expected the top 2 cards of the deck, got %v", offer.Rabbit)
+	}
+	if len(room.Deck) != deckSize {
+		t.Errorf("Deck should not change, expected %d cards, got %d", deckSize, len(room.Deck))
+	}
+	if offer.HandNumber != 3 {
+		t.Errorf("Expected hand number 3, got %d", offer.HandNumber)
+	}
+
+	room.CommunityCards = append(room.CommunityCards, room.Deck[:2]...)
+	room.offerRabbit()
+	if room.RabbitOffer != nil {
+		t.Error("Rabbit hunt should be unavailable once the board is complete")
+	}
+}
@@ -17,9 +17,9 @@ echo "✅ 环境检查通过"
 echo ""
 
 # 检查是否已编译
-if [ ! -f "poker-server" ] || [ "main.go" -nt "poker-server" ] || [ "game.go" -nt "poker-server" ]; then
+if [ ! -f "poker-server" ] || [ -n "$(find . -maxdepth 1 -name '*.go' -newer poker-server)" ]; then
     echo "📦 正在编译 Go 程序..."
-    go build -o poker-server main.go game.go
+    go build -o poker-server .
     
     if [ $? -ne 0 ]; then
         echo "❌ 编译失败"
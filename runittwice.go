@@ -0,0 +1,351 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// 多次发牌投票（所有未弃牌玩家同意后才会多次发牌）
+type RunItVote struct {
+	MaxRuns  int             // 最多发牌次数
+	Eligible map[string]bool // 有权投票的玩家ID
+	Votes    map[string]int  // 玩家ID -> 同意的发牌次数
+	Timer    *time.Timer     // 投票超时定时器
+}
+
+// 所有未弃牌玩家都已全押（或无人可以行动）时结束本局
+// 注意：调用此函数时应该持有写锁，函数会释放锁
+func finishAllInHand(room *GameRoom) {
+	if room.TurnTimer != nil {
+		room.TurnTimer.Stop()
+		room.TurnTimer = nil
+	}
+	room.AllInRunout = true
+
+	// 公共牌还没发完且房间允许多次发牌时，先询问玩家（双公共牌的炸弹底池已经是两套公共牌，不再询问）
+	if room.Settings.MaxRuns > 1 && len(room.CommunityCards) < 5 && len(room.RunBoards) < 2 {
+		startRunItVote(room)
+		return
+	}
+
+	runOutAndShowdown(room, 1)
+}
+
+// 发起多次发牌投票
+// 注意：调用此函数时应该持有写锁，函数会释放锁
+func startRunItVote(room *GameRoom) {
+	vote := &RunItVote{
+		MaxRuns:  room.Settings.MaxRuns,
+		Eligible: make(map[string]bool),
+		Votes:    make(map[string]int),
+	}
+	eligibleIDs := []string{}
+	for _, p := range room.Players {
+		if !p.Folded {
+			vote.Eligible[p.ID] = true
+			eligibleIDs = append(eligibleIDs, p.ID)
+		}
+	}
+	room.RunItVote = vote
+
+	roomID := room.ID
+	vote.Timer = time.AfterFunc(RUN_IT_TWICE_TIMEOUT*time.Second, func() {
+		roomsMutex.RLock()
+		r, exists := rooms[roomID]
+		roomsMutex.RUnlock()
+		if !exists {
+			return
+		}
+
+		r.Mutex.Lock()
+		if r.RunItVote != vote {
+			r.Mutex.Unlock()
+			return
+		}
+		log.Printf("多次发牌投票超时，只发一次，房间 %s", roomID)
+		resolveRunItVote(r, 1)
+	})
+
+	recipients := room.recipients()
+	room.Mutex.Unlock()
+
+	log.Printf("所有玩家都已全押，发起多次发牌投票，房间 %s，最多 %d 次", roomID, vote.MaxRuns)
+	sendToPlayers(recipients, Message{
+		Type: "runItTwiceOffer",
+		Data: map[string]interface{}{
+			"maxRuns":   vote.MaxRuns,
+			"playerIds": eligibleIDs,
+			"timeout":   RUN_IT_TWICE_TIMEOUT,
+		},
+	})
+}
+
+// 处理玩家的多次发牌投票
+func handleRunItTwice(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	runs := 1
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		if n, ok := data["runs"].(float64); ok {
+			runs = int(n)
+		}
+	}
+
+	room.Mutex.Lock()
+	vote := room.RunItVote
+	if vote == nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "当前没有进行中的多次发牌投票"},
+		})
+		return
+	}
+	if !vote.Eligible[player.ID] {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "只有全押的玩家可以选择发牌次数"},
+		})
+		return
+	}
+
+	if runs < 1 {
+		runs = 1
+	}
+	if runs > vote.MaxRuns {
+		runs = vote.MaxRuns
+	}
+	vote.Votes[player.ID] = runs
+	log.Printf("玩家 %s 选择发牌 %d 次，房间 %s", player.Name, runs, room.ID)
+
+	// 有人只想发一次，或者所有人都已投票，立即结算（取所有人同意的最少次数）
+	if runs == 1 || len(vote.Votes) == len(vote.Eligible) {
+		agreed := vote.MaxRuns
+		for _, n := range vote.Votes {
+			if n < agreed {
+				agreed = n
+			}
+		}
+		if runs == 1 {
+			agreed = 1
+		}
+		resolveRunItVote(room, agreed)
+		return
+	}
+
+	recipients := room.recipients()
+	room.Mutex.Unlock()
+
+	sendToPlayers(recipients, Message{
+		Type: "runItTwiceVoted",
+		Data: map[string]interface{}{
+			"playerId": player.ID,
+			"name":     player.Name,
+			"runs":     runs,
+		},
+	})
+}
+
+// 结束投票并按约定次数发牌
+// 注意：调用此函数时应该持有写锁，函数会释放锁
+func resolveRunItVote(room *GameRoom, runs int) {
+	if room.RunItVote != nil && room.RunItVote.Timer != nil {
+		room.RunItVote.Timer.Stop()
+	}
+	room.RunItVote = nil
+	room.recordHandEvent(HandEvent{
+		Type:    HandEventRunIt,
+		Message: fmt.Sprintf("约定发牌 %d 次", runs),
+	})
+	runOutAndShowdown(room, runs)
+}
+
+// 发完剩余公共牌（runs > 1 时额外发出多套公共牌），逐张亮牌后进入比牌
+// 双公共牌的炸弹底池两套公共牌各自发完，忽略runs
+// 注意：调用此函数时应该持有写锁，函数会释放锁
+func runOutAndShowdown(room *GameRoom, runs int) {
+	// 每套公共牌已经发出的部分
+	knowns := [][]Card{}
+	if len(room.RunBoards) > 1 {
+		for _, board := range room.RunBoards {
+			knowns = append(knowns, append([]Card{}, board...))
+		}
+	} else {
+		for i := 0; i < runs; i++ {
+			knowns = append(knowns, append([]Card{}, room.CommunityCards...))
+		}
+	}
+
+	// 在锁内发好每次的全部公共牌，亮牌过程在锁外逐张进行
+	boards := [][]Card{}
+	for i, known := range knowns {
+		board := append([]Card{}, known...)
+		if err := dealRemainingBoard(room, &board); err != nil {
+			log.Printf("第 %d 次发牌失败: %v，房间 %s", i+1, err, room.ID)
+			break
+		}
+		boards = append(boards, board)
+	}
+	if len(boards) == 0 {
+		room.Mutex.Unlock()
+		return
+	}
+	log.Printf("全押后发牌 %d 次，房间 %s", len(boards), room.ID)
+
+	// 复制未弃牌玩家的手牌，用于计算胜率
+	hands := []EquityHand{}
+	for _, p := range room.Players {
+		if !p.Folded {
+			hands = append(hands, EquityHand{
+				PlayerID: p.ID,
+				Name:     p.Name,
+				Hand:     append([]Card{}, p.Hand...),
+			})
+		}
+	}
+	roomID := room.ID
+	room.Mutex.Unlock()
+
+	go revealRunout(roomID, knowns[:len(boards)], boards, hands)
+}
+
+// 逐张亮出全押后的公共牌，每亮一次都向玩家和观战者广播当前胜率，最后进入比牌
+func revealRunout(roomID string, knowns [][]Card, boards [][]Card, hands []EquityHand) {
+	for run, board := range boards {
+		// 亮牌节点：当前牌面、翻牌、转牌、河牌（只保留还没发出的）
+		known := knowns[run]
+		stages := []int{len(known)}
+		for _, n := range []int{3, 4, 5} {
+			if n > len(known) {
+				stages = append(stages, n)
+			}
+		}
+
+		for _, n := range stages {
+			// 第二次及以后的发牌不再重复显示发牌前的牌面
+			if run > 0 && n == len(known) {
+				continue
+			}
+
+			roomsMutex.RLock()
+			room, exists := rooms[roomID]
+			roomsMutex.RUnlock()
+			if !exists {
+				return
+			}
+
+			room.Mutex.Lock()
+			if run == 0 {
+				room.CommunityCards = append([]Card{}, board[:n]...)
+				room.GamePhase = phaseForBoardSize(n)
+				if n > len(known) {
+					room.recordStreet()
+				}
+			} else {
+				message := fmt.Sprintf("第 %d 次发牌", run+1)
+				if room.BombPot != nil && room.BombPot.DoubleBoard {
+					message = "第二套公共牌"
+				}
+				room.recordHandEvent(HandEvent{
+					Type:    HandEventStreet,
+					Cards:   append([]Card{}, board[:n]...),
+					Message: message,
+				})
+			}
+			if len(boards) > 1 {
+				// 已发完的几次、正在亮牌的这一次，以及之后几次已经发出的部分
+				current := make([][]Card, len(boards))
+				for i := range boards {
+					switch {
+					case i < run:
+						current[i] = boards[i]
+					case i == run:
+						current[i] = append([]Card{}, board[:n]...)
+					default:
+						current[i] = knowns[i]
+					}
+				}
+				room.RunBoards = current
+			}
+			recipients := room.recipients()
+			room.Mutex.Unlock()
+
+			equity, exact := calculateEquity(hands, board[:n])
+			roomData := room.ToJSON()
+			sendToPlayers(recipients, Message{
+				Type: "roomUpdated",
+				Data: map[string]interface{}{
+					"room": roomData,
+					"runs": len(boards),
+				},
+			})
+			sendToPlayers(recipients, Message{
+				Type: "allInEquity",
+				Data: map[string]interface{}{
+					"run":            run + 1,
+					"runs":           len(boards),
+					"communityCards": board[:n],
+					"equity":         equity,
+					"exact":          exact,
+				},
+			})
+
+			// 等待一小段时间让前端显示公共牌和胜率
+			time.Sleep(ALL_IN_REVEAL_DELAY * time.Millisecond)
+		}
+	}
+
+	// 重新获取锁并进入比牌阶段
+	roomsMutex.RLock()
+	r, exists := rooms[roomID]
+	roomsMutex.RUnlock()
+	if !exists {
+		return
+	}
+	r.Mutex.Lock()
+	r.CommunityCards = boards[0]
+	if len(boards) > 1 {
+		r.RunBoards = boards
+	}
+	r.GamePhase = "showdown"
+	r.LastRaiseIndex = -1
+	determineWinner(r) // 会释放锁
+}
+
+// 根据公共牌数量得到游戏阶段
+func phaseForBoardSize(n int) string {
+	switch {
+	case n >= 5:
+		return "river"
+	case n == 4:
+		return "turn"
+	case n == 3:
+		return "flop"
+	default:
+		return "preflop"
+	}
+}
+
+// 从牌组中发牌，直到公共牌补满5张（调用者需持有写锁）
+func dealRemainingBoard(room *GameRoom, board *[]Card) error {
+	for len(*board) < 5 {
+		card, err := drawCard(&room.Deck)
+		if err != nil {
+			return err
+		}
+		*board = append(*board, card)
+	}
+	return nil
+}
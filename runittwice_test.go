@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+)
+
+// 测试多次发牌时每次独立结算
+func TestRunItTwiceSplitsPot(t *testing.T) {
+	alice := &Player{ID: "a", Name: "Alice", Hand: []Card{{"spades", "A"}, {"hearts", "A"}}, AllIn: true}
+	bob := &Player{ID: "b", Name: "Bob", Hand: []Card{{"spades", "K"}, {"hearts", "K"}}, AllIn: true}
+	room := &GameRoom{
+		ID:      "test_run_it_twice",
+		Players: []*Player{alice, bob},
+		Pot:     1001,
+	}
+	// 第一次：Alice的一对A获胜；第二次：Bob中了三条K
+	room.RunBoards = [][]Card{
+		{{"clubs", "2"}, {"diamonds", "7"}, {"clubs", "9"}, {"diamonds", "J"}, {"clubs", "4"}},
+		{{"clubs", "2"}, {"diamonds", "7"}, {"clubs", "9"}, {"diamonds", "K"}, {"clubs", "4"}},
+	}
+	room.CommunityCards = room.RunBoards[0]
+	room.beginHandHistory()
+
+	room.Mutex.Lock()
+	determineWinner(room) // 会释放锁
+
+	if alice.Chips != 501 {
+		t.Errorf("Alice should win first run with remainder (501), got %d", alice.Chips)
+	}
+	if bob.Chips != 500 {
+		t.Errorf("Bob should win second run (500), got %d", bob.Chips)
+	}
+
+	if len(room.HandHistories) != 1 {
+		t.Fatalf("Expected 1 hand history, got %d", len(room.HandHistories))
+	}
+	if len(room.HandHistories[0].Boards) != 2 {
+		t.Errorf("Expected 2 boards in hand history, got %d", len(room.HandHistories[0].Boards))
+	}
+}
+
+// 测试补发剩余公共牌
+func TestDealRemainingBoard(t *testing.T) {
+	room := &GameRoom{Deck: createDeck()}
+	board := []Card{}
+	if err := dealRemainingBoard(room, &board); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(board) != 5 {
+		t.Errorf("Expected 5 community cards, got %d", len(board))
+	}
+	if len(room.Deck) != CARDS_IN_DECK-5 {
+		t.Errorf("Expected %d cards left in deck, got %d", CARDS_IN_DECK-5, len(room.Deck))
+	}
+
+	room.Deck = room.Deck[:1]
+	board = board[:3]
+	if err := dealRemainingBoard(room, &board); err == nil {
+		t.Error("Expected error when deck runs out, got nil")
+	}
+}
+
+// 测试约定的发牌次数单独记录，不会被当成下注
+func TestResolveRunItVoteRecordsRuns(t *testing.T) {
+	alice := &Player{ID: "ra", Name: "Alice", AllIn: true}
+	bob := &Player{ID: "rb", Name: "Bob", AllIn: true}
+	room := &GameRoom{
+		ID:        "test_resolve_run_it",
+		Players:   []*Player{alice, bob},
+		Deck:      createDeck(),
+		GamePhase: "preflop",
+		Pot:       1000,
+	}
+	alice.Hand = []Card{room.Deck[0], room.Deck[1]}
+	bob.Hand = []Card{room.Deck[2], room.Deck[3]}
+	room.Deck = room.Deck[4:]
+	room.beginHandHistory()
+	history := room.CurrentHand
+
+	room.Mutex.Lock()
+	resolveRunItVote(room, 2) // 会释放锁
+
+	for _, e := range history.Events {
+		if e.Type == HandEventAction {
+			t.Errorf("Run it twice should not be recorded as an action: %+v", e)
+		}
+		if e.Type == HandEventRunIt && (e.Amount != 0 || e.Message != "约定发牌 2 次") {
+			t.Errorf("Unexpected run it event: %+v", e)
+		}
+	}
+	if len(history.Events) == 0 || history.Events[0].Type != HandEventRunIt {
+		t.Errorf("Expected the run it event to be recorded, got %+v", history.Events)
+	}
+}
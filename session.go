@@ -0,0 +1,187 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// 现金局中一名玩家每局输赢的统计（按昵称）
+type SessionHands struct {
+	Hands       int `json:"hands"`       // 参与的局数
+	BiggestWin  int `json:"biggestWin"`  // 单局最多赢的筹码
+	BiggestLoss int `json:"biggestLoss"` // 单局最多输的筹码（正数）
+}
+
+// 结算报告中的一名玩家
+type SessionPlayer struct {
+	Name      string `json:"name"`
+	BoughtIn  int    `json:"boughtIn"`  // 累计从钱包买入的筹码（上桌买入加买一手）
+	CashedOut int    `json:"cashedOut"` // 离桌时已经存回钱包的筹码
+	Chips     int    `json:"chips"`     // 还在座位上的筹码（本局进行中时不包括已经下注的筹码）
+	Net       int    `json:"net"`       // 输赢：离桌兑现加现有筹码减累计买入
+	Seated    bool   `json:"seated"`    // 是否还在座位上
+	SessionHands
+}
+
+// 一笔结算：From付给To
+type Settlement struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount int    `json:"amount"`
+}
+
+// 房间的结算报告
+type SessionReport struct {
+	RoomID      string          `json:"roomId"`
+	At          time.Time       `json:"at"`
+	Players     []SessionPlayer `json:"players"`     // 按输赢从多到少排列
+	Settlements []Settlement    `json:"settlements"` // 结算建议，最多n-1笔
+}
+
+// 一局结束后累加每个玩家的局数和单局最大输赢（调用者需持有写锁），锦标赛的筹码不计入
+func (room *GameRoom) recordSessionHand(history *HandHistory) {
+	if room.Tournament != nil || room.MTT != nil {
+		return
+	}
+	if room.Sessions == nil {
+		room.Sessions = make(map[string]*SessionHands)
+	}
+	nets := handNets(history, room.seatedIDs())
+	for _, hp := range history.Players {
+		s := room.Sessions[hp.Name]
+		if s == nil {
+			s = &SessionHands{}
+			room.Sessions[hp.Name] = s
+		}
+		s.Hands++
+		net := nets[hp.ID]
+		if net > s.BiggestWin {
+			s.BiggestWin = net
+		}
+		if -net > s.BiggestLoss {
+			s.BiggestLoss = -net
+		}
+	}
+}
+
+// 生成房间的结算报告（调用者需持有锁）
+func (room *GameRoom) sessionReport() *SessionReport {
+	players := make(map[string]*SessionPlayer)
+	get := func(name string) *SessionPlayer {
+		if players[name] == nil {
+			players[name] = &SessionPlayer{Name: name}
+		}
+		return players[name]
+	}
+	for _, cashOut := range room.CashOuts {
+		p := get(cashOut.Name)
+		p.BoughtIn += cashOut.BuyIn
+		p.CashedOut += cashOut.Chips
+	}
+	for _, seated := range append(append([]*Player{}, room.Players...), room.WaitingPlayers...) {
+		p := get(seated.Name)
+		p.BoughtIn += room.BuyIns[seated.Name]
+		p.Chips = seated.Chips
+		p.Seated = true
+	}
+	for name, hands := range room.Sessions {
+		get(name).SessionHands = *hands
+	}
+
+	report := &SessionReport{
+		RoomID:  room.ID,
+		At:      time.Now(),
+		Players: make([]SessionPlayer, 0, len(players)),
+	}
+	for _, p := range players {
+		p.Net = p.CashedOut + p.Chips - p.BoughtIn
+		report.Players = append(report.Players, *p)
+	}
+	sort.Slice(report.Players, func(i, j int) bool {
+		if report.Players[i].Net != report.Players[j].Net {
+			return report.Players[i].Net > report.Players[j].Net
+		}
+		return report.Players[i].Name < report.Players[j].Name
+	})
+	report.Settlements = settleNets(report.Players)
+	return report
+}
+
+// 结算建议：每次让输得最多的玩家付给赢得最多的玩家，每笔至少结清一方，最多n-1笔
+// players需按输赢从多到少排列
+func settleNets(players []SessionPlayer) []Settlement {
+	type balance struct {
+		name   string
+		amount int
+	}
+	var creditors, debtors []*balance
+	for _, p := range players {
+		if p.Net > 0 {
+			creditors = append(creditors, &balance{p.Name, p.Net})
+		}
+	}
+	for i := len(players) - 1; i >= 0; i-- {
+		if players[i].Net < 0 {
+			debtors = append(debtors, &balance{players[i].Name, -players[i].Net})
+		}
+	}
+
+	settlements := []Settlement{}
+	for len(creditors) > 0 && len(debtors) > 0 {
+		c, d := creditors[0], debtors[0]
+		amount := c.amount
+		if d.amount < amount {
+			amount = d.amount
+		}
+		settlements = append(settlements, Settlement{From: d.name, To: c.name, Amount: amount})
+		c.amount -= amount
+		d.amount -= amount
+		if c.amount == 0 {
+			creditors = creditors[1:]
+		}
+		if d.amount == 0 {
+			debtors = debtors[1:]
+		}
+	}
+	return settlements
+}
+
+// 查询房间的结算报告（只有现金局有）
+func getSessionReport(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	room.Mutex.RLock()
+	if room.Tournament != nil || room.MTT != nil {
+		room.Mutex.RUnlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "锦标赛没有结算报告"},
+		})
+		return
+	}
+	report := room.sessionReport()
+	room.Mutex.RUnlock()
+
+	sendSessionReport(player, report)
+}
+
+func sendSessionReport(player *Player, report *SessionReport) {
+	log.Printf("发送房间 %s 的结算报告给玩家 %s，%d 名玩家，%d 笔结算", report.RoomID, player.Name, len(report.Players), len(report.Settlements))
+	sendMessage(player, Message{
+		Type: "sessionReport",
+		Data: map[string]interface{}{
+			"report": report,
+		},
+	})
+}
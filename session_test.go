@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 测试结算报告：离桌和还在座位上的玩家的买入、输赢和单局最大输赢
+func TestSessionReport(t *testing.T) {
+	alice := &Player{ID: "sa", Name: "Alice", Chips: 1500}
+	bob := &Player{ID: "sb", Name: "Bob", Chips: 200}
+	room := &GameRoom{
+		ID:      "test_session",
+		Players: []*Player{alice, bob},
+		BuyIns:  map[string]int{"Alice": 1000, "Bob": 500},
+		CashOuts: []*CashOut{
+			{Name: "Carol", Chips: 0, BuyIn: 1000},
+			{Name: "Alice", Chips: 700, BuyIn: 500},
+		},
+	}
+
+	history := &HandHistory{
+		Players: []*HandHistoryPlayer{
+			{ID: "sa", Name: "Alice", StartChips: 1200, EndChips: 1500},
+			{ID: "sb", Name: "Bob", StartChips: 500, EndChips: 200},
+		},
+	}
+	room.recordSessionHand(history)
+	history.Players[0].StartChips, history.Players[0].EndChips = 1500, 1400
+	history.Players[1].StartChips, history.Players[1].EndChips = 200, 300
+	room.recordSessionHand(history)
+
+	report := room.sessionReport()
+	want := []SessionPlayer{
+		{Name: "Alice", BoughtIn: 1500, CashedOut: 700, Chips: 1500, Net: 700, Seated: true,
+			SessionHands: SessionHands{Hands: 2, BiggestWin: 300, BiggestLoss: 100}},
+		{Name: "Bob", BoughtIn: 500, Chips: 200, Net: -300, Seated: true,
+			SessionHands: SessionHands{Hands: 2, BiggestWin: 100, BiggestLoss: 300}},
+		{Name: "Carol", BoughtIn: 1000, Net: -1000},
+	}
+	if !reflect.DeepEqual(report.Players, want) {
+		t.Errorf("Unexpected players: %+v", report.Players)
+	}
+
+	// 锦标赛不计入
+	room.Tournament = &Tournament{}
+	room.recordSessionHand(history)
+	if room.Sessions["Alice"].Hands != 2 {
+		t.Error("Tournament hands should not be recorded")
+	}
+}
+
+// 测试结算建议：输得最多的玩家先付给赢得最多的玩家
+func TestSettleNets(t *testing.T) {
+	players := []SessionPlayer{
+		{Name: "A", Net: 700},
+		{Name: "B", Net: 300},
+		{Name: "C", Net: 0},
+		{Name: "D", Net: -200},
+		{Name: "E", Net: -800},
+	}
+	want := []Settlement{
+		{From: "E", To: "A", Amount: 700},
+		{From: "E", To: "B", Amount: 100},
+		{From: "D", To: "B", Amount: 200},
+	}
+	if got := settleNets(players); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected settlements: %+v", got)
+	}
+	if got := settleNets(nil); len(got) != 0 {
+		t.Errorf("Expected no settlements, got %+v", got)
+	}
+}
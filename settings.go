@@ -0,0 +1,121 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+const (
+	MAX_RUNS             = 3    // 全押后最多发牌次数
+	RUN_IT_TWICE_TIMEOUT = 15   // 多次发牌投票超时时间（秒）
+	ALL_IN_REVEAL_DELAY  = 1000 // 全押后每亮一次公共牌的间隔（毫秒）
+)
+
+// 房间设置（创建房间时指定）
+type RoomSettings struct {
+	MaxRuns    int  `json:"maxRuns"`    // 全押后允许发几次公共牌（1表示不启用多次发牌）
+	RabbitHunt bool `json:"rabbitHunt"` // 其他玩家都弃牌结束后，是否允许玩家查看没发出的公共牌
+
+	Visibility  string `json:"visibility"`  // public或private，私密房间不显示在大厅中
+	IdleMinutes int    `json:"idleMinutes"` // 没有玩家在座多少分钟后自动关闭房间
+
+	// 现金局买入限制（0表示不限制）
+	MinBuyIn       int `json:"minBuyIn"`       // 坐下时至少需要的筹码
+	MaxBuyIn       int `json:"maxBuyIn"`       // 买一手最多补到的筹码
+	MaxRebuys      int `json:"maxRebuys"`      // 每人最多买一手次数
+	RatholeMinutes int `json:"ratholeMinutes"` // 离桌后多少分钟内回到座位需要带上离桌时的筹码
+
+	SeparateSpectatorChat bool `json:"separateSpectatorChat"` // 观战的人的聊天消息只发给观战的人
+
+	// 观战设置
+	SpectatorDelay int    `json:"spectatorDelay"` // 牌局进展延迟多少秒发给观战的人（0表示不延迟）
+	SpectatorMode  string `json:"spectatorMode"`  // hidden（摊牌前看不到手牌）或omniscient（延迟后看到所有手牌）
+	MaxSpectators  int    `json:"maxSpectators"`  // 最多观战人数（0表示不限制）
+
+	Tournament *TournamentSettings `json:"tournament,omitempty"` // 不为空时房间是锦标赛（坐满即玩）
+}
+
+// 默认房间设置
+func defaultRoomSettings() RoomSettings {
+	return RoomSettings{
+		MaxRuns:     1,
+		Visibility:  RoomVisibilityPublic,
+		IdleMinutes: ROOM_IDLE_MINUTES,
+
+		RatholeMinutes: RATHOLE_MINUTES,
+
+		SpectatorMode: SpectatorModeHidden,
+	}
+}
+
+// 从创建房间请求中解析房间设置，未指定或无效的字段使用默认值
+func parseRoomSettings(data map[string]interface{}) RoomSettings {
+	raw, ok := data["settings"].(map[string]interface{})
+	if !ok {
+		return defaultRoomSettings()
+	}
+	return defaultRoomSettings().merge(raw)
+}
+
+// 用请求中指定的字段覆盖当前设置，未指定或无效的字段保持不变
+func (settings RoomSettings) merge(raw map[string]interface{}) RoomSettings {
+	if maxRuns, ok := raw["maxRuns"].(float64); ok {
+		settings.MaxRuns = int(maxRuns)
+	}
+	if settings.MaxRuns < 1 {
+		settings.MaxRuns = 1
+	}
+	if settings.MaxRuns > MAX_RUNS {
+		settings.MaxRuns = MAX_RUNS
+	}
+	if rabbitHunt, ok := raw["rabbitHunt"].(bool); ok {
+		settings.RabbitHunt = rabbitHunt
+	}
+	if visibility, ok := raw["visibility"].(string); ok && (visibility == RoomVisibilityPublic || visibility == RoomVisibilityPrivate) {
+		settings.Visibility = visibility
+	}
+	if idleMinutes, ok := raw["idleMinutes"].(float64); ok && idleMinutes >= 1 {
+		settings.IdleMinutes = int(idleMinutes)
+		if settings.IdleMinutes > MAX_ROOM_IDLE_MINUTES {
+			settings.IdleMinutes = MAX_ROOM_IDLE_MINUTES
+		}
+	}
+	for key, field := range map[string]*int{
+		"minBuyIn":      &settings.MinBuyIn,
+		"maxBuyIn":      &settings.MaxBuyIn,
+		"maxRebuys":     &settings.MaxRebuys,
+		"maxSpectators": &settings.MaxSpectators,
+	} {
+		if value, ok := raw[key].(float64); ok && value >= 0 {
+			*field = int(value)
+		}
+	}
+	if settings.MaxBuyIn > 0 && settings.MaxBuyIn < settings.MinBuyIn {
+		settings.MaxBuyIn = settings.MinBuyIn
+	}
+	if ratholeMinutes, ok := raw["ratholeMinutes"].(float64); ok && ratholeMinutes >= 0 {
+		settings.RatholeMinutes = int(ratholeMinutes)
+		if settings.RatholeMinutes > MAX_RATHOLE_MINUTES {
+			settings.RatholeMinutes = MAX_RATHOLE_MINUTES
+		}
+	}
+	if separate, ok := raw["separateSpectatorChat"].(bool); ok {
+		settings.SeparateSpectatorChat = separate
+	}
+	if delay, ok := raw["spectatorDelay"].(float64); ok && delay >= 0 {
+		settings.SpectatorDelay = int(delay)
+		if settings.SpectatorDelay > MAX_SPECTATOR_DELAY {
+			settings.SpectatorDelay = MAX_SPECTATOR_DELAY
+		}
+	}
+	if mode, ok := raw["spectatorMode"].(string); ok && (mode == SpectatorModeHidden || mode == SpectatorModeOmniscient) {
+		settings.SpectatorMode = mode
+	}
+	// 看到所有手牌必须有足够的延迟，否则观战的人可以把手牌告诉玩家
+	if settings.SpectatorMode == SpectatorModeOmniscient && settings.SpectatorDelay < OMNISCIENT_MIN_DELAY {
+		settings.SpectatorDelay = OMNISCIENT_MIN_DELAY
+	}
+	if tournament, ok := raw["tournament"].(map[string]interface{}); ok {
+		settings.Tournament = parseTournamentSettings(tournament)
+	}
+
+	return settings
+}
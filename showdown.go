@@ -0,0 +1,212 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"log"
+)
+
+// 无人跟注赢得底池后，获胜者可以选择亮牌
+type ShowCardsOffer struct {
+	PlayerID   string
+	HandNumber int
+	Hand       []Card
+	Shown      bool
+}
+
+// 按摊牌规则决定亮牌顺序以及哪些玩家亮牌（调用者需持有写锁）
+// 全押摊牌时所有未弃牌玩家都亮牌；否则最后加注者先亮，本轮没有人下注时从庄家下一位开始，
+// 之后的玩家只有在能赢或打平已亮出的牌时才亮牌，否则自动盖牌（除非玩家关闭了自动盖牌）
+// 已弃牌玩家的手牌永远不会亮出
+func (room *GameRoom) showdownReveal(activePlayers []*Player, boards [][]Card) ([]*Player, map[string][]Card) {
+	revealed := make(map[string][]Card)
+	if len(room.Players) == 0 {
+		return nil, revealed
+	}
+
+	isActive := make(map[string]bool)
+	for _, p := range activePlayers {
+		isActive[p.ID] = true
+	}
+
+	// 找到第一个亮牌的玩家
+	start := -1
+	if room.LastAggressorID != "" && isActive[room.LastAggressorID] {
+		for i, p := range room.Players {
+			if p.ID == room.LastAggressorID {
+				start = i
+				break
+			}
+		}
+	}
+	if start == -1 {
+		start = (room.DealerIndex + 1) % len(room.Players)
+	}
+
+	// 从第一个亮牌的玩家开始按座位顺序排列
+	order := []*Player{}
+	for i := 0; i < len(room.Players); i++ {
+		p := room.Players[(start+i)%len(room.Players)]
+		if isActive[p.ID] {
+			order = append(order, p)
+		}
+	}
+
+	// 每套公共牌上已亮出的最大牌型
+	best := make([]HandRank, len(boards))
+	for i := range best {
+		best[i].Rank = -1
+	}
+
+	for i, p := range order {
+		ranks := make([]HandRank, len(boards))
+		contends := false
+		for b, board := range boards {
+			ranks[b] = evaluateHand(append([]Card{}, p.Hand...), board)
+			if compareHandRanks(ranks[b], best[b]) >= 0 {
+				contends = true
+			}
+		}
+
+		show := i == 0 || room.AllInRunout || contends || p.ShowLosingHands
+		if !show {
+			log.Printf("玩家 %s 摊牌时盖牌，房间 %s", p.Name, room.ID)
+			room.recordHandEvent(HandEvent{
+				Type:     HandEventShow,
+				PlayerID: p.ID,
+				Name:     p.Name,
+				Action:   "muck",
+			})
+			continue
+		}
+
+		revealed[p.ID] = p.Hand
+		room.recordHandEvent(HandEvent{
+			Type:     HandEventShow,
+			PlayerID: p.ID,
+			Name:     p.Name,
+			Action:   "show",
+			Cards:    append([]Card{}, p.Hand...),
+		})
+		for b := range boards {
+			if compareHandRanks(ranks[b], best[b]) > 0 {
+				best[b] = ranks[b]
+			}
+		}
+	}
+
+	return order, revealed
+}
+
+// 构建结算消息中的手牌信息：只包含亮出的手牌，盖牌和弃牌的玩家不包含手牌
+func showdownHands(players []*Player, revealed map[string][]Card) []map[string]interface{} {
+	hands := make([]map[string]interface{}, len(players))
+	for i, p := range players {
+		hands[i] = map[string]interface{}{
+			"id":     p.ID,
+			"name":   p.Name,
+			"folded": p.Folded,
+			"chips":  p.Chips,
+		}
+		if cards, ok := revealed[p.ID]; ok {
+			hands[i]["hand"] = cards
+		} else if !p.Folded {
+			hands[i]["mucked"] = true
+		}
+	}
+	return hands
+}
+
+// 无人跟注赢得底池后，记录获胜者可以选择亮牌（调用者需持有写锁）
+func (room *GameRoom) offerShowCards(winner *Player) {
+	room.ShowCardsOffer = &ShowCardsOffer{
+		PlayerID:   winner.ID,
+		HandNumber: room.HandCount,
+		Hand:       append([]Card{}, winner.Hand...),
+	}
+}
+
+// 设置摊牌时是否自动盖掉输的牌
+func setAutoMuck(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	autoMuck := true
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		if enabled, ok := data["autoMuck"].(bool); ok {
+			autoMuck = enabled
+		}
+	}
+
+	room.Mutex.Lock()
+	player.ShowLosingHands = !autoMuck
+	room.Mutex.Unlock()
+
+	log.Printf("玩家 %s 设置自动盖牌: %v，房间 %s", player.Name, autoMuck, room.ID)
+	sendMessage(player, Message{
+		Type: "autoMuckUpdated",
+		Data: map[string]interface{}{
+			"autoMuck": autoMuck,
+		},
+	})
+}
+
+// 无人跟注赢得底池的玩家选择亮牌
+func handleShowCards(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "房间不存在"},
+		})
+		return
+	}
+
+	room.Mutex.Lock()
+	offer := room.ShowCardsOffer
+	if offer == nil || offer.PlayerID != player.ID || offer.Shown {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "当前不能亮牌"},
+		})
+		return
+	}
+	offer.Shown = true
+
+	// 补充到该局的牌局记录中
+	history := room.appendFinishedHandEvent(offer.HandNumber, HandEvent{
+		Type:     HandEventShow,
+		PlayerID: player.ID,
+		Name:     player.Name,
+		Action:   "show",
+		Cards:    append([]Card{}, offer.Hand...),
+	})
+	if history != nil {
+		for _, hp := range history.Players {
+			if hp.ID == player.ID {
+				hp.Hand = append([]Card{}, offer.Hand...)
+			}
+		}
+	}
+	recipients := room.recipients()
+	room.Mutex.Unlock()
+
+	log.Printf("玩家 %s 亮出手牌，房间 %s，第 %d 局", player.Name, room.ID, offer.HandNumber)
+	sendToPlayers(recipients, Message{
+		Type: "cardsShown",
+		Data: map[string]interface{}{
+			"playerId":   player.ID,
+			"name":       player.Name,
+			"hand":       offer.Hand,
+			"handNumber": offer.HandNumber,
+		},
+	})
+}
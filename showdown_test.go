@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+)
+
+// 测试摊牌顺序：最后加注者先亮牌，之后输的玩家自动盖牌
+func TestShowdownRevealOrder(t *testing.T) {
+	alice := &Player{ID: "a", Name: "Alice", Hand: []Card{{"spades", "A"}, {"hearts", "A"}}}
+	bob := &Player{ID: "b", Name: "Bob", Hand: []Card{{"spades", "K"}, {"hearts", "K"}}}
+	carol := &Player{ID: "c", Name: "Carol", Hand: []Card{{"spades", "3"}, {"hearts", "3"}}, Folded: true}
+	room := &GameRoom{
+		ID:              "test_showdown",
+		Players:         []*Player{alice, bob, carol},
+		DealerIndex:     0,
+		LastAggressorID: "a",
+	}
+	board := []Card{{"clubs", "2"}, {"diamonds", "7"}, {"clubs", "9"}, {"diamonds", "J"}, {"clubs", "4"}}
+	active := []*Player{alice, bob}
+
+	order, revealed := room.showdownReveal(active, [][]Card{board})
+	if len(order) != 2 || order[0] != alice || order[1] != bob {
+		t.Fatalf("Expected Alice then Bob, got %v", order)
+	}
+	if _, ok := revealed["a"]; !ok {
+		t.Error("Alice should show first as last aggressor")
+	}
+	if _, ok := revealed["b"]; ok {
+		t.Error("Bob should muck a losing hand")
+	}
+	if _, ok := revealed["c"]; ok {
+		t.Error("Folded player should never be revealed")
+	}
+
+	// 没有最后加注者时从庄家下一位开始亮牌，Bob先亮，Alice能赢所以也亮牌
+	room.LastAggressorID = ""
+	order, revealed = room.showdownReveal(active, [][]Card{board})
+	if order[0] != bob || len(revealed) != 2 {
+		t.Errorf("Expected Bob first and both hands shown, got order %v revealed %v", order, revealed)
+	}
+
+	// 关闭自动盖牌后输的牌也会亮出
+	room.LastAggressorID = "a"
+	bob.ShowLosingHands = true
+	_, revealed = room.showdownReveal(active, [][]Card{board})
+	if _, ok := revealed["b"]; !ok {
+		t.Error("Bob should show when auto muck is disabled")
+	}
+
+	hands := showdownHands(room.Players, map[string][]Card{"a": alice.Hand})
+	if hands[1]["mucked"] != true || hands[1]["hand"] != nil {
+		t.Errorf("Expected Bob's hand to be mucked, got %v", hands[1])
+	}
+	if hands[2]["hand"] != nil || hands[2]["mucked"] != nil {
+		t.Errorf("Folded player should have no hand and not be marked mucked, got %v", hands[2])
+	}
+}
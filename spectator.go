@@ -0,0 +1,203 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	MAX_SPECTATOR_DELAY  = 600 // 观战延迟最长的秒数
+	OMNISCIENT_MIN_DELAY = 30  // 观战看到所有手牌时至少延迟的秒数
+)
+
+// 观战模式
+const (
+	SpectatorModeHidden     = "hidden"     // 摊牌前看不到任何人的手牌
+	SpectatorModeOmniscient = "omniscient" // 延迟后看到所有人的手牌（用于直播）
+)
+
+// 设置了观战延迟时，发给观战的人的带有房间信息的消息都延迟发送（房间信息就是当前牌局的状态），
+// 以下例外立即发送：进入房间时需要马上显示房间
+var spectatorImmediateTypes = map[string]bool{
+	"roomCreated": true,
+	"roomJoined":  true,
+}
+
+// 不带房间信息但属于牌局进展的消息，同样延迟发送；其他消息（错误、聊天、钱包等）立即发送
+var spectatorDelayedTypes = map[string]bool{
+	"allInEquity":        true,
+	"cardsShown":         true,
+	"rabbitRevealed":     true,
+	"runItTwiceOffer":    true,
+	"runItTwiceVoted":    true,
+	"blindLevelUp":       true,
+	"handForHand":        true,
+	"playerEliminated":   true,
+	"tournamentFinished": true,
+	"dealProposed":       true,
+	"dealAccepted":       true,
+	"dealRejected":       true,
+}
+
+// 发给观战的人时是否延迟发送
+func spectatorDelayed(msg Message) bool {
+	if spectatorImmediateTypes[msg.Type] {
+		return false
+	}
+	return messageRoomData(msg) != nil || spectatorDelayedTypes[msg.Type]
+}
+
+type delayedMessage struct {
+	At  time.Time
+	Msg Message
+}
+
+// 观战的人的延迟设置和等待发送的消息，延迟设置从发给他的房间信息中更新
+type spectatorView struct {
+	mutex      sync.Mutex
+	delay      time.Duration
+	omniscient bool
+	queue      []delayedMessage
+	sending    bool
+}
+
+// 消息中的房间信息（消息本身或data.room），没有时返回nil
+func messageRoomData(msg Message) map[string]interface{} {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if isRoomData(data) {
+		return data
+	}
+	if room, ok := data["room"].(map[string]interface{}); ok && isRoomData(room) {
+		return room
+	}
+	return nil
+}
+
+// 是否是ToJSON生成的房间信息
+func isRoomData(data map[string]interface{}) bool {
+	_, hasPlayers := data["players"].([]map[string]interface{})
+	_, hasSettings := data["settings"].(RoomSettings)
+	return hasPlayers && hasSettings
+}
+
+// 隐藏房间信息中除viewerID以外的玩家的手牌，showAll为true时不隐藏
+// 房间信息会发给多个人，这里返回副本，不修改原来的数据
+func redactRoomData(room map[string]interface{}, viewerID string, showAll bool) map[string]interface{} {
+	if showAll {
+		return room
+	}
+	players := room["players"].([]map[string]interface{})
+	redactedPlayers := make([]map[string]interface{}, len(players))
+	for i, p := range players {
+		if id, _ := p["id"].(string); id == viewerID && viewerID != "" {
+			redactedPlayers[i] = p
+			continue
+		}
+		c := make(map[string]interface{}, len(p))
+		for k, v := range p {
+			c[k] = v
+		}
+		c["hand"] = []Card{}
+		redactedPlayers[i] = c
+	}
+	redacted := make(map[string]interface{}, len(room))
+	for k, v := range room {
+		redacted[k] = v
+	}
+	redacted["players"] = redactedPlayers
+	return redacted
+}
+
+// 某个人看到的消息：房间信息中只保留他自己的手牌（showAll为true时保留所有手牌）
+func viewFor(msg Message, viewerID string, showAll bool) Message {
+	room := messageRoomData(msg)
+	if room == nil {
+		return msg
+	}
+	redacted := redactRoomData(room, viewerID, showAll)
+	data := msg.Data.(map[string]interface{})
+	if isRoomData(data) {
+		msg.Data = redacted
+		return msg
+	}
+	c := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		c[k] = v
+	}
+	c["room"] = redacted
+	msg.Data = c
+	return msg
+}
+
+// 发给观战的人：房间设置了观战延迟时，牌局进展的消息延迟发送
+// 立即发送的消息看不到任何手牌，延迟发送的消息在全知模式下可以看到所有手牌
+func (player *Player) sendSpectatorMessage(msg Message) {
+	v := &player.spectatorView
+	v.mutex.Lock()
+	if room := messageRoomData(msg); room != nil {
+		settings := room["settings"].(RoomSettings)
+		v.delay = time.Duration(settings.SpectatorDelay) * time.Second
+		v.omniscient = settings.SpectatorMode == SpectatorModeOmniscient
+	}
+	if v.delay <= 0 || !spectatorDelayed(msg) {
+		v.mutex.Unlock()
+		writeMessage(player, viewFor(msg, "", false))
+		return
+	}
+
+	v.queue = append(v.queue, delayedMessage{At: time.Now().Add(v.delay), Msg: viewFor(msg, "", v.omniscient)})
+	if !v.sending {
+		v.sending = true
+		go player.flushDelayed()
+	}
+	v.mutex.Unlock()
+}
+
+// 按顺序发送延迟的消息，发完后退出
+func (player *Player) flushDelayed() {
+	v := &player.spectatorView
+	for {
+		v.mutex.Lock()
+		if len(v.queue) == 0 {
+			v.sending = false
+			v.mutex.Unlock()
+			return
+		}
+		next := v.queue[0]
+		v.queue = v.queue[1:]
+		v.mutex.Unlock()
+
+		time.Sleep(time.Until(next.At))
+		// 已经上桌的人不再需要延迟的画面
+		if player.Status != PlayerStatusSpectating {
+			continue
+		}
+		writeMessage(player, next.Msg)
+	}
+}
+
+// 观战的人能看到的牌局记录的结束时间（调用者需持有锁），设置了观战延迟时还在延迟中的牌局不返回
+func (room *GameRoom) historyCutoff(player *Player, now time.Time) time.Time {
+	if room.Settings.SpectatorDelay <= 0 || !room.isSpectator(player.ID) {
+		return now
+	}
+	return now.Add(-time.Duration(room.Settings.SpectatorDelay) * time.Second)
+}
+
+// 只包含公开信息的玩家数据（不包含手牌）
+func publicPlayer(p *Player) map[string]interface{} {
+	if p == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"id":    p.ID,
+		"name":  p.Name,
+		"chips": p.Chips,
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// 测试房间信息按收到的人隐藏手牌，原来的数据不被修改
+func TestViewFor(t *testing.T) {
+	alice := &Player{ID: "va", Name: "Alice", Hand: []Card{{Suit: "spades", Rank: "A"}, {Suit: "hearts", Rank: "A"}}}
+	bob := &Player{ID: "vb", Name: "Bob", Hand: []Card{{Suit: "clubs", Rank: "2"}, {Suit: "diamonds", Rank: "7"}}}
+	room := &GameRoom{ID: "test_view", Players: []*Player{alice, bob}, Settings: defaultRoomSettings()}
+	roomData := room.ToJSON()
+	hand := func(msg Message, i int) []Card {
+		data := messageRoomData(msg)
+		return data["players"].([]map[string]interface{})[i]["hand"].([]Card)
+	}
+
+	nested := Message{Type: "roomJoined", Data: map[string]interface{}{"room": roomData, "isSpectating": false}}
+	view := viewFor(nested, alice.ID, false)
+	if len(hand(view, 0)) != 2 || len(hand(view, 1)) != 0 {
+		t.Error("Alice should only see her own hand")
+	}
+	if view.Data.(map[string]interface{})["isSpectating"] != false {
+		t.Error("Other fields should be kept")
+	}
+	if len(hand(nested, 1)) != 2 {
+		t.Error("Original room data should not be modified")
+	}
+
+	direct := Message{Type: "actionTaken", Data: roomData}
+	if view := viewFor(direct, "", false); len(hand(view, 0)) != 0 || len(hand(view, 1)) != 0 {
+		t.Error("Spectators should not see any hand")
+	}
+	if view := viewFor(direct, "", true); len(hand(view, 0)) != 2 || len(hand(view, 1)) != 2 {
+		t.Error("Omniscient view should see all hands")
+	}
+
+	other := Message{Type: "error", Data: map[string]string{"message": "x"}}
+	if view := viewFor(other, alice.ID, false); view.Data.(map[string]string)["message"] != "x" {
+		t.Error("Messages without room data should not change")
+	}
+}
+
+// 测试观战设置：全知模式至少延迟OMNISCIENT_MIN_DELAY秒，延迟中的牌局记录不返回给观战的人
+func TestSpectatorSettings(t *testing.T) {
+	settings := defaultRoomSettings().merge(map[string]interface{}{"spectatorMode": "omniscient", "maxSpectators": float64(3)})
+	if settings.SpectatorDelay != OMNISCIENT_MIN_DELAY || settings.MaxSpectators != 3 {
+		t.Errorf("Unexpected settings: %+v", settings)
+	}
+	settings = settings.merge(map[string]interface{}{"spectatorDelay": float64(MAX_SPECTATOR_DELAY + 1), "spectatorMode": "bogus"})
+	if settings.SpectatorDelay != MAX_SPECTATOR_DELAY || settings.SpectatorMode != SpectatorModeOmniscient {
+		t.Errorf("Unexpected settings: %+v", settings)
+	}
+
+	alice := &Player{ID: "va"}
+	bob := &Player{ID: "vb"}
+	room := &GameRoom{Players: []*Player{alice}, Spectators: []*Player{bob}, Settings: settings}
+	now := time.Now()
+	if !room.historyCutoff(alice, now).Equal(now) {
+		t.Error("Players should see all hand histories")
+	}
+	if want := now.Add(-MAX_SPECTATOR_DELAY * time.Second); !room.historyCutoff(bob, now).Equal(want) {
+		t.Error("Spectators should not see hands within the delay")
+	}
+}
+
+// 测试观战延迟：牌局进行中带有房间信息的消息（包括玩家加入、暂停等）都延迟发送，进入房间和聊天立即发送
+func TestSpectatorDelayedMessages(t *testing.T) {
+	conn := &httpConn{signal: make(chan struct{}), done: make(chan struct{})}
+	bob := &Player{ID: "sd_b", Name: "Bob", Conn: conn, Status: PlayerStatusSpectating}
+	alice := &Player{ID: "sd_a", Name: "Alice", Hand: []Card{{Suit: "spades", Rank: "A"}, {Suit: "hearts", Rank: "A"}}}
+	settings := defaultRoomSettings()
+	settings.SpectatorDelay = 1
+	room := &GameRoom{ID: "test_spectator_delay", Players: []*Player{alice}, Spectators: []*Player{bob}, GamePhase: "flop", Settings: settings}
+	reader := conn.attach()
+	received := func() []string {
+		messages, _, _ := conn.take(reader, 0, 0)
+		types := []string{}
+		for _, m := range messages {
+			var msg Message
+			json.Unmarshal(m.Data, &msg)
+			types = append(types, msg.Type)
+		}
+		return types
+	}
+
+	sendMessage(bob, Message{Type: "roomJoined", Data: map[string]interface{}{"room": room.ToJSON()}})
+	for _, msgType := range []string{"playerJoined", "gamePaused", "settingsUpdated", "tableMoved"} {
+		sendMessage(bob, Message{Type: msgType, Data: map[string]interface{}{"room": room.ToJSON()}})
+	}
+	sendMessage(bob, Message{Type: "chat", Data: map[string]interface{}{"text": "hi"}})
+	if types := received(); len(types) != 2 || types[0] != "roomJoined" || types[1] != "chat" {
+		t.Fatalf("Only roomJoined and chat should be sent right away, got %v", types)
+	}
+
+	time.Sleep(1200 * time.Millisecond)
+	if types := received(); len(types) != 6 || types[2] != "playerJoined" || types[5] != "tableMoved" {
+		t.Errorf("Room updates should arrive after the delay, got %v", types)
+	}
+}
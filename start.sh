@@ -14,4 +14,4 @@ go mod download 2>/dev/null || true
 go mod tidy 2>/dev/null || true
 
 echo "启动服务器..."
-go run main.go game.go
+go run .
@@ -0,0 +1,310 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// 玩家统计的计数，每局结束后按该局的记录累加
+type PlayerStats struct {
+	Name            string  `json:"name"`
+	Hands           int     `json:"hands"`           // 参与的局数
+	HandsWon        int     `json:"handsWon"`        // 赢得（或分得）底池的局数
+	PreflopHands    int     `json:"preflopHands"`    // 有翻牌前行动的局数（炸弹底池没有翻牌前）
+	VPIPHands       int     `json:"vpipHands"`       // 翻牌前主动投入筹码（跟注或加注）的局数
+	PFRHands        int     `json:"pfrHands"`        // 翻牌前加注的局数
+	ThreeBetChances int     `json:"threeBetChances"` // 翻牌前面对一次加注的局数
+	ThreeBets       int     `json:"threeBets"`       // 面对一次加注时再加注的局数
+	Aggressive      int     `json:"aggressive"`      // 翻牌后下注和加注的次数
+	Calls           int     `json:"calls"`           // 翻牌后跟注的次数
+	SawFlop         int     `json:"sawFlop"`         // 看到翻牌的局数
+	WentToShowdown  int     `json:"wentToShowdown"`  // 到摊牌的局数
+	WonAtShowdown   int     `json:"wonAtShowdown"`   // 摊牌时赢得底池的局数
+	NetBB           float64 `json:"netBB"`           // 以大盲计的输赢
+}
+
+// 发给客户端的统计：计数和计算出的比例（百分比）
+type StatsSummary struct {
+	PlayerStats
+	WinRate          float64 `json:"winRate"`          // 赢得底池的局数 / 参与的局数
+	VPIP             float64 `json:"vpip"`             // 主动入池率
+	PFR              float64 `json:"pfr"`              // 翻牌前加注率
+	ThreeBet         float64 `json:"threeBet"`         // 3-bet率
+	AggressionFactor float64 `json:"aggressionFactor"` // 翻牌后（下注+加注）/ 跟注
+	WTSD             float64 `json:"wtsd"`             // 看到翻牌后到摊牌的比例
+	WSD              float64 `json:"wsd"`              // 摊牌时赢的比例
+	BBPer100         float64 `json:"bbPer100"`         // 每100局赢的大盲数
+}
+
+// 所有房间的统计（按昵称，已登录玩家的昵称就是用户名）
+var overallStats = make(map[string]*PlayerStats)
+var statsMutex sync.RWMutex
+
+// 累加另一份统计
+func (s *PlayerStats) add(other *PlayerStats) {
+	s.Hands += other.Hands
+	s.HandsWon += other.HandsWon
+	s.PreflopHands += other.PreflopHands
+	s.VPIPHands += other.VPIPHands
+	s.PFRHands += other.PFRHands
+	s.ThreeBetChances += other.ThreeBetChances
+	s.ThreeBets += other.ThreeBets
+	s.Aggressive += other.Aggressive
+	s.Calls += other.Calls
+	s.SawFlop += other.SawFlop
+	s.WentToShowdown += other.WentToShowdown
+	s.WonAtShowdown += other.WonAtShowdown
+	s.NetBB += other.NetBB
+}
+
+// 百分比，保留一位小数
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(n)*1000/float64(total)) / 10
+}
+
+func (s *PlayerStats) summary() StatsSummary {
+	summary := StatsSummary{
+		PlayerStats: *s,
+		WinRate:     percent(s.HandsWon, s.Hands),
+		VPIP:        percent(s.VPIPHands, s.PreflopHands),
+		PFR:         percent(s.PFRHands, s.PreflopHands),
+		ThreeBet:    percent(s.ThreeBets, s.ThreeBetChances),
+		WTSD:        percent(s.WentToShowdown, s.SawFlop),
+		WSD:         percent(s.WonAtShowdown, s.WentToShowdown),
+	}
+	summary.NetBB = math.Round(s.NetBB*100) / 100
+	if s.Calls > 0 {
+		summary.AggressionFactor = math.Round(float64(s.Aggressive)*100/float64(s.Calls)) / 100
+	} else {
+		summary.AggressionFactor = float64(s.Aggressive)
+	}
+	if s.Hands > 0 {
+		summary.BBPer100 = math.Round(s.NetBB*100/float64(s.Hands)*100) / 100
+	}
+	return summary
+}
+
+// 根据一局的记录计算每个玩家这一局的统计（按玩家ID）
+// seated为结束时还在座位上的玩家，中途离开的玩家按投入的筹码计算输赢
+func handStats(history *HandHistory, bigBlind int, seated map[string]bool) map[string]*PlayerStats {
+	stats := make(map[string]*PlayerStats, len(history.Players))
+	for _, hp := range history.Players {
+		stats[hp.ID] = &PlayerStats{Name: hp.Name, Hands: 1}
+	}
+
+	folded := make(map[string]bool)
+	bets := make(map[string]int) // 本轮下注
+	currentBet := 0
+	preflopRaises := 0 // 翻牌前的加注次数（不算盲注）
+	preflop := false
+	flopSeen := false
+	for _, e := range history.Events {
+		switch e.Type {
+		case HandEventStreet:
+			if !flopSeen {
+				flopSeen = true
+				for id, s := range stats {
+					if !folded[id] {
+						s.SawFlop = 1
+					}
+				}
+			}
+			bets = make(map[string]int)
+			currentBet = 0
+		case HandEventAction:
+			s := stats[e.PlayerID]
+			if s == nil {
+				continue
+			}
+			if e.Action == "ante" {
+				continue
+			}
+			bets[e.PlayerID] += e.Amount
+			raise := e.Action == "raise" && bets[e.PlayerID] > currentBet
+			if bets[e.PlayerID] > currentBet {
+				currentBet = bets[e.PlayerID]
+			}
+
+			switch {
+			case e.Action == "smallBlind" || e.Action == "bigBlind":
+			case e.Phase == "preflop":
+				preflop = true
+				if preflopRaises == 1 {
+					s.ThreeBetChances = 1
+					if raise {
+						s.ThreeBets = 1
+					}
+				}
+				if e.Action == "call" || e.Action == "raise" {
+					s.VPIPHands = 1
+				}
+				if raise {
+					s.PFRHands = 1
+					preflopRaises++
+				}
+			case raise:
+				s.Aggressive++
+			case e.Action == "call" || e.Action == "raise":
+				s.Calls++
+			}
+			if e.Action == "fold" {
+				folded[e.PlayerID] = true
+			}
+		}
+	}
+
+	remaining := 0
+	for id := range stats {
+		if !folded[id] {
+			remaining++
+		}
+	}
+	winners := make(map[string]bool, len(history.Winners))
+	for _, name := range history.Winners {
+		winners[name] = true
+	}
+	nets := handNets(history, seated)
+	for _, hp := range history.Players {
+		s := stats[hp.ID]
+		if preflop {
+			s.PreflopHands = 1
+		}
+		if winners[hp.Name] {
+			s.HandsWon = 1
+		}
+		if remaining >= 2 && !folded[hp.ID] {
+			s.WentToShowdown = 1
+			s.WonAtShowdown = s.HandsWon
+		}
+		if bigBlind > 0 {
+			s.NetBB = float64(nets[hp.ID]) / float64(bigBlind)
+		}
+	}
+	return stats
+}
+
+// 每个玩家这一局投入的筹码（按玩家ID）
+func handCommitted(history *HandHistory) map[string]int {
+	committed := make(map[string]int, len(history.Players))
+	for _, e := range history.Events {
+		if e.Type == HandEventAction {
+			committed[e.PlayerID] += e.Amount
+		}
+	}
+	return committed
+}
+
+// 每个玩家这一局的输赢（按玩家ID），中途离开的玩家按投入的筹码计算
+func handNets(history *HandHistory, seated map[string]bool) map[string]int {
+	committed := handCommitted(history)
+	nets := make(map[string]int, len(history.Players))
+	for _, hp := range history.Players {
+		nets[hp.ID] = -committed[hp.ID]
+		if seated[hp.ID] {
+			nets[hp.ID] = hp.EndChips - hp.StartChips
+		}
+	}
+	return nets
+}
+
+// 还在座位上的玩家（调用者需持有锁）
+func (room *GameRoom) seatedIDs() map[string]bool {
+	seated := make(map[string]bool, len(room.Players))
+	for _, p := range room.Players {
+		seated[p.ID] = true
+	}
+	return seated
+}
+
+// 一局结束后累加房间和所有房间的统计（调用者需持有写锁）
+func (room *GameRoom) recordHandStats(history *HandHistory) {
+	if room.Stats == nil {
+		room.Stats = make(map[string]*PlayerStats)
+	}
+
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	for _, s := range handStats(history, room.BigBlind, room.seatedIDs()) {
+		if room.Stats[s.Name] == nil {
+			room.Stats[s.Name] = &PlayerStats{Name: s.Name}
+		}
+		room.Stats[s.Name].add(s)
+		if overallStats[s.Name] == nil {
+			overallStats[s.Name] = &PlayerStats{Name: s.Name}
+		}
+		overallStats[s.Name].add(s)
+	}
+}
+
+// 统计列表，按参与的局数从多到少排列，name不为空时只返回该玩家
+func statsSummaries(all map[string]*PlayerStats, name string) []StatsSummary {
+	list := []StatsSummary{}
+	for _, s := range all {
+		if name == "" || s.Name == name {
+			list = append(list, s.summary())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Hands != list[j].Hands {
+			return list[i].Hands > list[j].Hands
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// 查询玩家统计：scope为room（默认）时是当前房间的统计，为overall时是所有房间的统计
+// playerName可选，只查询该玩家
+func getStats(player *Player, msg *Message) {
+	scope, name := "room", ""
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		if s, ok := data["scope"].(string); ok && s != "" {
+			scope = s
+		}
+		name, _ = data["playerName"].(string)
+	}
+
+	var list []StatsSummary
+	roomID := ""
+	switch scope {
+	case "overall":
+		statsMutex.RLock()
+		list = statsSummaries(overallStats, name)
+		statsMutex.RUnlock()
+	case "room":
+		room := findPlayerRoom(player)
+		if room == nil {
+			sendMessage(player, Message{
+				Type: "error",
+				Data: map[string]string{"message": "房间不存在"},
+			})
+			return
+		}
+		roomID = room.ID
+		room.Mutex.RLock()
+		list = statsSummaries(room.Stats, name)
+		room.Mutex.RUnlock()
+	default:
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "统计范围无效"},
+		})
+		return
+	}
+
+	sendMessage(player, Message{
+		Type: "stats",
+		Data: map[string]interface{}{
+			"scope":  scope,
+			"roomId": roomID,
+			"stats":  list,
+		},
+	})
+}
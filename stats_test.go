@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+)
+
+// 测试按一局的记录计算统计：盲注不算主动入池，面对一次加注再加注算3-bet，翻牌后的下注和跟注计入激进度
+func TestHandStats(t *testing.T) {
+	action := func(phase, id, act string, amount int) HandEvent {
+		return HandEvent{Type: HandEventAction, Phase: phase, PlayerID: id, Action: act, Amount: amount}
+	}
+	history := &HandHistory{
+		Players: []*HandHistoryPlayer{
+			{ID: "a", Name: "Alice", StartChips: 1000, EndChips: 1090},
+			{ID: "b", Name: "Bob", StartChips: 1000, EndChips: 995},
+			{ID: "c", Name: "Carol", StartChips: 1000, EndChips: 910},
+			{ID: "d", Name: "Dave", StartChips: 1000},
+		},
+		Events: []HandEvent{
+			action("preflop", "b", "smallBlind", 5),
+			action("preflop", "c", "bigBlind", 10),
+			action("preflop", "d", "call", 10),
+			action("preflop", "a", "raise", 30),
+			action("preflop", "b", "fold", 0),
+			action("preflop", "c", "raise", 80),
+			action("preflop", "d", "fold", 0),
+			action("preflop", "a", "call", 60),
+			{Type: HandEventStreet, Phase: "flop"},
+			action("flop", "c", "check", 0),
+			action("flop", "a", "raise", 50),
+			action("flop", "c", "call", 50),
+			{Type: HandEventStreet, Phase: "turn"},
+			{Type: HandEventStreet, Phase: "river"},
+		},
+		Winners: []string{"Alice"},
+	}
+	stats := handStats(history, 10, map[string]bool{"a": true, "b": true, "c": true})
+
+	alice, bob, carol, dave := stats["a"], stats["b"], stats["c"], stats["d"]
+	if alice.VPIPHands != 1 || alice.PFRHands != 1 || alice.ThreeBetChances != 0 || alice.Aggressive != 1 || alice.Calls != 0 {
+		t.Errorf("Unexpected stats for Alice: %+v", alice)
+	}
+	if alice.SawFlop != 1 || alice.WentToShowdown != 1 || alice.WonAtShowdown != 1 || alice.NetBB != 9 {
+		t.Errorf("Unexpected showdown stats for Alice: %+v", alice)
+	}
+	if bob.VPIPHands != 0 || bob.ThreeBetChances != 1 || bob.SawFlop != 0 || bob.NetBB != -0.5 {
+		t.Errorf("Unexpected stats for Bob: %+v", bob)
+	}
+	if carol.VPIPHands != 1 || carol.ThreeBets != 1 || carol.Calls != 1 || carol.WentToShowdown != 1 || carol.WonAtShowdown != 0 {
+		t.Errorf("Unexpected stats for Carol: %+v", carol)
+	}
+	// Dave中途离开，按投入的筹码计算输赢
+	if dave.VPIPHands != 1 || dave.PFRHands != 0 || dave.NetBB != -1 {
+		t.Errorf("Unexpected stats for Dave: %+v", dave)
+	}
+}
+
+// 测试累加统计和计算比例
+func TestStatsSummary(t *testing.T) {
+	s := &PlayerStats{Name: "Alice"}
+	s.add(&PlayerStats{Hands: 3, HandsWon: 1, PreflopHands: 3, VPIPHands: 2, PFRHands: 1, Aggressive: 3, Calls: 2, SawFlop: 2, WentToShowdown: 1, NetBB: 4.5})
+	s.add(&PlayerStats{Hands: 1, PreflopHands: 1, NetBB: -0.5})
+
+	summary := s.summary()
+	if summary.VPIP != 50 || summary.PFR != 25 || summary.WinRate != 25 || summary.WTSD != 50 {
+		t.Errorf("Unexpected percentages: %+v", summary)
+	}
+	if summary.AggressionFactor != 1.5 || summary.BBPer100 != 100 {
+		t.Errorf("Unexpected aggression factor or win rate: %+v", summary)
+	}
+}
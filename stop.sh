@@ -3,7 +3,7 @@
 echo "正在关闭所有服务..."
 
 # 关闭Go进程
-pkill -f "go run main.go" 2>/dev/null
+pkill -f "go run \." 2>/dev/null
 pkill -f "poker-server" 2>/dev/null
 pkill -f "main.go" 2>/dev/null
 
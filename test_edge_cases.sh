@@ -6,7 +6,7 @@ echo "=== 德州扑克边缘情况测试 ==="
 echo ""
 
 # 检查服务器是否在运行
-if ! pgrep -f "go run main.go" > /dev/null && ! pgrep -f "poker-server" > /dev/null; then
+if ! pgrep -f "go run" > /dev/null && ! pgrep -f "poker-server" > /dev/null; then
     echo "启动服务器..."
     cd /home/laighno/go/src/awesomeProject
     timeout 30 bash start.sh > /tmp/poker_test.log 2>&1 &
@@ -6,7 +6,7 @@ echo "=== 多轮游戏测试（包含新玩家加入和退出） ==="
 echo ""
 
 # 检查服务器是否在运行
-if ! pgrep -f "go run main.go" > /dev/null && ! pgrep -f "poker-server" > /dev/null; then
+if ! pgrep -f "go run" > /dev/null && ! pgrep -f "poker-server" > /dev/null; then
     echo "启动服务器..."
     cd /home/laighno/go/src/awesomeProject
     timeout 300 bash start.sh > /tmp/poker_multi_rounds.log 2>&1 &
@@ -7,7 +7,7 @@ echo "场景：4人开局 -> 第5人进入 -> 完成一局 -> 第二局无法开
 echo ""
 
 # 检查服务器是否在运行
-if ! pgrep -f "go run main.go" > /dev/null && ! pgrep -f "poker-server" > /dev/null; then
+if ! pgrep -f "go run" > /dev/null && ! pgrep -f "poker-server" > /dev/null; then
     echo "启动服务器..."
     cd /home/laighno/go/src/awesomeProject
     timeout 180 bash start.sh > /tmp/poker_new_player_test.log 2>&1 &
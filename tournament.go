@@ -0,0 +1,550 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+const (
+	MIN_TOURNAMENT_PLAYERS = 2 // 锦标赛开始后继续比赛的最少玩家数
+)
+
+// 盲注级别
+type BlindLevel struct {
+	SmallBlind int `json:"smallBlind"`
+	BigBlind   int `json:"bigBlind"`
+	Ante       int `json:"ante"`
+}
+
+// 默认的盲注级别表
+var defaultBlindLevels = []BlindLevel{
+	{10, 20, 0},
+	{15, 30, 0},
+	{25, 50, 5},
+	{50, 100, 10},
+	{75, 150, 15},
+	{100, 200, 25},
+	{150, 300, 25},
+	{200, 400, 50},
+	{300, 600, 75},
+	{500, 1000, 100},
+}
+
+// 锦标赛设置（创建房间时指定）
+type TournamentSettings struct {
+	BuyIn         int          `json:"buyIn"`         // 买入金额（计入奖池）
+	StartingStack int          `json:"startingStack"` // 起始筹码
+	LevelSeconds  int          `json:"levelSeconds"`  // 每个盲注级别的时长（秒）
+	Levels        []BlindLevel `json:"levels"`        // 盲注级别表
+	MaxRebuys     int          `json:"maxRebuys"`     // 每个玩家最多重购次数（0表示不允许重购）
+	RebuyLevels   int          `json:"rebuyLevels"`   // 前几个级别内允许重购
+	Payouts       []int        `json:"payouts"`       // 奖励结构：按名次分配奖池的百分比
+}
+
+// 默认锦标赛设置
+func defaultTournamentSettings() *TournamentSettings {
+	return &TournamentSettings{
+		BuyIn:         100,
+		StartingStack: 1500,
+		LevelSeconds:  300,
+		Levels:        append([]BlindLevel{}, defaultBlindLevels...),
+		Payouts:       []int{50, 30, 20},
+	}
+}
+
+// 解析锦标赛设置，未指定或无效的字段使用默认值
+func parseTournamentSettings(raw map[string]interface{}) *TournamentSettings {
+	settings := defaultTournamentSettings()
+
+	if buyIn, ok := raw["buyIn"].(float64); ok && buyIn >= 0 {
+		settings.BuyIn = int(buyIn)
+	}
+	if stack, ok := raw["startingStack"].(float64); ok && stack > 0 {
+		settings.StartingStack = int(stack)
+	}
+	if seconds, ok := raw["levelSeconds"].(float64); ok && seconds > 0 {
+		settings.LevelSeconds = int(seconds)
+	}
+	if maxRebuys, ok := raw["maxRebuys"].(float64); ok && maxRebuys >= 0 {
+		settings.MaxRebuys = int(maxRebuys)
+	}
+	if rebuyLevels, ok := raw["rebuyLevels"].(float64); ok && rebuyLevels >= 0 {
+		settings.RebuyLevels = int(rebuyLevels)
+	}
+
+	if levels, ok := raw["levels"].([]interface{}); ok {
+		parsed := []BlindLevel{}
+		for _, item := range levels {
+			level, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			sb, _ := level["smallBlind"].(float64)
+			bb, _ := level["bigBlind"].(float64)
+			ante, _ := level["ante"].(float64)
+			if sb <= 0 || bb < sb || ante < 0 {
+				continue
+			}
+			parsed = append(parsed, BlindLevel{int(sb), int(bb), int(ante)})
+		}
+		if len(parsed) > 0 {
+			settings.Levels = parsed
+		}
+	}
+
+	if payouts, ok := raw["payouts"].([]interface{}); ok {
+		parsed := []int{}
+		total := 0
+		for _, item := range payouts {
+			if pct, ok := item.(float64); ok && pct > 0 {
+				parsed = append(parsed, int(pct))
+				total += int(pct)
+			}
+		}
+		if len(parsed) > 0 && total <= 100 {
+			settings.Payouts = parsed
+		}
+	}
+
+	return settings
+}
+
+// 被淘汰的玩家
+type TournamentEntry struct {
+	PlayerID     string `json:"playerId"`
+	Name         string `json:"name"`
+	PendingRebuy bool   `json:"pendingRebuy"` // 还可以重购，重购期结束后才算正式淘汰
+}
+
+// 最终名次
+type TournamentResult struct {
+	Place    int    `json:"place"`
+	PlayerID string `json:"playerId"`
+	Name     string `json:"name"`
+	Prize    int    `json:"prize"`
+	Deal     bool   `json:"deal,omitempty"` // 奖金由协议分配
+}
+
+// 锦标赛进行状态
+type Tournament struct {
+	Settings    *TournamentSettings `json:"settings"`
+	Started     bool                `json:"started"`
+	Finished    bool                `json:"finished"`
+	Level       int                 `json:"level"`       // 当前盲注级别（从0开始）
+	LevelEndsAt time.Time           `json:"levelEndsAt"` // 当前级别结束时间
+	Entries     int                 `json:"entries"`     // 参赛人数
+	Rebuys      map[string]int      `json:"rebuys"`      // 玩家昵称 -> 重购次数
+	PrizePool   int                 `json:"prizePool"`
+	Busted      []*TournamentEntry  `json:"busted"`  // 按淘汰顺序
+	Results     []TournamentResult  `json:"results"` // 比赛结束后的名次和奖金
+	Deal        *TournamentDeal     `json:"deal"`    // 正在协商或已达成的奖金分配协议
+	LevelTimer  *time.Timer         `json:"-"`
+	LevelLeft   time.Duration       `json:"-"` // 暂停时当前级别剩余的时间
+	Entrants    map[string]*Player  `json:"-"` // 已从钱包支付买入的玩家（玩家ID），开始前离开时退还，结束后发放奖金
+}
+
+// 创建锦标赛状态
+func newTournament(settings *TournamentSettings) *Tournament {
+	return &Tournament{
+		Settings: settings,
+		Rebuys:   make(map[string]int),
+		Entrants: make(map[string]*Player),
+	}
+}
+
+// 当前盲注级别
+func (t *Tournament) currentLevel() BlindLevel {
+	if t.Level >= len(t.Settings.Levels) {
+		return t.Settings.Levels[len(t.Settings.Levels)-1]
+	}
+	return t.Settings.Levels[t.Level]
+}
+
+// 是否还在重购期内
+func (t *Tournament) canRebuy(name string) bool {
+	return !t.Finished && t.Level < t.Settings.RebuyLevels && t.Rebuys[name] < t.Settings.MaxRebuys
+}
+
+// 报名锦标赛：从钱包支付买入并发放起始筹码（调用者需持有写锁）
+func (room *GameRoom) tournamentBuyIn(player *Player) error {
+	t := room.Tournament
+	if t.Started {
+		return fmt.Errorf("锦标赛已开始，无法加入")
+	}
+	if err := walletWithdraw(player, t.Settings.BuyIn); err != nil {
+		return err
+	}
+	player.Chips = t.Settings.StartingStack
+	t.Entrants[player.ID] = player
+	log.Printf("玩家 %s 从钱包支付锦标赛买入 %d，房间 %s", player.Name, t.Settings.BuyIn, room.ID)
+	return nil
+}
+
+// 锦标赛开始前离开座位时退还买入（调用者需持有写锁），没有支付过时返回false
+func (room *GameRoom) refundTournamentBuyIn(player *Player) bool {
+	t := room.Tournament
+	if t == nil || t.Started || t.Entrants[player.ID] == nil {
+		return false
+	}
+	delete(t.Entrants, player.ID)
+	player.Chips = 0
+	walletDeposit(player, t.Settings.BuyIn)
+	log.Printf("退还玩家 %s 的锦标赛买入 %d，房间 %s", player.Name, t.Settings.BuyIn, room.ID)
+	return true
+}
+
+// 获奖的玩家（奖金按名次或协议分配）
+func prizeWinners(results []TournamentResult, entrants map[string]*Player) []*Player {
+	winners := []*Player{}
+	for _, r := range results {
+		if p := entrants[r.PlayerID]; p != nil && r.Prize > 0 {
+			winners = append(winners, p)
+		}
+	}
+	return winners
+}
+
+// 把奖金存入获奖玩家的钱包
+func depositPrizes(results []TournamentResult, entrants map[string]*Player) {
+	for _, r := range results {
+		if p := entrants[r.PlayerID]; p != nil && r.Prize > 0 {
+			walletDeposit(p, r.Prize)
+		}
+	}
+}
+
+// 锦标赛开始：记录参赛人数和奖池，启动盲注级别定时器（调用者需持有写锁）
+func (room *GameRoom) startTournament() {
+	t := room.Tournament
+	t.Started = true
+	t.Entries = len(room.Players)
+	t.PrizePool = t.Entries * t.Settings.BuyIn
+	t.Level = 0
+	room.applyBlindLevel()
+	room.scheduleLevelUp()
+	log.Printf("锦标赛开始，房间 %s，参赛人数 %d，奖池 %d", room.ID, t.Entries, t.PrizePool)
+}
+
+// 把当前级别的盲注应用到房间（调用者需持有写锁）
+func (room *GameRoom) applyBlindLevel() {
+	level := room.Tournament.currentLevel()
+	room.SmallBlind = level.SmallBlind
+	room.BigBlind = level.BigBlind
+	room.Ante = level.Ante
+}
+
+// 启动下一次升级盲注的定时器（调用者需持有写锁）
+// 新的盲注级别从下一局开始生效，房间暂停期间级别计时也暂停
+func (room *GameRoom) scheduleLevelUp() {
+	t := room.Tournament
+	duration := time.Duration(t.Settings.LevelSeconds) * time.Second
+	if t.LevelLeft > 0 {
+		// 暂停后继续，当前级别只剩下暂停时的时间
+		duration = t.LevelLeft
+		t.LevelLeft = 0
+	}
+	t.LevelEndsAt = time.Now().Add(duration)
+
+	roomID := room.ID
+	t.LevelTimer = time.AfterFunc(duration, func() {
+		roomsMutex.RLock()
+		r, exists := rooms[roomID]
+		roomsMutex.RUnlock()
+		if !exists {
+			return
+		}
+
+		r.Mutex.Lock()
+		if r.Tournament != t || t.Finished {
+			r.Mutex.Unlock()
+			return
+		}
+		if t.Level < len(t.Settings.Levels)-1 {
+			t.Level++
+		}
+		r.scheduleLevelUp()
+
+		// 重购期结束，等待重购的玩家正式淘汰
+		msgs := []Message{}
+		if t.Level >= t.Settings.RebuyLevels {
+			for _, entry := range t.Busted {
+				if entry.PendingRebuy {
+					entry.PendingRebuy = false
+					msgs = append(msgs, eliminatedMessage(entry, false))
+				}
+			}
+		}
+
+		level := t.currentLevel()
+		msgs = append(msgs, Message{
+			Type: "blindLevelUp",
+			Data: map[string]interface{}{
+				"level":       t.Level + 1,
+				"smallBlind":  level.SmallBlind,
+				"bigBlind":    level.BigBlind,
+				"ante":        level.Ante,
+				"levelEndsAt": t.LevelEndsAt,
+				"message":     fmt.Sprintf("盲注升至 %d/%d，前注 %d，下一局生效", level.SmallBlind, level.BigBlind, level.Ante),
+			},
+		})
+		recipients := r.recipients()
+		r.Mutex.Unlock()
+
+		log.Printf("锦标赛盲注升级到第 %d 级，房间 %s", t.Level+1, roomID)
+		for _, msg := range msgs {
+			sendToPlayers(recipients, msg)
+		}
+	})
+}
+
+// 一局结束后淘汰筹码为0的玩家，只剩一名玩家时结束比赛（调用者需持有写锁）
+// 返回需要在释放锁之后广播的消息
+func (room *GameRoom) checkTournamentEliminations() []Message {
+	if room.MTT != nil {
+		return room.mttHandEnded()
+	}
+	t := room.Tournament
+	if t == nil || !t.Started || t.Finished {
+		return nil
+	}
+
+	// 本局开始时的筹码，同一局被淘汰的玩家按此排名（筹码多的名次靠前）
+	startChips := make(map[string]int)
+	if n := len(room.HandHistories); n > 0 {
+		for _, hp := range room.HandHistories[n-1].Players {
+			startChips[hp.ID] = hp.StartChips
+		}
+	}
+
+	busted := []*Player{}
+	remaining := []*Player{}
+	for _, p := range room.Players {
+		if p.Chips <= 0 {
+			busted = append(busted, p)
+		} else {
+			remaining = append(remaining, p)
+		}
+	}
+	sort.SliceStable(busted, func(i, j int) bool {
+		return startChips[busted[i].ID] < startChips[busted[j].ID]
+	})
+
+	msgs := []Message{}
+	for _, p := range busted {
+		p.Chips = 0
+		p.Status = PlayerStatusSpectating
+		room.Spectators = append(room.Spectators, p)
+
+		entry := &TournamentEntry{
+			PlayerID:     p.ID,
+			Name:         p.Name,
+			PendingRebuy: t.canRebuy(p.Name),
+		}
+		t.Busted = append(t.Busted, entry)
+		msgs = append(msgs, eliminatedMessage(entry, entry.PendingRebuy))
+		log.Printf("锦标赛玩家 %s 筹码输光，可以重购: %v，房间 %s", p.Name, entry.PendingRebuy, room.ID)
+	}
+	room.Players = remaining
+
+	// 只剩一名玩家（包括等待列表中重购后回来的玩家）时比赛结束
+	if len(room.Players)+len(room.WaitingPlayers) < MIN_TOURNAMENT_PLAYERS {
+		msgs = append(msgs, room.finishTournament())
+	}
+	return msgs
+}
+
+// 已经开始的锦标赛中离开房间的玩家视为被淘汰（调用者需持有写锁），返回需要在释放锁之后广播的消息
+// 坐着的玩家按离开的顺序排名，只剩一名玩家时结束比赛（牌局进行中时在本局结束后检查）
+func (room *GameRoom) tournamentPlayerLeft(player *Player, seated bool) []Message {
+	t := room.Tournament
+	if t == nil || !t.Started || t.Finished {
+		return nil
+	}
+	msgs := []Message{}
+	if seated {
+		entry := &TournamentEntry{PlayerID: player.ID, Name: player.Name}
+		t.Busted = append(t.Busted, entry)
+		msgs = append(msgs, eliminatedMessage(entry, false))
+		log.Printf("锦标赛玩家 %s 离开房间，视为淘汰，房间 %s", player.Name, room.ID)
+	} else {
+		// 已经输光的玩家离开后不能再重购
+		for _, e := range t.Busted {
+			if e.PlayerID == player.ID {
+				e.PendingRebuy = false
+			}
+		}
+	}
+	if room.GamePhase == "waiting" && len(room.Players)+len(room.WaitingPlayers) < MIN_TOURNAMENT_PLAYERS {
+		msgs = append(msgs, room.finishTournament())
+	}
+	return msgs
+}
+
+// 淘汰消息
+func eliminatedMessage(entry *TournamentEntry, canRebuy bool) Message {
+	return Message{
+		Type: "playerEliminated",
+		Data: map[string]interface{}{
+			"playerId": entry.PlayerID,
+			"name":     entry.Name,
+			"canRebuy": canRebuy,
+		},
+	}
+}
+
+// 结束比赛，按奖励结构（或达成的协议）计算名次和奖金并存入获奖玩家的钱包（调用者需持有写锁）
+func (room *GameRoom) finishTournament() Message {
+	t := room.Tournament
+	t.Finished = true
+	if t.LevelTimer != nil {
+		t.LevelTimer.Stop()
+		t.LevelTimer = nil
+	}
+
+	// 名次：剩下的玩家第一（达成协议时按筹码排名），之后按淘汰顺序倒序
+	results := []TournamentResult{}
+	if t.Deal != nil && t.Deal.Agreed {
+		for _, offer := range t.Deal.Offers {
+			results = append(results, TournamentResult{PlayerID: offer.PlayerID, Name: offer.Name})
+		}
+	} else {
+		for _, p := range append(append([]*Player{}, room.Players...), room.WaitingPlayers...) {
+			results = append(results, TournamentResult{PlayerID: p.ID, Name: p.Name})
+		}
+	}
+	for i := len(t.Busted) - 1; i >= 0; i-- {
+		t.Busted[i].PendingRebuy = false
+		results = append(results, TournamentResult{PlayerID: t.Busted[i].PlayerID, Name: t.Busted[i].Name})
+	}
+	for i := range results {
+		results[i].Place = i + 1
+	}
+
+	prizes := calculatePayouts(t.PrizePool, t.Settings.Payouts, len(results))
+	for i := range results {
+		if i < len(prizes) {
+			results[i].Prize = prizes[i]
+		}
+	}
+	if t.Deal != nil && t.Deal.Agreed {
+		for i, offer := range t.Deal.Offers {
+			results[i].Prize = offer.Payout
+			results[i].Deal = true
+		}
+	}
+	t.Results = results
+	recordTournamentResults(room.ID, results)
+	depositPrizes(results, t.Entrants)
+
+	log.Printf("锦标赛结束，房间 %s，冠军 %s，奖池 %d", room.ID, results[0].Name, t.PrizePool)
+	return Message{
+		Type: "tournamentFinished",
+		Data: map[string]interface{}{
+			"results":   results,
+			"prizePool": t.PrizePool,
+			"deal":      t.Deal,
+		},
+	}
+}
+
+// 按奖励结构分配奖池：参赛人数少于奖励名次时只奖励前几名，并按比例放大，零头归第一名
+func calculatePayouts(prizePool int, payouts []int, players int) []int {
+	if players < len(payouts) {
+		payouts = payouts[:players]
+	}
+	total := 0
+	for _, pct := range payouts {
+		total += pct
+	}
+	if total == 0 {
+		return nil
+	}
+
+	prizes := make([]int, len(payouts))
+	paid := 0
+	for i, pct := range payouts {
+		prizes[i] = prizePool * pct / total
+		paid += prizes[i]
+	}
+	prizes[0] += prizePool - paid
+	return prizes
+}
+
+// 锦标赛中重购：补满起始筹码并回到牌桌（调用者需持有写锁）
+// 游戏进行中时先加入等待列表，下一局开始前上桌
+func (room *GameRoom) tournamentRebuy(player *Player) error {
+	t := room.Tournament
+	if !t.Started {
+		return fmt.Errorf("锦标赛还没有开始")
+	}
+
+	var entry *TournamentEntry
+	entryIndex := -1
+	for i, e := range t.Busted {
+		if e.PlayerID == player.ID {
+			entry = e
+			entryIndex = i
+		}
+	}
+	if entry == nil || !entry.PendingRebuy || !t.canRebuy(player.Name) {
+		return fmt.Errorf("当前不能重购")
+	}
+
+	spectatorIndex := -1
+	for i, p := range room.Spectators {
+		if p.ID == player.ID {
+			spectatorIndex = i
+			break
+		}
+	}
+	if spectatorIndex == -1 {
+		return fmt.Errorf("当前不能重购")
+	}
+
+	if err := walletWithdraw(player, t.Settings.BuyIn); err != nil {
+		return err
+	}
+
+	t.Busted = append(t.Busted[:entryIndex], t.Busted[entryIndex+1:]...)
+	t.Rebuys[player.Name]++
+	t.PrizePool += t.Settings.BuyIn
+	player.Chips = t.Settings.StartingStack
+	room.Spectators = append(room.Spectators[:spectatorIndex], room.Spectators[spectatorIndex+1:]...)
+	// 在等待列表中也已经是游戏中的玩家，收到的消息不再按观战延迟和隐藏手牌
+	player.Status = PlayerStatusPlaying
+	if room.GamePhase == "waiting" {
+		room.Players = append(room.Players, player)
+	} else {
+		room.WaitingPlayers = append(room.WaitingPlayers, player)
+	}
+	log.Printf("锦标赛玩家 %s 重购（第 %d 次），房间 %s，奖池 %d", player.Name, t.Rebuys[player.Name], room.ID, t.PrizePool)
+	return nil
+}
+
+// 在释放锁之后广播锦标赛消息
+func (room *GameRoom) sendTournamentMessages(msgs []Message) {
+	if len(msgs) == 0 {
+		return
+	}
+	room.Mutex.RLock()
+	recipients := room.recipients()
+	// 比赛结束时获奖的玩家收到新的钱包余额
+	winners := []*Player{}
+	for _, msg := range msgs {
+		if msg.Type == "tournamentFinished" && room.Tournament != nil {
+			winners = prizeWinners(room.Tournament.Results, room.Tournament.Entrants)
+		}
+	}
+	room.Mutex.RUnlock()
+	for _, msg := range msgs {
+		sendToPlayers(recipients, msg)
+	}
+	for _, p := range winners {
+		sendWallet(p)
+	}
+}
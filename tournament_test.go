@@ -0,0 +1,292 @@
+package main
+
+import (
+	"testing"
+)
+
+// 测试奖池分配
+func TestCalculatePayouts(t *testing.T) {
+	prizes := calculatePayouts(1000, []int{50, 30, 20}, 6)
+	if len(prizes) != 3 || prizes[0] != 500 || prizes[1] != 300 || prizes[2] != 200 {
+		t.Errorf("Expected [500 300 200], got %v", prizes)
+	}
+
+	// 参赛人数少于奖励名次时按比例放大，零头归第一名
+	prizes = calculatePayouts(1001, []int{50, 30, 20}, 2)
+	if len(prizes) != 2 || prizes[0]+prizes[1] != 1001 || prizes[0] != 626 {
+		t.Errorf("Expected [626 375], got %v", prizes)
+	}
+}
+
+// 测试淘汰顺序和最终名次
+func TestTournamentEliminations(t *testing.T) {
+	alice := &Player{ID: "a", Name: "Alice", Chips: 4000}
+	bob := &Player{ID: "b", Name: "Bob", Chips: 0}
+	carol := &Player{ID: "c", Name: "Carol", Chips: 0}
+	settings := defaultTournamentSettings()
+	room := &GameRoom{
+		ID:         "test_tournament",
+		Players:    []*Player{alice, bob, carol},
+		Tournament: newTournament(settings),
+	}
+	room.Tournament.Started = true
+	room.Tournament.Entries = 3
+	room.Tournament.PrizePool = 300
+	// 本局开始时Bob的筹码比Carol多，所以Bob名次更靠前
+	room.HandHistories = []*HandHistory{{Players: []*HandHistoryPlayer{
+		{ID: "a", StartChips: 1500},
+		{ID: "b", StartChips: 1500},
+		{ID: "c", StartChips: 1000},
+	}}}
+
+	msgs := room.checkTournamentEliminations()
+	if len(room.Players) != 1 || room.Players[0] != alice {
+		t.Fatalf("Expected only Alice to remain seated, got %v", room.Players)
+	}
+	if len(room.Spectators) != 2 {
+		t.Errorf("Expected busted players to become spectators, got %d", len(room.Spectators))
+	}
+	if !room.Tournament.Finished {
+		t.Fatal("Tournament should finish with one player left")
+	}
+	if msgs[len(msgs)-1].Type != "tournamentFinished" {
+		t.Errorf("Expected tournamentFinished message last, got %s", msgs[len(msgs)-1].Type)
+	}
+
+	results := room.Tournament.Results
+	expected := []string{"Alice", "Bob", "Carol"}
+	prizes := []int{150, 90, 60}
+	for i, r := range results {
+		if r.Name != expected[i] || r.Place != i+1 || r.Prize != prizes[i] {
+			t.Errorf("Place %d: expected %s with %d, got %+v", i+1, expected[i], prizes[i], r)
+		}
+	}
+}
+
+// 测试重购期内输光的玩家可以重购回到牌桌
+func TestTournamentRebuy(t *testing.T) {
+	settings := defaultTournamentSettings()
+	settings.MaxRebuys = 1
+	settings.RebuyLevels = 2
+	players := []*Player{
+		{ID: "a", Name: "Alice", Chips: 3000},
+		{ID: "b", Name: "Bob", Chips: 0},
+		{ID: "c", Name: "Carol", Chips: 1500},
+	}
+	room := &GameRoom{
+		ID:         "test_rebuy",
+		Players:    players,
+		GamePhase:  "waiting",
+		Tournament: newTournament(settings),
+	}
+	room.Tournament.Started = true
+	room.Tournament.PrizePool = 300
+
+	room.checkTournamentEliminations()
+	if len(room.Tournament.Busted) != 1 || !room.Tournament.Busted[0].PendingRebuy {
+		t.Fatalf("Bob should be waiting to rebuy, got %+v", room.Tournament.Busted)
+	}
+
+	if err := room.tournamentRebuy(players[1]); err != nil {
+		t.Fatalf("Unexpected rebuy error: %v", err)
+	}
+	if players[1].Chips != settings.StartingStack || len(room.Players) != 3 {
+		t.Errorf("Bob should be back with %d chips, got %d chips and %d players", settings.StartingStack, players[1].Chips, len(room.Players))
+	}
+	if room.Tournament.PrizePool != 400 {
+		t.Errorf("Expected prize pool 400 after rebuy, got %d", room.Tournament.PrizePool)
+	}
+
+	// 重购次数用完后再次输光就正式淘汰
+	players[1].Chips = 0
+	room.checkTournamentEliminations()
+	if err := room.tournamentRebuy(players[1]); err == nil {
+		t.Error("Expected an error after the rebuy limit is reached")
+	}
+}
+
+// 测试锦标赛的钱包：报名支付买入，开始前离开退还，重购支付买入，结束后奖金存入钱包
+func TestTournamentWallet(t *testing.T) {
+	settings := defaultTournamentSettings()
+	settings.MaxRebuys = 1
+	settings.RebuyLevels = 2
+	settings.Payouts = []int{100}
+	alice := &Player{ID: "tw_a", Name: "Alice"}
+	bob := &Player{ID: "tw_b", Name: "Bob"}
+	carol := &Player{ID: "tw_c", Name: "Carol"}
+	dave := &Player{ID: "tw_d", Name: "Dave"}
+	room := &GameRoom{ID: "test_tournament_wallet", GamePhase: "waiting", Tournament: newTournament(settings)}
+
+	for _, p := range []*Player{alice, bob, carol, dave} {
+		if err := room.buyInFromWallet(p, 0); err != nil {
+			t.Fatal(err)
+		}
+		if p.Chips != settings.StartingStack || walletBalance(p) != WALLET_INITIAL_BALANCE-settings.BuyIn {
+			t.Fatalf("Buy-in should come from the wallet, chips %d, wallet %d", p.Chips, walletBalance(p))
+		}
+	}
+
+	// 开始前离开退还买入
+	if !room.refundTournamentBuyIn(dave) || walletBalance(dave) != WALLET_INITIAL_BALANCE {
+		t.Errorf("Buy-in should be refunded before the start, wallet %d", walletBalance(dave))
+	}
+	if room.refundTournamentBuyIn(dave) {
+		t.Error("Buy-in should only be refunded once")
+	}
+
+	room.Players = []*Player{alice, bob, carol}
+	room.startTournament()
+	defer room.Tournament.LevelTimer.Stop()
+	if room.refundTournamentBuyIn(alice) {
+		t.Error("Buy-in should not be refunded after the start")
+	}
+
+	// 重购从钱包支付
+	alice.Chips, bob.Chips = 3000, 0
+	room.checkTournamentEliminations()
+	if err := room.tournamentRebuy(bob); err != nil {
+		t.Fatal(err)
+	}
+	if walletBalance(bob) != WALLET_INITIAL_BALANCE-2*settings.BuyIn || room.Tournament.PrizePool != 4*settings.BuyIn {
+		t.Errorf("Rebuy should come from the wallet, wallet %d, prize pool %d", walletBalance(bob), room.Tournament.PrizePool)
+	}
+
+	// 冠军的奖金存入钱包
+	alice.Chips, bob.Chips, carol.Chips = 6000, 0, 0
+	room.checkTournamentEliminations()
+	if !room.Tournament.Finished {
+		t.Fatal("Tournament should finish with one player left")
+	}
+	if balance := walletBalance(alice); balance != WALLET_INITIAL_BALANCE+3*settings.BuyIn {
+		t.Errorf("Prize should go to the winner's wallet, got %d", balance)
+	}
+	if balance := walletBalance(bob); balance != WALLET_INITIAL_BALANCE-2*settings.BuyIn {
+		t.Errorf("Unexpected wallet for the busted player: %d", balance)
+	}
+}
+
+// 测试达成协议后按协议的金额存入钱包
+func TestTournamentDealPayoutsToWallet(t *testing.T) {
+	alice := &Player{ID: "td_a", Name: "Alice", Chips: 2000}
+	bob := &Player{ID: "td_b", Name: "Bob", Chips: 1000}
+	room := &GameRoom{ID: "test_tournament_deal_wallet", Players: []*Player{alice, bob}, Tournament: newTournament(defaultTournamentSettings())}
+	room.Tournament.Entrants[alice.ID] = alice
+	room.Tournament.Entrants[bob.ID] = bob
+	room.Tournament.Started = true
+	room.Tournament.PrizePool = 200
+	room.Tournament.Deal = &TournamentDeal{
+		Offers: []DealOffer{{PlayerID: alice.ID, Name: alice.Name, Payout: 120}, {PlayerID: bob.ID, Name: bob.Name, Payout: 80}},
+		Agreed: true,
+	}
+	room.finishTournament()
+	if walletBalance(alice) != WALLET_INITIAL_BALANCE+120 || walletBalance(bob) != WALLET_INITIAL_BALANCE+80 {
+		t.Errorf("Deal payouts should go to the wallets, got %d and %d", walletBalance(alice), walletBalance(bob))
+	}
+}
+
+// 测试两人对决：庄家下小盲注，翻牌前小盲注跟注后轮到大盲注行动，而不是直接发翻牌
+func TestHeadsUpBigBlindOption(t *testing.T) {
+	alice := &Player{ID: "hu_a", Name: "Alice", Chips: 1000, Status: PlayerStatusPlaying}
+	bob := &Player{ID: "hu_b", Name: "Bob", Chips: 1000, Status: PlayerStatusPlaying}
+	room := &GameRoom{
+		ID:          "test_heads_up",
+		Players:     []*Player{alice, bob},
+		GamePhase:   "waiting",
+		SmallBlind:  10,
+		BigBlind:    20,
+		DealerIndex: 1,
+		Settings:    defaultRoomSettings(),
+	}
+	roomsMutex.Lock()
+	rooms[room.ID] = room
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(rooms, room.ID)
+		roomsMutex.Unlock()
+		room.Mutex.Lock()
+		if room.TurnTimer != nil {
+			room.TurnTimer.Stop()
+		}
+		room.Mutex.Unlock()
+	}()
+
+	startNewHand(room)
+	room.Mutex.RLock()
+	dealer, bigBlind := room.Players[room.DealerIndex], room.Players[room.BigBlindIndex]
+	turn := room.Players[room.CurrentTurn]
+	room.Mutex.RUnlock()
+	if dealer != alice || !alice.IsSmall || !bob.IsBig || turn != alice {
+		t.Fatalf("Dealer should post the small blind and act first, turn %s", turn.Name)
+	}
+
+	handleAction(alice, &Message{Type: "action", Data: map[string]interface{}{"action": "call"}})
+	room.Mutex.RLock()
+	defer room.Mutex.RUnlock()
+	if room.GamePhase != "preflop" || room.Players[room.CurrentTurn] != bigBlind {
+		t.Errorf("Big blind should get the option after the limp, phase %s, turn %s", room.GamePhase, room.Players[room.CurrentTurn].Name)
+	}
+}
+
+// 测试牌局进行中重购：进入等待列表，状态已经是游戏中（不再按观战的人发送消息）
+func TestTournamentRebuyDuringHand(t *testing.T) {
+	settings := defaultTournamentSettings()
+	settings.MaxRebuys = 1
+	settings.RebuyLevels = 1
+	bob := &Player{ID: "rh_b", Name: "Bob", Status: PlayerStatusSpectating}
+	room := &GameRoom{
+		ID:         "test_rebuy_during_hand",
+		Players:    []*Player{{ID: "rh_a", Name: "Alice", Chips: 3000}, {ID: "rh_c", Name: "Carol", Chips: 1500}},
+		Spectators: []*Player{bob},
+		GamePhase:  "flop",
+		Tournament: newTournament(settings),
+	}
+	room.Tournament.Started = true
+	room.Tournament.Busted = []*TournamentEntry{{PlayerID: bob.ID, Name: bob.Name, PendingRebuy: true}}
+
+	if err := room.tournamentRebuy(bob); err != nil {
+		t.Fatal(err)
+	}
+	if len(room.WaitingPlayers) != 1 || room.WaitingPlayers[0] != bob || bob.Status != PlayerStatusPlaying {
+		t.Errorf("Bob should wait for the next hand as a playing player, status %s", bob.Status)
+	}
+}
+
+// 测试已经开始的锦标赛中离开房间视为被淘汰，只剩一名玩家时比赛结束
+func TestTournamentPlayerLeft(t *testing.T) {
+	settings := defaultTournamentSettings()
+	settings.Payouts = []int{70, 30}
+	alice := &Player{ID: "tl_a", Name: "Alice", Chips: 1500, Status: PlayerStatusPlaying}
+	bob := &Player{ID: "tl_b", Name: "Bob", Chips: 1500, Status: PlayerStatusPlaying}
+	carol := &Player{ID: "tl_c", Name: "Carol", Chips: 1500, Status: PlayerStatusPlaying}
+	room := &GameRoom{ID: "test_tournament_left", Players: []*Player{alice, bob, carol}, GamePhase: "waiting", Tournament: newTournament(settings)}
+	for _, p := range room.Players {
+		room.Tournament.Entrants[p.ID] = p
+	}
+	room.startTournament()
+	roomsMutex.Lock()
+	rooms[room.ID] = room
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(rooms, room.ID)
+		roomsMutex.Unlock()
+	}()
+
+	removePlayer(carol)
+	if len(room.Tournament.Busted) != 1 || room.Tournament.Busted[0].PlayerID != carol.ID || room.Tournament.Finished {
+		t.Fatalf("Carol should be eliminated, got %+v", room.Tournament.Busted)
+	}
+
+	removePlayer(bob)
+	if !room.Tournament.Finished {
+		t.Fatal("Tournament should finish with one player left")
+	}
+	expected := []string{"Alice", "Bob", "Carol"}
+	prizes := []int{210, 90, 0}
+	for i, r := range room.Tournament.Results {
+		if r.Name != expected[i] || r.Prize != prizes[i] {
+			t.Errorf("Place %d: expected %s with %d, got %+v", i+1, expected[i], prizes[i], r)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	HTTP_CONN_TIMEOUT    = 60 * time.Second // 没有任何请求超过这个时间后断开，与WebSocket的读超时相同
+	HTTP_POLL_TIMEOUT    = 25 * time.Second // 长轮询没有消息时最多等待的时间
+	HTTP_STREAM_PING     = 15 * time.Second // Server-Sent Events没有消息时发送注释保持连接的间隔
+	HTTP_STREAM_ACK_AGE  = 30 * time.Second // Server-Sent Events写出的消息最晚多久后结束响应，让客户端重连时用Last-Event-ID确认
+	HTTP_STREAM_ACK_SIZE = 200              // 写出这么多条没有确认的消息后也结束响应
+	HTTP_QUEUE_SIZE      = 1000             // 最多保留的没有确认的消息数，超过后发送失败（客户端已经不再读取）
+	HTTP_MAX_MESSAGE_LEN = 64 * 1024        // POST发送的一条消息的最大字节数
+)
+
+// 玩家的连接：WebSocket，或WebSocket被屏蔽时的HTTP连接，发送消息时只用到这两个方法
+type Connection interface {
+	WriteJSON(v interface{}) error
+	Close() error
+}
+
+type queuedMessage struct {
+	ID   int64
+	Data json.RawMessage
+}
+
+// HTTP连接：消息通过Server-Sent Events或长轮询读取，客户端的消息通过POST发送
+// 消息带有递增的编号，读取时确认收到的编号之后才删除，重新连接时不会丢失消息
+type httpConn struct {
+	ID       string
+	Player   *Player
+	mutex    sync.Mutex
+	queue    []queuedMessage
+	lastID   int64
+	signal   chan struct{} // 有新消息或新的读取请求时关闭并替换
+	done     chan struct{}
+	closed   bool
+	reader   int // 当前读取请求的编号，新的读取请求替换之前的
+	lastSeen time.Time
+	handle   sync.Mutex // 同一个连接的消息按顺序处理，与WebSocket一样
+}
+
+var httpConns = make(map[string]*httpConn)
+var httpConnsMutex sync.Mutex
+
+func (c *httpConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return errors.New("连接已关闭")
+	}
+	if len(c.queue) >= HTTP_QUEUE_SIZE {
+		return errors.New("没有确认的消息过多")
+	}
+	c.lastID++
+	c.queue = append(c.queue, queuedMessage{ID: c.lastID, Data: data})
+	c.wake()
+	return nil
+}
+
+func (c *httpConn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+		c.wake()
+	}
+	return nil
+}
+
+// 唤醒等待中的读取请求（调用者需持有c.mutex）
+func (c *httpConn) wake() {
+	close(c.signal)
+	c.signal = make(chan struct{})
+}
+
+// 开始一个读取请求，之前的读取请求随后结束，返回读取请求的编号
+func (c *httpConn) attach() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.reader++
+	c.lastSeen = time.Now()
+	c.wake()
+	return c.reader
+}
+
+// 删除编号不大于ack的消息（客户端确认已经收到），返回编号大于after的消息（这个读取请求还没有写出的）和等待新消息的channel
+// 连接已关闭或被新的读取请求替换时ok为false
+func (c *httpConn) take(reader int, ack, after int64) (messages []queuedMessage, signal <-chan struct{}, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed || c.reader != reader {
+		return nil, nil, false
+	}
+	i := 0
+	for i < len(c.queue) && c.queue[i].ID <= ack {
+		i++
+	}
+	c.queue = c.queue[i:]
+	c.lastSeen = time.Now()
+	rest := c.queue
+	for len(rest) > 0 && rest[0].ID <= after {
+		rest = rest[1:]
+	}
+	return append([]queuedMessage{}, rest...), c.signal, true
+}
+
+func (c *httpConn) idle() time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return time.Since(c.lastSeen)
+}
+
+func (c *httpConn) touch() {
+	c.mutex.Lock()
+	c.lastSeen = time.Now()
+	c.mutex.Unlock()
+}
+
+func newConnID() string {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(raw)
+}
+
+// 连接的账号：带登录令牌的连接使用账号身份，不带令牌的是游客
+// 令牌无效或不允许游客时返回错误响应，ok为false
+func connectionAccount(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if token := requestToken(r); token != "" {
+		username, ok := sessionAccount(token)
+		if !ok {
+			http.Error(w, "登录已过期，请重新登录", http.StatusUnauthorized)
+			return "", false
+		}
+		return username, true
+	}
+	if !ALLOW_GUESTS {
+		http.Error(w, "请先登录", http.StatusUnauthorized)
+		return "", false
+	}
+	return "", true
+}
+
+// 查询参数conn对应的HTTP连接，不存在时返回404
+func requestHTTPConn(w http.ResponseWriter, r *http.Request) *httpConn {
+	httpConnsMutex.Lock()
+	conn := httpConns[r.URL.Query().Get("conn")]
+	httpConnsMutex.Unlock()
+	if conn == nil {
+		http.Error(w, "连接不存在或已断开", http.StatusNotFound)
+	}
+	return conn
+}
+
+// 长时间没有请求时断开连接，断开后与WebSocket断开一样处理
+func watchHTTPConn(conn *httpConn) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case <-conn.done:
+			break loop
+		case <-ticker.C:
+			if conn.idle() > HTTP_CONN_TIMEOUT {
+				log.Printf("HTTP连接超时 (玩家=%s)", conn.Player.ID)
+				conn.Close()
+				break loop
+			}
+		}
+	}
+
+	httpConnsMutex.Lock()
+	delete(httpConns, conn.ID)
+	httpConnsMutex.Unlock()
+	// 等正在处理的消息处理完，之后收到的消息不再处理
+	conn.handle.Lock()
+	playerDisconnected(conn.Player)
+	conn.handle.Unlock()
+}
+
+// HTTP接口：POST /api/connect 建立HTTP连接，返回{"conn": 连接ID}；DELETE /api/connect?conn=... 断开
+// 之后通过 /api/stream 或 /api/poll 接收消息，通过 /api/send 发送消息，消息格式与WebSocket相同
+func handleHTTPConnect(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodDelete:
+		if conn := requestHTTPConn(w, r); conn != nil {
+			conn.Close()
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	account, ok := connectionAccount(w, r)
+	if !ok {
+		return
+	}
+	conn := &httpConn{
+		ID:       newConnID(),
+		signal:   make(chan struct{}),
+		done:     make(chan struct{}),
+		lastSeen: time.Now(),
+	}
+	player := &Player{
+		ID:            generateID(),
+		Conn:          conn,
+		Chips:         INITIAL_CHIPS, // 初始筹码（一手）
+		Status:        PlayerStatusSpectating,
+		LastHeartbeat: time.Now(),
+		RemoteAddr:    clientAddr(r),
+		Account:       account,
+	}
+	conn.Player = player
+
+	httpConnsMutex.Lock()
+	httpConns[conn.ID] = conn
+	httpConnsMutex.Unlock()
+	go watchHTTPConn(conn)
+	go startHeartbeatCheck(player)
+
+	log.Printf("新玩家通过HTTP连接成功: ID=%s, 地址=%s", player.ID, r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"conn": conn.ID})
+}
+
+// HTTP接口：GET /api/stream?conn=... 用Server-Sent Events接收消息
+// 每条消息是一个默认的message事件，id为消息编号；断开后EventSource自动重连，通过Last-Event-ID确认收到的消息并补发之后的消息
+// 写出的消息只有在确认后才删除，所以写出一段时间或一定数量的消息后结束响应，让EventSource重连确认
+func handleHTTPStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	conn := requestHTTPConn(w, r)
+	if conn == nil {
+		return
+	}
+	ack, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+	sent := ack
+	reader := conn.attach()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// 禁止nginx等代理缓冲响应
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	// 结束响应后EventSource等待retry毫秒重连
+	fmt.Fprint(w, "retry: 1000\n\n")
+	flusher.Flush()
+
+	ping := time.NewTicker(HTTP_STREAM_PING)
+	defer ping.Stop()
+	var firstSent time.Time // 第一条没有确认的消息写出的时间
+	unacked := 0
+	for {
+		// 写出的消息在客户端重连时通过Last-Event-ID确认，连接断开时没有确认的消息在重连后补发
+		messages, signal, ok := conn.take(reader, ack, sent)
+		if !ok {
+			return
+		}
+		for _, m := range messages {
+			fmt.Fprintf(w, "id: %d\ndata: %s\n\n", m.ID, m.Data)
+			sent = m.ID
+		}
+		if len(messages) > 0 {
+			flusher.Flush()
+			if unacked == 0 {
+				firstSent = time.Now()
+			}
+			unacked += len(messages)
+		}
+		if unacked >= HTTP_STREAM_ACK_SIZE || (unacked > 0 && time.Since(firstSent) >= HTTP_STREAM_ACK_AGE) {
+			return
+		}
+		select {
+		case <-signal:
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// HTTP接口：GET /api/poll?conn=...&since=N 长轮询接收消息（不支持Server-Sent Events时使用）
+// 返回{"messages": [...], "lastId": M}，没有新消息时最多等待HTTP_POLL_TIMEOUT；since为上次收到的lastId，编号不大于since的消息视为已收到
+func handleHTTPPoll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	conn := requestHTTPConn(w, r)
+	if conn == nil {
+		return
+	}
+	since, _ := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+	reader := conn.attach()
+
+	messages, signal, ok := conn.take(reader, since, since)
+	if ok && len(messages) == 0 {
+		timeout := time.NewTimer(HTTP_POLL_TIMEOUT)
+		defer timeout.Stop()
+		select {
+		case <-signal:
+			messages, _, ok = conn.take(reader, since, since)
+		case <-timeout.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if !ok {
+		http.Error(w, "连接已断开或有新的读取请求", http.StatusConflict)
+		return
+	}
+
+	data := make([]json.RawMessage, len(messages))
+	lastID := since
+	for i, m := range messages {
+		data[i] = m.Data
+		lastID = m.ID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(map[string]interface{}{"messages": data, "lastId": lastID})
+}
+
+// HTTP接口：POST /api/send?conn=... 发送一条消息，请求体与WebSocket的消息相同，由handleMessage处理
+func handleHTTPSend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	conn := requestHTTPConn(w, r)
+	if conn == nil {
+		return
+	}
+	var msg Message
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, HTTP_MAX_MESSAGE_LEN)).Decode(&msg); err != nil {
+		http.Error(w, "无效的消息", http.StatusBadRequest)
+		return
+	}
+
+	conn.touch()
+	conn.handle.Lock()
+	defer conn.handle.Unlock()
+	select {
+	case <-conn.done:
+		http.Error(w, "连接不存在或已断开", http.StatusNotFound)
+		return
+	default:
+	}
+	conn.Player.LastHeartbeat = time.Now()
+	log.Printf("收到HTTP消息 (玩家=%s): 类型=%s", conn.Player.ID, msg.Type)
+	handleMessage(conn.Player, &msg)
+	w.WriteHeader(http.StatusNoContent)
+}
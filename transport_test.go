@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试HTTP连接的消息队列：确认后才删除消息，新的读取请求替换之前的
+func TestHTTPConnQueue(t *testing.T) {
+	conn := &httpConn{signal: make(chan struct{}), done: make(chan struct{})}
+	reader := conn.attach()
+	messages, signal, ok := conn.take(reader, 0, 0)
+	if !ok || len(messages) != 0 {
+		t.Fatal("Expected an empty queue")
+	}
+
+	conn.WriteJSON(Message{Type: "a"})
+	conn.WriteJSON(Message{Type: "b"})
+	select {
+	case <-signal:
+	default:
+		t.Error("Readers should be woken up by new messages")
+	}
+	if messages, _, _ := conn.take(reader, 0, 0); len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	// 已经写出但没有确认的消息不删除，同一个读取请求不重复返回
+	if messages, _, _ := conn.take(reader, 0, 2); len(messages) != 0 || len(conn.queue) != 2 {
+		t.Errorf("Unacknowledged messages should be kept, got %d new and %d queued", len(messages), len(conn.queue))
+	}
+	// 没有确认的消息在重新读取时补发
+	messages, _, _ = conn.take(reader, 1, 1)
+	if len(messages) != 1 || messages[0].ID != 2 || !strings.Contains(string(messages[0].Data), `"b"`) {
+		t.Errorf("Unexpected messages: %+v", messages)
+	}
+
+	next := conn.attach()
+	if _, _, ok := conn.take(reader, 0, 0); ok {
+		t.Error("Replaced reader should stop")
+	}
+	conn.Close()
+	if _, _, ok := conn.take(next, 0, 0); ok {
+		t.Error("Closed connection should stop readers")
+	}
+	if err := conn.WriteJSON(Message{Type: "c"}); err == nil {
+		t.Error("Writing to a closed connection should fail")
+	}
+}
+
+// 测试Server-Sent Events：写出的消息在重连时通过Last-Event-ID确认后才删除，只补发之后的消息
+func TestHTTPStreamAck(t *testing.T) {
+	conn := &httpConn{ID: "test_stream", signal: make(chan struct{}), done: make(chan struct{})}
+	httpConnsMutex.Lock()
+	httpConns[conn.ID] = conn
+	httpConnsMutex.Unlock()
+	defer func() {
+		httpConnsMutex.Lock()
+		delete(httpConns, conn.ID)
+		httpConnsMutex.Unlock()
+	}()
+	conn.WriteJSON(Message{Type: "a"})
+	conn.WriteJSON(Message{Type: "b"})
+
+	stream := func(lastEventID string) string {
+		ctx, cancel := context.WithCancel(context.Background())
+		r := httptest.NewRequest(http.MethodGet, "/api/stream?conn="+conn.ID, nil).WithContext(ctx)
+		if lastEventID != "" {
+			r.Header.Set("Last-Event-ID", lastEventID)
+		}
+		w := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			handleHTTPStream(w, r)
+			close(done)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		<-done
+		return w.Body.String()
+	}
+
+	if body := stream(""); !strings.Contains(body, "id: 1\n") || !strings.Contains(body, "id: 2\n") {
+		t.Fatalf("Expected both messages, got %q", body)
+	}
+	if len(conn.queue) != 2 {
+		t.Fatalf("Written messages should be kept until acknowledged, got %d", len(conn.queue))
+	}
+
+	// 客户端只收到了第一条，重连时补发第二条
+	body := stream("1")
+	if strings.Contains(body, "id: 1\n") || !strings.Contains(body, "id: 2\n") {
+		t.Errorf("Expected only the unacknowledged message, got %q", body)
+	}
+	if len(conn.queue) != 1 || conn.queue[0].ID != 2 {
+		t.Errorf("Acknowledged messages should be removed, got %+v", conn.queue)
+	}
+}
+
+// 测试通过HTTP连接发送消息和长轮询接收回复，消息格式与WebSocket相同
+func TestHTTPTransport(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleHTTPConnect(w, httptest.NewRequest(http.MethodPost, "/api/connect", nil))
+	var connected map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&connected); err != nil || connected["conn"] == "" {
+		t.Fatalf("Unexpected connect response: %d %s", w.Code, w.Body.String())
+	}
+	id := connected["conn"]
+	defer handleHTTPConnect(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/api/connect?conn="+id, nil))
+
+	w = httptest.NewRecorder()
+	handleHTTPSend(w, httptest.NewRequest(http.MethodPost, "/api/send?conn="+id, strings.NewReader(`{"type":"subscribeLobby","data":{}}`)))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Unexpected send response: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handleHTTPPoll(w, httptest.NewRequest(http.MethodGet, "/api/poll?conn="+id+"&since=0", nil))
+	var polled struct {
+		Messages []Message `json:"messages"`
+		LastID   int64     `json:"lastId"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&polled); err != nil {
+		t.Fatal(err)
+	}
+	if len(polled.Messages) != 1 || polled.Messages[0].Type != "lobbyRooms" || polled.LastID != 1 {
+		t.Errorf("Unexpected poll response: %+v", polled)
+	}
+
+	w = httptest.NewRecorder()
+	handleHTTPSend(w, httptest.NewRequest(http.MethodPost, "/api/send?conn=unknown", strings.NewReader(`{"type":"heartbeat"}`)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Unknown connection should return 404, got %d", w.Code)
+	}
+
+	// 断开后连接被移除
+	handleHTTPConnect(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/api/connect?conn="+id, nil))
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		httpConnsMutex.Lock()
+		_, exists := httpConns[id]
+		httpConnsMutex.Unlock()
+		if !exists {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Closed connection should be removed")
+}
@@ -0,0 +1,340 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	SEAT_OFFER_TIMEOUT = 30 // 等候名单中的玩家接受座位的时间（秒）
+)
+
+// 等候名单中的一名玩家
+type WaitlistEntry struct {
+	Player         *Player
+	JoinedAt       time.Time
+	OfferExpiresAt time.Time   // 为他保留座位的截止时间（没有座位时为空）
+	OfferTimer     *time.Timer // 座位保留超时定时器
+}
+
+// 是否已经为他保留了座位
+func (e *WaitlistEntry) offered() bool {
+	return !e.OfferExpiresAt.IsZero()
+}
+
+// 发给客户端的等候名单信息
+type WaitlistPosition struct {
+	PlayerID       string     `json:"playerId"`
+	Name           string     `json:"name"`
+	Position       int        `json:"position"` // 从1开始
+	Offered        bool       `json:"offered"`  // 已经为他保留了座位，等待他接受
+	OfferExpiresAt *time.Time `json:"offerExpiresAt,omitempty"`
+}
+
+// 等候名单（调用者需持有锁）
+func (room *GameRoom) waitlistInfo() []WaitlistPosition {
+	list := make([]WaitlistPosition, len(room.Waitlist))
+	for i, e := range room.Waitlist {
+		list[i] = WaitlistPosition{
+			PlayerID: e.Player.ID,
+			Name:     e.Player.Name,
+			Position: i + 1,
+			Offered:  e.offered(),
+		}
+		if e.offered() {
+			expiresAt := e.OfferExpiresAt
+			list[i].OfferExpiresAt = &expiresAt
+		}
+	}
+	return list
+}
+
+// 玩家在等候名单中的位置，不在名单中时返回-1（调用者需持有锁）
+func (room *GameRoom) waitlistIndex(playerID string) int {
+	for i, e := range room.Waitlist {
+		if e.Player.ID == playerID {
+			return i
+		}
+	}
+	return -1
+}
+
+// 把玩家移出等候名单，返回他是否在名单中（调用者需持有写锁）
+func (room *GameRoom) removeFromWaitlist(playerID string) bool {
+	i := room.waitlistIndex(playerID)
+	if i < 0 {
+		return false
+	}
+	if timer := room.Waitlist[i].OfferTimer; timer != nil {
+		timer.Stop()
+	}
+	room.Waitlist = append(room.Waitlist[:i], room.Waitlist[i+1:]...)
+	return true
+}
+
+// 空座位数：已经为等候名单保留的座位不算空座位（调用者需持有锁）
+// 游戏进行中等待上桌的玩家也占座位
+func (room *GameRoom) freeSeats() int {
+	free := MAX_PLAYERS - len(room.Players) - len(room.WaitingPlayers)
+	for _, e := range room.Waitlist {
+		if e.offered() {
+			free--
+		}
+	}
+	return free
+}
+
+// 是否有还没有分到座位的玩家在排队（调用者需持有锁）
+func (room *GameRoom) waitlistQueued() bool {
+	for _, e := range room.Waitlist {
+		if !e.offered() {
+			return true
+		}
+	}
+	return false
+}
+
+// 两局之间有空座位时按顺序为等候名单中的玩家保留座位（调用者需持有写锁）
+// 返回新分到座位的玩家，需要在释放锁之后通知他们
+func (room *GameRoom) offerSeats() []*Player {
+	if room.GamePhase != "waiting" {
+		return nil
+	}
+	var offered []*Player
+	for _, e := range room.Waitlist {
+		if room.freeSeats() <= 0 {
+			break
+		}
+		if e.offered() {
+			continue
+		}
+		e.OfferExpiresAt = time.Now().Add(SEAT_OFFER_TIMEOUT * time.Second)
+		e.OfferTimer = room.startSeatOfferTimer(e.Player.ID, e.OfferExpiresAt)
+		offered = append(offered, e.Player)
+		log.Printf("为等候名单中的玩家 %s 保留座位，房间 %s", e.Player.Name, room.ID)
+	}
+	return offered
+}
+
+// 座位保留超时：玩家被移出等候名单，座位留给下一个人
+func (room *GameRoom) startSeatOfferTimer(playerID string, expiresAt time.Time) *time.Timer {
+	roomID := room.ID
+	return time.AfterFunc(SEAT_OFFER_TIMEOUT*time.Second, func() {
+		roomsMutex.RLock()
+		r, exists := rooms[roomID]
+		roomsMutex.RUnlock()
+		if !exists {
+			return
+		}
+
+		r.Mutex.Lock()
+		i := r.waitlistIndex(playerID)
+		if i < 0 || !r.Waitlist[i].OfferExpiresAt.Equal(expiresAt) {
+			r.Mutex.Unlock()
+			return
+		}
+		player := r.Waitlist[i].Player
+		r.removeFromWaitlist(playerID)
+		r.Mutex.Unlock()
+
+		log.Printf("玩家 %s 没有及时接受座位，已移出等候名单，房间 %s", player.Name, roomID)
+		sendMessage(player, Message{
+			Type: "seatOfferExpired",
+			Data: map[string]interface{}{
+				"roomId": roomID,
+			},
+		})
+		r.refreshWaitlist(true)
+	})
+}
+
+// 为等候名单保留空出来的座位，并把最新的等候名单发给房间中的所有人（在锁外调用）
+// 一局结束、有玩家离开或等候名单变化后调用，changed为false时只在保留了新座位时广播
+func (room *GameRoom) refreshWaitlist(changed bool) {
+	room.Mutex.Lock()
+	offered := room.offerSeats()
+	waitlist := room.waitlistInfo()
+	recipients := room.recipients()
+	room.Mutex.Unlock()
+	if !changed && len(offered) == 0 {
+		return
+	}
+
+	for _, p := range offered {
+		sendMessage(p, Message{
+			Type: "seatOffered",
+			Data: map[string]interface{}{
+				"roomId":         room.ID,
+				"timeoutSeconds": SEAT_OFFER_TIMEOUT,
+			},
+		})
+	}
+	sendToPlayers(recipients, Message{
+		Type: "waitlistUpdated",
+		Data: map[string]interface{}{
+			"waitlist": waitlist,
+		},
+	})
+}
+
+// 观战的玩家在房间坐满时加入等候名单
+func joinWaitlist(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "未找到房间"},
+		})
+		return
+	}
+
+	room.Mutex.Lock()
+	var err error
+	switch {
+	case room.MTT != nil || room.Tournament != nil:
+		err = errors.New("锦标赛没有等候名单")
+	case room.waitlistIndex(player.ID) >= 0:
+		err = errors.New("您已经在等候名单中")
+	case !room.isSpectator(player.ID):
+		err = errors.New("您不在观战列表中")
+	case room.freeSeats() > 0 && !room.waitlistQueued():
+		err = errors.New("还有空座位，请直接上桌")
+	}
+	if err != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+	room.Waitlist = append(room.Waitlist, &WaitlistEntry{Player: player, JoinedAt: time.Now()})
+	position := len(room.Waitlist)
+	room.Mutex.Unlock()
+
+	log.Printf("玩家 %s 加入等候名单，第 %d 位，房间 %s", player.Name, position, room.ID)
+	room.refreshWaitlist(true)
+}
+
+// 离开等候名单（保留的座位也一起放弃）
+func leaveWaitlist(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "未找到房间"},
+		})
+		return
+	}
+
+	room.Mutex.Lock()
+	removed := room.removeFromWaitlist(player.ID)
+	room.Mutex.Unlock()
+	if !removed {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "您不在等候名单中"},
+		})
+		return
+	}
+
+	log.Printf("玩家 %s 离开等候名单，房间 %s", player.Name, room.ID)
+	room.refreshWaitlist(true)
+}
+
+// 接受保留的座位：两局之间直接上桌，已经开始新的一局时下一局上桌
+func acceptSeat(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "未找到房间"},
+		})
+		return
+	}
+
+	room.Mutex.Lock()
+	i := room.waitlistIndex(player.ID)
+	var err error
+	if i < 0 || !room.Waitlist[i].offered() {
+		err = errors.New("没有为您保留的座位")
+	} else {
+		err = room.buyInFromWallet(player, requestedBuyIn(msg))
+	}
+	if err != nil {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": err.Error()},
+		})
+		return
+	}
+	room.removeFromWaitlist(player.ID)
+	for j, p := range room.Spectators {
+		if p.ID == player.ID {
+			room.Spectators = append(room.Spectators[:j], room.Spectators[j+1:]...)
+			break
+		}
+	}
+	player.Status = PlayerStatusPlaying
+	if room.GamePhase == "waiting" {
+		room.Players = append(room.Players, player)
+	} else {
+		room.WaitingPlayers = append(room.WaitingPlayers, player)
+	}
+	recipients := room.recipients()
+	room.Mutex.Unlock()
+
+	log.Printf("玩家 %s 接受座位上桌，房间 %s", player.Name, room.ID)
+	sendToPlayers(recipients, Message{
+		Type: "playerJoinedTable",
+		Data: map[string]interface{}{
+			"player": player,
+			"room":   room.ToJSON(),
+		},
+	})
+	sendWallet(player)
+	room.refreshWaitlist(true)
+}
+
+// 放弃保留的座位，移出等候名单
+func declineSeat(player *Player, msg *Message) {
+	room := findPlayerRoom(player)
+	if room == nil {
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "未找到房间"},
+		})
+		return
+	}
+
+	room.Mutex.Lock()
+	i := room.waitlistIndex(player.ID)
+	if i < 0 || !room.Waitlist[i].offered() {
+		room.Mutex.Unlock()
+		sendMessage(player, Message{
+			Type: "error",
+			Data: map[string]string{"message": "没有为您保留的座位"},
+		})
+		return
+	}
+	room.removeFromWaitlist(player.ID)
+	room.Mutex.Unlock()
+
+	log.Printf("玩家 %s 放弃座位，已移出等候名单，房间 %s", player.Name, room.ID)
+	room.refreshWaitlist(true)
+}
+
+// 玩家是否在观战列表中（调用者需持有锁）
+func (room *GameRoom) isSpectator(playerID string) bool {
+	for _, p := range room.Spectators {
+		if p.ID == playerID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// 测试空出座位后按顺序为等候名单中的玩家保留座位，接受后上桌
+func TestWaitlistOfferSeats(t *testing.T) {
+	alice := &Player{ID: "wa", Name: "Alice"}
+	bob := &Player{ID: "wb", Name: "Bob"}
+	room := &GameRoom{
+		ID:         "test_waitlist",
+		GamePhase:  "waiting",
+		Spectators: []*Player{alice, bob},
+	}
+	for i := 0; i < MAX_PLAYERS; i++ {
+		room.Players = append(room.Players, &Player{ID: fmt.Sprintf("p%d", i), Name: fmt.Sprintf("P%d", i)})
+	}
+	roomsMutex.Lock()
+	rooms[room.ID] = room
+	roomsMutex.Unlock()
+	defer func() {
+		roomsMutex.Lock()
+		delete(rooms, room.ID)
+		roomsMutex.Unlock()
+	}()
+
+	joinWaitlist(alice, &Message{})
+	joinWaitlist(bob, &Message{})
+	if len(room.Waitlist) != 2 || room.Waitlist[0].Player != alice {
+		t.Fatalf("Expected Alice then Bob on the waitlist, got %+v", room.waitlistInfo())
+	}
+	if offered := room.offerSeats(); len(offered) != 0 {
+		t.Error("No seat should be offered while the table is full")
+	}
+
+	// 游戏进行中空出的座位要等到这一局结束
+	room.Players = room.Players[1:]
+	room.GamePhase = "flop"
+	if offered := room.offerSeats(); len(offered) != 0 {
+		t.Error("Seats should only be offered between hands")
+	}
+	room.GamePhase = "waiting"
+	offered := room.offerSeats()
+	if len(offered) != 1 || offered[0] != alice {
+		t.Fatalf("Expected the seat to be offered to Alice, got %v", offered)
+	}
+	if room.freeSeats() != 0 || !room.waitlistQueued() {
+		t.Error("The offered seat should be held for Alice while Bob keeps waiting")
+	}
+
+	// Bob没有保留的座位，不能接受
+	acceptSeat(bob, &Message{})
+	if !room.isSpectator(bob.ID) {
+		t.Error("Bob should still be spectating")
+	}
+	acceptSeat(alice, &Message{})
+	if len(room.Players) != MAX_PLAYERS || room.Players[MAX_PLAYERS-1] != alice || room.isSpectator(alice.ID) {
+		t.Error("Alice should take the seat")
+	}
+	if info := room.waitlistInfo(); len(info) != 1 || info[0].PlayerID != bob.ID || info[0].Position != 1 {
+		t.Errorf("Bob should move up to the first position, got %+v", info)
+	}
+
+	room.removeFromWaitlist(bob.ID)
+	if len(room.Waitlist) != 0 {
+		t.Error("Bob should be removed from the waitlist")
+	}
+}
@@ -0,0 +1,126 @@
+//go:build !tie_test
+// +build !tie_test
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+const (
+	WALLET_INITIAL_BALANCE = 10000 // 新钱包的初始余额
+)
+
+// 钱包：所有房间共用，上桌时从钱包买入，离桌时把筹码存回钱包，房间里的筹码只在坐着时存在
+// 已登录账号的余额保存在账号文件中；游客的余额按连接保存在内存中，断开连接后清除
+// （不能按昵称保存，否则任何人都可以用别人的昵称使用他的钱包，或者换昵称反复领取初始余额）
+var guestWallets = make(map[string]int) // 游客玩家ID -> 余额
+var guestWalletsMutex sync.Mutex
+
+// 修改钱包余额，change返回新的余额，返回错误时不修改
+func updateWallet(player *Player, change func(balance int) (int, error)) (int, error) {
+	if player.Account != "" {
+		accountsMutex.Lock()
+		defer accountsMutex.Unlock()
+		account, exists := accounts[player.Account]
+		if !exists {
+			return 0, fmt.Errorf("账号不存在")
+		}
+		balance, err := change(account.Wallet)
+		if err != nil {
+			return account.Wallet, err
+		}
+		account.Wallet = balance
+		if err := saveAccounts(); err != nil {
+			log.Printf("保存账号失败: %v", err)
+		}
+		return balance, nil
+	}
+
+	guestWalletsMutex.Lock()
+	defer guestWalletsMutex.Unlock()
+	if player.walletClosed {
+		return 0, fmt.Errorf("连接已断开")
+	}
+	balance, exists := guestWallets[player.ID]
+	if !exists {
+		balance = WALLET_INITIAL_BALANCE
+	}
+	balance, err := change(balance)
+	if err != nil {
+		return balance, err
+	}
+	guestWallets[player.ID] = balance
+	return balance, nil
+}
+
+// 钱包余额
+func walletBalance(player *Player) int {
+	if player.Account != "" {
+		accountsMutex.RLock()
+		defer accountsMutex.RUnlock()
+		if account, exists := accounts[player.Account]; exists {
+			return account.Wallet
+		}
+		return 0
+	}
+
+	guestWalletsMutex.Lock()
+	defer guestWalletsMutex.Unlock()
+	if player.walletClosed {
+		return 0
+	}
+	if balance, exists := guestWallets[player.ID]; exists {
+		return balance
+	}
+	return WALLET_INITIAL_BALANCE
+}
+
+// 从钱包取出筹码，余额不足时返回错误
+func walletWithdraw(player *Player, amount int) error {
+	balance, err := updateWallet(player, func(balance int) (int, error) {
+		if balance < amount {
+			return balance, fmt.Errorf("钱包余额不足，当前余额 %d", balance)
+		}
+		return balance - amount, nil
+	})
+	if err == nil {
+		log.Printf("钱包取出: 玩家=%s, 筹码=%d, 余额=%d", player.Name, amount, balance)
+	}
+	return err
+}
+
+// 把筹码存回钱包
+func walletDeposit(player *Player, amount int) {
+	balance, err := updateWallet(player, func(balance int) (int, error) {
+		return balance + amount, nil
+	})
+	if err != nil {
+		log.Printf("钱包存入失败: 玩家=%s, 筹码=%d, %v", player.Name, amount, err)
+		return
+	}
+	log.Printf("钱包存入: 玩家=%s, 筹码=%d, 余额=%d", player.Name, amount, balance)
+}
+
+// 断开连接后清除游客的钱包（离开房间时的筹码已经存回），之后不再存入
+func closeGuestWallet(player *Player) {
+	if player.Account != "" {
+		return
+	}
+	guestWalletsMutex.Lock()
+	defer guestWalletsMutex.Unlock()
+	player.walletClosed = true
+	delete(guestWallets, player.ID)
+}
+
+// 把钱包余额发给玩家
+func sendWallet(player *Player) {
+	sendMessage(player, Message{
+		Type: "walletUpdated",
+		Data: map[string]interface{}{
+			"balance": walletBalance(player),
+		},
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+)
+
+// 测试钱包：游客按连接保存在内存中，账号的余额保存在账号文件中
+func TestWallet(t *testing.T) {
+	guest := &Player{ID: "wg", Name: "WalletGuest"}
+	if walletBalance(guest) != WALLET_INITIAL_BALANCE {
+		t.Fatal("New wallet should start with the initial balance")
+	}
+	if err := walletWithdraw(guest, WALLET_INITIAL_BALANCE+1); err == nil {
+		t.Error("Should not withdraw more than the balance")
+	}
+	if err := walletWithdraw(guest, 600); err != nil {
+		t.Fatal(err)
+	}
+	walletDeposit(guest, 1000)
+	if balance := walletBalance(guest); balance != WALLET_INITIAL_BALANCE+400 {
+		t.Errorf("Unexpected guest balance %d", balance)
+	}
+
+	// 同一个昵称的另一个连接使用自己的钱包
+	impostor := &Player{ID: "wi", Name: "WalletGuest"}
+	if err := walletWithdraw(impostor, WALLET_INITIAL_BALANCE); err != nil {
+		t.Fatal(err)
+	}
+	if balance := walletBalance(guest); balance != WALLET_INITIAL_BALANCE+400 {
+		t.Errorf("Another connection with the same name should not touch the wallet, got %d", balance)
+	}
+
+	// 断开连接后钱包清除，不再存入
+	closeGuestWallet(guest)
+	walletDeposit(guest, 100)
+	guestWalletsMutex.Lock()
+	_, exists := guestWallets[guest.ID]
+	guestWalletsMutex.Unlock()
+	if exists || walletBalance(guest) != 0 {
+		t.Error("Guest wallet should be removed after disconnecting")
+	}
+
+	useTestAccountsFile(t)
+	if err := registerAccount("walletuser", "secret1"); err != nil {
+		t.Fatal(err)
+	}
+	user := &Player{ID: "wu", Name: "walletuser", Account: "walletuser"}
+	if err := walletWithdraw(user, 500); err != nil {
+		t.Fatal(err)
+	}
+	accountsMutex.Lock()
+	delete(accounts, "walletuser")
+	accountsMutex.Unlock()
+	if err := loadAccounts(); err != nil {
+		t.Fatal(err)
+	}
+	if balance := walletBalance(user); balance != WALLET_INITIAL_BALANCE-500 {
+		t.Errorf("Account balance should be saved, got %d", balance)
+	}
+}
+
+// 测试上桌从钱包买入，离桌把筹码存回钱包
+func TestBuyInFromWallet(t *testing.T) {
+	alice := &Player{ID: "wla", Name: "WalletAlice"}
+	room := &GameRoom{ID: "test_wallet", GamePhase: "waiting", Settings: RoomSettings{MaxBuyIn: 2000}}
+	if err := room.buyInFromWallet(alice, 1500); err != nil {
+		t.Fatal(err)
+	}
+	room.Players = []*Player{alice}
+	if alice.Chips != 1500 || walletBalance(alice) != WALLET_INITIAL_BALANCE-1500 {
+		t.Fatalf("Buy-in should move chips from the wallet, chips %d", alice.Chips)
+	}
+
+	alice.Chips = 2500
+	cashOut := room.recordCashOut(alice)
+	if cashOut.BuyIn != 1500 || cashOut.Net != 1000 || alice.Chips != 0 {
+		t.Errorf("Unexpected cash out %+v", cashOut)
+	}
+	if balance := walletBalance(alice); balance != WALLET_INITIAL_BALANCE+1000 {
+		t.Errorf("Cash out should go back to the wallet, got %d", balance)
+	}
+
+	bob := &Player{ID: "wlb", Name: "WalletBob"}
+	if err := room.buyInFromWallet(bob, 2000); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		room.buyInFromWallet(bob, 2000)
+	}
+	if err := room.buyInFromWallet(bob, 2000); err == nil || bob.Chips != 2000 {
+		t.Error("Should not buy in with an empty wallet")
+	}
+}